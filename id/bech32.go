@@ -0,0 +1,229 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+// bech32.go contains a checksummed text encoding of IDs for use where people
+// copy IDs by hand, such as in config files and support tickets. It is produced
+// by ID.MarshalBech32 and accepted by ID.UnmarshalBech32 and ID.UnmarshalText;
+// ID.MarshalText always produces base 64. Fields and map keys of type Bech32ID
+// use the text encoding in JSON and other text formats. IDs are encoded using
+// bech32m (BIP 350) with the registered name of the ID type as the
+// human-readable prefix and the 32 bytes of ID data as the data part. For
+// example, the node ID with the data bytes 0 through 31 is
+//
+//	node1qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0sl8gx07
+//
+// The checksum detects any error affecting up to four characters, which covers
+// typical transcription mistakes.
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// bech32Charset is the alphabet of the data part; the index of each
+	// character is the 5-bit value it encodes.
+	bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// bech32Separator separates the human-readable prefix from the data.
+	bech32Separator = '1'
+
+	// bech32mConst is the constant the checksum of a valid bech32m string
+	// evaluates to.
+	bech32mConst = 0x2bc830a3
+
+	// bech32ChecksumLen is the number of characters in the checksum.
+	bech32ChecksumLen = 6
+
+	// bech32DataLen is the number of characters encoding the ID data.
+	bech32DataLen = (dataLen*8 + 4) / 5
+)
+
+// Error messages.
+const (
+	bech32UnknownTypeErr = "cannot encode ID with unregistered type %d"
+	bech32MixedCaseErr   = "ID text %q mixes upper and lower case"
+	bech32SeparatorErr   = "ID text %q has no type prefix"
+	bech32PrefixErr      = "ID text has unknown type prefix %q"
+	bech32LengthErr      = "ID text has %d data characters; expected %d"
+	bech32CharErr        = "ID text has invalid character %q at position %d"
+	bech32ChecksumErr    = "ID text %q has an invalid checksum; check it for typos"
+	bech32PaddingErr     = "ID text has non-zero padding bits"
+)
+
+// MarshalBech32 returns the checksummed text encoding of the ID. An error is
+// returned if the ID type is not registered.
+func (id ID) MarshalBech32() ([]byte, error) {
+	name, exists := typeName(id.GetType())
+	if !exists {
+		return nil, errors.Errorf(bech32UnknownTypeErr, id.GetType())
+	}
+	hrp := strings.ToLower(name)
+
+	data := convertBits(id[:dataLen])
+	data = append(data, bech32Checksum(hrp, data)...)
+
+	text := make([]byte, 0, len(hrp)+1+len(data))
+	text = append(text, hrp...)
+	text = append(text, bech32Separator)
+	for _, b := range data {
+		text = append(text, bech32Charset[b])
+	}
+	return text, nil
+}
+
+// UnmarshalBech32 parses the ID from the text encoding produced by
+// ID.MarshalBech32. The text may be all upper case or all lower case. An error
+// is returned if the prefix is not a registered type or if the checksum does
+// not match, which indicates the text was mistyped.
+func (id *ID) UnmarshalBech32(text []byte) error {
+	s := string(text)
+	lower := strings.ToLower(s)
+	if lower != s && strings.ToUpper(s) != s {
+		return errors.Errorf(bech32MixedCaseErr, s)
+	}
+
+	sep := strings.LastIndexByte(lower, bech32Separator)
+	if sep < 1 {
+		return errors.Errorf(bech32SeparatorErr, s)
+	}
+	hrp, encoded := lower[:sep], lower[sep+1:]
+
+	t, err := ParseType(hrp)
+	if err != nil || strings.ToLower(t.String()) != hrp {
+		return errors.Errorf(bech32PrefixErr, hrp)
+	}
+
+	if len(encoded) != bech32DataLen+bech32ChecksumLen {
+		return errors.Errorf(bech32LengthErr,
+			len(encoded)-bech32ChecksumLen, bech32DataLen)
+	}
+
+	data := make([]byte, len(encoded))
+	for i := range encoded {
+		data[i] = byte(strings.IndexByte(bech32Charset, encoded[i]))
+		if data[i] == 0xFF {
+			return errors.Errorf(bech32CharErr, s[sep+1+i], sep+1+i)
+		}
+	}
+
+	if bech32Polymod(append(bech32HrpExpand(hrp), data...)) != bech32mConst {
+		return errors.Errorf(bech32ChecksumErr, s)
+	}
+
+	idData, ok := unconvertBits(data[:bech32DataLen])
+	if !ok {
+		return errors.New(bech32PaddingErr)
+	}
+
+	*id = *copyID(idData)
+	id.SetType(t)
+	return nil
+}
+
+// Bech32ID is an ID that is encoded with ID.MarshalBech32 in text formats such
+// as JSON, for use in fields and map keys that people read or edit by hand.
+// Convert between the two with Bech32ID(id) and ID(b).
+type Bech32ID ID
+
+// MarshalText returns the checksummed text encoding of the ID. This function
+// adheres to the encoding.TextMarshaler interface.
+func (b Bech32ID) MarshalText() ([]byte, error) {
+	return ID(b).MarshalBech32()
+}
+
+// UnmarshalText parses the ID from its checksummed text encoding. This
+// function adheres to the encoding.TextUnmarshaler interface.
+func (b *Bech32ID) UnmarshalText(text []byte) error {
+	return (*ID)(b).UnmarshalBech32(text)
+}
+
+// bech32Checksum returns the six 5-bit values of the checksum of the prefix and
+// data.
+func bech32Checksum(hrp string, data []byte) []byte {
+	values := append(bech32HrpExpand(hrp), data...)
+	values = append(values, make([]byte, bech32ChecksumLen)...)
+	mod := bech32Polymod(values) ^ bech32mConst
+
+	checksum := make([]byte, bech32ChecksumLen)
+	for i := range checksum {
+		checksum[i] = byte(mod>>(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+// bech32Polymod computes the BCH checksum over the 5-bit values as specified in
+// BIP 173.
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+// bech32HrpExpand expands the human-readable prefix into the values that are
+// included in the checksum.
+func bech32HrpExpand(hrp string) []byte {
+	values := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]>>5)
+	}
+	values = append(values, 0)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]&31)
+	}
+	return values
+}
+
+// convertBits regroups bytes into 5-bit values, padding the last value with
+// zeros.
+func convertBits(data []byte) []byte {
+	var out []byte
+	var acc uint32
+	var bits uint
+	for _, b := range data {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out = append(out, byte(acc>>bits)&31)
+		}
+	}
+	if bits > 0 {
+		out = append(out, byte(acc<<(5-bits))&31)
+	}
+	return out
+}
+
+// unconvertBits regroups 5-bit values into bytes. It returns false if the
+// leftover padding bits are not zero, so every ID has exactly one encoding.
+func unconvertBits(data []byte) ([]byte, bool) {
+	var out []byte
+	var acc uint32
+	var bits uint
+	for _, v := range data {
+		acc = acc<<5 | uint32(v)
+		bits += 5
+		if bits >= 8 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+		}
+	}
+	return out, bits < 5 && acc&(1<<bits-1) == 0
+}
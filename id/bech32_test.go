@@ -0,0 +1,226 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Tests that the checksum matches the valid bech32m test vectors from BIP 350.
+func Test_bech32Polymod_Vectors(t *testing.T) {
+	for _, vector := range []string{
+		"a1lqfn3a",
+		"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+		"split1checkupstagehandshakeupstreamerranterredcaperredlc445v",
+		"?1v759aa",
+	} {
+		sep := strings.LastIndexByte(vector, bech32Separator)
+		values := bech32HrpExpand(vector[:sep])
+		for _, c := range vector[sep+1:] {
+			values = append(values, byte(strings.IndexRune(bech32Charset, c)))
+		}
+
+		if bech32Polymod(values) != bech32mConst {
+			t.Errorf("Checksum of %q is not valid.", vector)
+		}
+	}
+}
+
+// Tests that the encoding of a known ID matches the expected string.
+func TestID_MarshalBech32(t *testing.T) {
+	id := &ID{}
+	for i := 0; i < dataLen; i++ {
+		id[i] = byte(i)
+	}
+	id.SetType(Node)
+
+	expected := "node1qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0sl8gx07"
+	s, err := id.MarshalBech32()
+	if err != nil || string(s) != expected {
+		t.Errorf("Unexpected encoding.\nexpected: %s\nreceived: %s\nerror: %+v",
+			expected, s, err)
+	}
+}
+
+// Tests that IDs of every registered type are parsed from their encoding in
+// both lower and upper case.
+func TestID_UnmarshalBech32(t *testing.T) {
+	prng := rand.New(rand.NewSource(42))
+	for _, idType := range RegisteredTypes() {
+		for i := 0; i < 10; i++ {
+			id := NewRandomTestID(prng, idType, t)
+			s, err := id.MarshalBech32()
+			if err != nil {
+				t.Fatalf("Failed to encode ID: %+v", err)
+			}
+			if !strings.HasPrefix(string(s), idType.String()+"1") {
+				t.Errorf("Encoding %s does not have the type prefix.", s)
+			}
+
+			for _, text := range []string{string(s), strings.ToUpper(string(s))} {
+				parsed := &ID{}
+				if err = parsed.UnmarshalBech32([]byte(text)); err != nil {
+					t.Errorf("Failed to parse %s: %+v", text, err)
+				} else if *parsed != *id {
+					t.Errorf("Unexpected ID.\nexpected: %s\nreceived: %s",
+						id, parsed)
+				}
+			}
+		}
+	}
+}
+
+// Error path: Tests that ID.UnmarshalBech32 detects single-character
+// substitutions, swapped adjacent characters, and malformed text.
+func TestID_UnmarshalBech32_Error(t *testing.T) {
+	id := NewRandomTestID(rand.New(rand.NewSource(7)), User, t)
+	encoded, _ := id.MarshalBech32()
+	s := string(encoded)
+	parse := func(text string) error {
+		return (&ID{}).UnmarshalBech32([]byte(text))
+	}
+	sep := strings.LastIndexByte(s, bech32Separator)
+
+	for i := sep + 1; i < len(s); i++ {
+		for _, c := range bech32Charset {
+			if byte(c) == s[i] {
+				continue
+			}
+			typo := s[:i] + string(c) + s[i+1:]
+			if err := parse(typo); err == nil {
+				t.Fatalf("Substitution at %d not detected: %s", i, typo)
+			}
+		}
+
+		if i+1 < len(s) && s[i] != s[i+1] {
+			swapped := s[:i] + string(s[i+1]) + string(s[i]) + s[i+2:]
+			if err := parse(swapped); err == nil {
+				t.Fatalf("Swap at %d not detected: %s", i, swapped)
+			}
+		}
+	}
+
+	for text, expected := range map[string]string{
+		s[:sep] + strings.ToUpper(s[sep:]): "mixes upper and lower case",
+		s[sep+1:]:                          "no type prefix",
+		"node" + s[sep:]:                   "invalid checksum",
+		"relay" + s[sep:]:                  "unknown type prefix",
+		"4" + s[sep:]:                      "unknown type prefix",
+		s[:len(s)-1]:                       "data characters",
+		s[:len(s)-1] + "b":                 "invalid character",
+	} {
+		if err := parse(text); err == nil ||
+			!strings.Contains(err.Error(), expected) {
+			t.Errorf("Unexpected error for %q.\nexpected: %s\nreceived: %+v",
+				text, expected, err)
+		}
+	}
+}
+
+// Error path: Tests that ID.MarshalBech32 returns an error for an unregistered
+// type.
+func TestID_MarshalBech32_UnknownType(t *testing.T) {
+	id := NewIdFromUInt(1, 250, t)
+	if _, err := id.MarshalBech32(); err == nil {
+		t.Errorf("No error for unregistered type.")
+	}
+}
+
+// Tests that ID.MarshalText always produces base 64 and that ID.UnmarshalText
+// accepts both base 64 and bech32 map keys.
+func TestID_UnmarshalText_Bech32(t *testing.T) {
+	ids := map[ID]int{}
+	bech32Keys := map[string]int{}
+	prng := rand.New(rand.NewSource(95))
+	for i := 0; i < 5; i++ {
+		id := NewRandomTestID(prng, Gateway, t)
+		ids[*id] = i
+		key, err := id.MarshalBech32()
+		if err != nil {
+			t.Fatalf("Failed to encode ID: %+v", err)
+		}
+		bech32Keys[string(key)] = i
+	}
+
+	base64JSON, err := json.Marshal(ids)
+	if err != nil {
+		t.Fatalf("Failed to marshal map: %+v", err)
+	}
+	if strings.Contains(string(base64JSON), `"gateway1`) {
+		t.Errorf("MarshalText produced bech32 keys: %s", base64JSON)
+	}
+	bech32JSON, err := json.Marshal(bech32Keys)
+	if err != nil {
+		t.Fatalf("Failed to marshal map: %+v", err)
+	}
+
+	for _, data := range [][]byte{base64JSON, bech32JSON} {
+		var received map[ID]int
+		if err = json.Unmarshal(data, &received); err != nil {
+			t.Errorf("Failed to unmarshal %s: %+v", data, err)
+		}
+		for id, i := range ids {
+			if received[id] != i {
+				t.Errorf("Missing ID %s in %s", &id, data)
+			}
+		}
+	}
+}
+
+// Tests that a struct with a Bech32ID field and a map with Bech32ID keys are
+// encoded as bech32 in JSON and decode to the original IDs.
+func TestBech32ID_JSON(t *testing.T) {
+	type config struct {
+		Node  Bech32ID
+		Peers map[Bech32ID]string
+	}
+
+	var nodeID ID
+	for i := 0; i < dataLen; i++ {
+		nodeID[i] = byte(i)
+	}
+	nodeID.SetType(Node)
+	prng := rand.New(rand.NewSource(42))
+	peerID := NewRandomTestID(prng, Gateway, t)
+
+	expected := config{
+		Node:  Bech32ID(nodeID),
+		Peers: map[Bech32ID]string{Bech32ID(*peerID): "peer"},
+	}
+
+	data, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %+v", err)
+	}
+
+	expectedNode := `"Node":"node1qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydp` +
+		`k8qarc0sl8gx07"`
+	if !strings.Contains(string(data), expectedNode) ||
+		!strings.Contains(string(data), `"gateway1`) {
+		t.Errorf("JSON does not use bech32: %s", data)
+	}
+
+	var received config
+	if err = json.Unmarshal(data, &received); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %+v", err)
+	}
+	if ID(received.Node) != nodeID ||
+		received.Peers[Bech32ID(*peerID)] != "peer" || len(received.Peers) != 1 {
+		t.Errorf("Unexpected config.\nexpected: %+v\nreceived: %+v",
+			expected, received)
+	}
+
+	// Base 64 is not accepted
+	base64JSON, _ := json.Marshal(map[string]*ID{"Node": &nodeID})
+	if err = json.Unmarshal(base64JSON, &received); err == nil {
+		t.Errorf("Unmarshalled base 64 ID into a Bech32ID: %s", base64JSON)
+	}
+}
@@ -0,0 +1,87 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+// derive.go contains the derivation of IDs from an RSA public key and salt, so
+// that any party holding the key and salt can check that an ID belongs to the
+// key.
+//
+// The derivation is the same as xx.NewID in the crypto repository, which
+// produces the gateway, node, and user IDs stored in idf.IdFile. The ID data is
+// the SHA-256 hash of
+//
+//	N || E || salt
+//
+// where N and E are the modulus and public exponent of the key as big-endian
+// integers without leading zeros and salt is the DerivationSaltLen byte salt.
+// The last byte of the ID is set to the type as usual.
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// DerivationSaltLen is the required length of the salt used by
+// NewFromPublicKey.
+const DerivationSaltLen = 32
+
+// Error messages.
+const (
+	deriveSaltLenErr  = "salt length must be %d; received length of %d"
+	deriveNilKeyErr   = "cannot derive ID from a nil public key"
+	deriveModulusErr  = "cannot derive ID from a public key with modulus %s"
+	deriveExponentErr = "cannot derive ID from a public key with exponent %d"
+	deriveMismatchErr = "ID %s is not derived from the public key and salt"
+)
+
+// NewFromPublicKey derives an ID of the given type from the RSA public key and
+// salt in the same way as xx.NewID. An error is returned if the salt is not
+// DerivationSaltLen bytes long or the key is invalid.
+func NewFromPublicKey(pubKey *rsa.PublicKey, salt []byte, t Type) (*ID, error) {
+	if len(salt) != DerivationSaltLen {
+		return nil, errors.Errorf(
+			deriveSaltLenErr, DerivationSaltLen, len(salt))
+	}
+	if pubKey == nil || pubKey.N == nil {
+		return nil, errors.New(deriveNilKeyErr)
+	}
+	if pubKey.N.BitLen() <= 1 {
+		return nil, errors.Errorf(deriveModulusErr, pubKey.N)
+	}
+	if pubKey.E < 2 {
+		return nil, errors.Errorf(deriveExponentErr, pubKey.E)
+	}
+
+	h := sha256.New()
+	h.Write(pubKey.N.Bytes())
+	h.Write(big.NewInt(int64(pubKey.E)).Bytes())
+	h.Write(salt)
+
+	newID := copyID(h.Sum(nil))
+	newID.SetType(t)
+	return newID, nil
+}
+
+// VerifyDerivation checks that the ID was derived from the RSA public key and
+// salt by NewFromPublicKey or xx.NewID. Returns nil if it was or an error if it
+// was not or the key or salt is invalid. The type is not part of the
+// derivation, so it is not checked.
+func (id *ID) VerifyDerivation(pubKey *rsa.PublicKey, salt []byte) error {
+	derived, err := NewFromPublicKey(pubKey, salt, id.GetType())
+	if err != nil {
+		return err
+	}
+
+	if *derived != *id {
+		return errors.Errorf(deriveMismatchErr, id)
+	}
+	return nil
+}
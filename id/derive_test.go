@@ -0,0 +1,129 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// newTestSalt returns a salt with the bytes 0xFF, 0xFE, ..., 0xE0.
+func newTestSalt() []byte {
+	salt := make([]byte, DerivationSaltLen)
+	for i := range salt {
+		salt[i] = byte(0xFF - i)
+	}
+	return salt
+}
+
+// newTestPublicKey returns a fixed 1024-bit RSA public key.
+func newTestPublicKey() *rsa.PublicKey {
+	n, _ := new(big.Int).SetString("D9793E6427D2A6997A814C1BEAB3E87973DC9211E"+
+		"2709AB1D4D011CC2D4780A8460E6A45E7788B1092F53F81EC67E10BEDB21A214CCF92"+
+		"C431E9F0240532773D270DA4D7F99DCC7FC804982E9F975EC6787E38D3CFCC7F8521B"+
+		"715FED849954E8D3F763C8E0349747234BB0A415063868D808A7E1CDACF3664ABDA76"+
+		"9191914F", 16)
+	return &rsa.PublicKey{N: n, E: 65537}
+}
+
+// Tests that NewFromPublicKey produces the node and gateway IDs that xx.NewID
+// produces for a fixed key and salt. The expected data is the SHA-256 hash of
+// the modulus, the exponent 0x010001, and the salt, computed independently of
+// this package.
+func TestNewFromPublicKey_Vector(t *testing.T) {
+	const data = "e1971027ac3c690aab7c203655a1ba0d" +
+		"283077b03f6365b32b3df47f59221388"
+	tests := []struct {
+		t        Type
+		expected string
+	}{
+		{Node, data + "02"},
+		{Gateway, data + "01"},
+	}
+
+	for _, tt := range tests {
+		newID, err := NewFromPublicKey(newTestPublicKey(), newTestSalt(), tt.t)
+		if err != nil {
+			t.Fatalf("Failed to derive %s ID: %+v", tt.t, err)
+		}
+
+		if hex.EncodeToString(newID[:]) != tt.expected {
+			t.Errorf("Unexpected %s ID.\nexpected: %s\nreceived: %x",
+				tt.t, tt.expected, newID[:])
+		}
+	}
+}
+
+// Tests that an ID derived by NewFromPublicKey is verified by
+// ID.VerifyDerivation with the same key and salt and rejected otherwise.
+func TestID_VerifyDerivation(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	pubKey := &rsaKey.PublicKey
+	salt := newTestSalt()
+
+	newID, err := NewFromPublicKey(pubKey, salt, Gateway)
+	if err != nil {
+		t.Fatalf("Failed to derive ID: %+v", err)
+	}
+	if newID.GetType() != Gateway {
+		t.Errorf("Unexpected type %s.", newID.GetType())
+	}
+
+	if err = newID.VerifyDerivation(pubKey, salt); err != nil {
+		t.Errorf("Failed to verify ID: %+v", err)
+	}
+
+	if err = newID.VerifyDerivation(&otherKey.PublicKey, salt); err == nil {
+		t.Errorf("Verified ID with the wrong key.")
+	}
+
+	otherSalt := newTestSalt()
+	otherSalt[0] ^= 1
+	if err = newID.VerifyDerivation(pubKey, otherSalt); err == nil ||
+		!strings.Contains(err.Error(), "is not derived") {
+		t.Errorf("Unexpected error for wrong salt: %+v", err)
+	}
+
+	// The type is not part of the hash, so changing it keeps the ID verifiable
+	retyped := newID.DeepCopy()
+	retyped.SetType(Node)
+	if err = retyped.VerifyDerivation(pubKey, salt); err != nil {
+		t.Errorf("Failed to verify ID with a changed type: %+v", err)
+	}
+	if *retyped == *newID {
+		t.Errorf("Changing the type did not change the ID.")
+	}
+}
+
+// Error path: Tests that NewFromPublicKey rejects salts of the wrong length
+// and invalid keys.
+func TestNewFromPublicKey_Error(t *testing.T) {
+	pubKey := newTestPublicKey()
+
+	if _, err := NewFromPublicKey(pubKey, make([]byte, 16), User); err == nil ||
+		!strings.Contains(err.Error(), "salt length") {
+		t.Errorf("Unexpected error for short salt: %+v", err)
+	}
+
+	invalid := []*rsa.PublicKey{
+		nil,
+		{E: 65537},
+		{N: big.NewInt(1), E: 65537},
+		{N: pubKey.N, E: 1},
+	}
+	for i, key := range invalid {
+		if _, err := NewFromPublicKey(key, newTestSalt(), User); err == nil {
+			t.Errorf("No error for invalid key %d.", i)
+		}
+	}
+}
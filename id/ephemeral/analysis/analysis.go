@@ -0,0 +1,294 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Package analysis measures how ephemeral IDs collide for a population of
+// identities and an address space schedule. Because ephemeral.Id.Clear
+// truncates IDs to the address space size, every identity shares its ephemeral
+// ID with the other identities that land on the same value; the number of
+// identities sharing an ID is its anonymity set. The analysis runs the same
+// GetIdFromIntermediary pipeline that clients use, so the results can be used
+// to justify changes to the address space size.
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/id/ephemeral"
+	"gitlab.com/xx_network/primitives/ndf"
+)
+
+// DefaultStep is the interval between samples used when Config.Step is zero.
+const DefaultStep = time.Hour
+
+// Error messages.
+const (
+	emptyPopulationErr = "population is empty: set the population count or " +
+		"provide a sample of IDs"
+	windowErr         = "window %s must be positive"
+	stepErr           = "step %s must be positive"
+	scheduleErr       = "invalid address space schedule: %+v"
+	generatePopErr    = "failed to generate population ID %d: %+v"
+	intermediaryIdErr = "failed to get intermediary ID of %s: %+v"
+	ephemeralIdErr    = "failed to get ephemeral ID of %s at %s: %+v"
+)
+
+// Config describes an analysis.
+type Config struct {
+	// Sample is the population of IDs to analyse. If it is empty, Population
+	// random user IDs are generated from Seed instead.
+	Sample     []*id.ID
+	Population int
+	Seed       int64
+
+	// Schedule is the address space schedule, as found in the NDF. It must
+	// have an entry in effect at Start.
+	Schedule []ndf.AddressSpace
+
+	// Start and Window are the time range to analyse. The population is
+	// sampled every Step in the range, starting at Start. If Step is zero,
+	// DefaultStep is used.
+	Start  time.Time
+	Window time.Duration
+	Step   time.Duration
+}
+
+// Report contains the results of an analysis.
+type Report struct {
+	// Samples contains the result at each sampled time.
+	Samples []Sample
+
+	// Distribution maps an anonymity set size to the number of times an
+	// identity had a set of that size, over all samples.
+	Distribution map[int]int
+
+	// MeanSet is the mean anonymity set size over all identities and samples.
+	MeanSet float64
+
+	// MinSet is the smallest anonymity set seen in any sample.
+	MinSet int
+}
+
+// Sample is the state of the population at one point in time.
+type Sample struct {
+	Time time.Time
+
+	// Size is the address space size in bits in effect at Time.
+	Size uint
+
+	// Population is the number of identities.
+	Population int
+
+	// DistinctIds is the number of different ephemeral IDs in use.
+	DistinctIds int
+
+	// Colliding is the number of identities that share their ephemeral ID
+	// with at least one other identity.
+	Colliding int
+
+	// MinSet, MaxSet, and MeanSet describe the anonymity set sizes of the
+	// identities. The anonymity set of an identity includes itself, so it is
+	// never smaller than one.
+	MinSet  int
+	MaxSet  int
+	MeanSet float64
+
+	// Distribution maps an anonymity set size to the number of identities
+	// with a set of that size.
+	Distribution map[int]int
+
+	// Expected contains the values predicted for a uniform random assignment
+	// of IDs, for comparison.
+	Expected Expectation
+}
+
+// Expectation contains the values expected when each identity is assigned one
+// of the available ephemeral IDs uniformly at random.
+type Expectation struct {
+	DistinctIds float64
+	Colliding   float64
+	MeanSet     float64
+}
+
+// Run performs the analysis described by the config.
+func Run(c Config) (*Report, error) {
+	if c.Window <= 0 {
+		return nil, errors.Errorf(windowErr, c.Window)
+	}
+	step := c.Step
+	if step == 0 {
+		step = DefaultStep
+	} else if step < 0 {
+		return nil, errors.Errorf(stepErr, step)
+	}
+
+	schedule := &ndf.NetworkDefinition{AddressSpace: c.Schedule}
+	if _, err := schedule.AddressSpaceSize(c.Start); err != nil {
+		return nil, errors.Errorf(scheduleErr, err)
+	}
+
+	population, err := c.population()
+	if err != nil {
+		return nil, err
+	}
+
+	iids := make([][]byte, len(population))
+	for i, popId := range population {
+		if iids[i], err = ephemeral.GetIntermediaryId(popId); err != nil {
+			return nil, errors.Errorf(intermediaryIdErr, popId, err)
+		}
+	}
+
+	report := &Report{Distribution: make(map[int]int)}
+	var setTotal float64
+	end := c.Start.Add(c.Window)
+	for t := c.Start; t.Before(end); t = t.Add(step) {
+		size, err := schedule.AddressSpaceSize(t)
+		if err != nil {
+			return nil, errors.Errorf(scheduleErr, err)
+		}
+
+		s, err := sample(population, iids, uint(size), t)
+		if err != nil {
+			return nil, err
+		}
+
+		for setSize, count := range s.Distribution {
+			report.Distribution[setSize] += count
+		}
+		if len(report.Samples) == 0 || s.MinSet < report.MinSet {
+			report.MinSet = s.MinSet
+		}
+		setTotal += s.MeanSet
+		report.Samples = append(report.Samples, s)
+	}
+	report.MeanSet = setTotal / float64(len(report.Samples))
+
+	return report, nil
+}
+
+// Expected returns the values expected for a population of the given number of
+// identities that are assigned IDs of the given size uniformly at random.
+// Reserved IDs that fit in the size are excluded from the available IDs.
+func Expected(population int, size uint) Expectation {
+	if population <= 0 {
+		return Expectation{}
+	}
+
+	// The number of available IDs can exceed the precision of a float64, but
+	// the result is only used as an estimate
+	available := math.Exp2(float64(size))
+	for _, r := range ephemeral.V1().Reserved {
+		if r.Clear(size) == r {
+			available--
+		}
+	}
+	if available < 1 {
+		available = 1
+	}
+
+	n := float64(population)
+
+	// Probability that a given ID is not used by one identity
+	pEmpty := math.Log1p(-1 / available)
+	if math.IsInf(pEmpty, -1) {
+		// There is only one ID, so every identity uses it
+		e := Expectation{DistinctIds: 1, MeanSet: n}
+		if population > 1 {
+			e.Colliding = n
+		}
+		return e
+	}
+
+	return Expectation{
+		DistinctIds: available * -math.Expm1(n*pEmpty),
+		Colliding:   n * -math.Expm1((n-1)*pEmpty),
+		MeanSet:     1 + (n-1)/available,
+	}
+}
+
+// sample computes the ephemeral ID of every identity at time t and measures
+// the anonymity sets.
+func sample(population []*id.ID, iids [][]byte, size uint, t time.Time) (
+	Sample, error) {
+	eids := make([]ephemeral.Id, len(iids))
+	counts := make(map[ephemeral.Id]int)
+	for i, iid := range iids {
+		eid, _, _, err := ephemeral.GetIdFromIntermediary(iid, size, t.UnixNano())
+		if err != nil {
+			return Sample{}, errors.Errorf(ephemeralIdErr, population[i], t, err)
+		}
+		eids[i] = eid
+		counts[eid]++
+	}
+
+	s := Sample{
+		Time:         t,
+		Size:         size,
+		Population:   len(iids),
+		DistinctIds:  len(counts),
+		Distribution: make(map[int]int),
+		Expected:     Expected(len(iids), size),
+	}
+
+	var total int
+	for i, eid := range eids {
+		setSize := counts[eid]
+		s.Distribution[setSize]++
+		if setSize > 1 {
+			s.Colliding++
+		}
+		if i == 0 || setSize < s.MinSet {
+			s.MinSet = setSize
+		}
+		if setSize > s.MaxSet {
+			s.MaxSet = setSize
+		}
+		total += setSize
+	}
+	s.MeanSet = float64(total) / float64(len(eids))
+
+	return s, nil
+}
+
+// population returns the sample of IDs or generates the population from the
+// seed.
+func (c Config) population() ([]*id.ID, error) {
+	if len(c.Sample) > 0 {
+		return c.Sample, nil
+	}
+	if c.Population <= 0 {
+		return nil, errors.New(emptyPopulationErr)
+	}
+
+	rng := rand.New(rand.NewSource(c.Seed))
+	population := make([]*id.ID, c.Population)
+	for i := range population {
+		popId, err := id.NewRandomID(rng, id.User)
+		if err != nil {
+			return nil, errors.Errorf(generatePopErr, i, err)
+		}
+		population[i] = popId
+	}
+	return population, nil
+}
+
+// SetSizes returns the anonymity set sizes in the distribution in ascending
+// order.
+func SetSizes(distribution map[int]int) []int {
+	sizes := make([]int, 0, len(distribution))
+	for setSize := range distribution {
+		sizes = append(sizes, setSize)
+	}
+	sort.Ints(sizes)
+	return sizes
+}
@@ -0,0 +1,209 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package analysis
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/id/ephemeral"
+	"gitlab.com/xx_network/primitives/ndf"
+)
+
+// Tests that Run measures anonymity sets that are consistent with each other
+// and close to the expected values, and that the size changes with the
+// schedule.
+func TestRun(t *testing.T) {
+	start := time.Unix(0, 1614199942358373731)
+	c := Config{
+		Population: 2000,
+		Seed:       42,
+		Schedule: []ndf.AddressSpace{
+			{Size: 8, Timestamp: start.Add(-time.Hour)},
+			{Size: 16, Timestamp: start.Add(12 * time.Hour)},
+		},
+		Start:  start,
+		Window: 24 * time.Hour,
+		Step:   6 * time.Hour,
+	}
+
+	report, err := Run(c)
+	if err != nil {
+		t.Fatalf("Run returned an error: %+v", err)
+	}
+	if len(report.Samples) != 4 {
+		t.Fatalf("Expected 4 samples, received %d.", len(report.Samples))
+	}
+
+	var reportTotal int
+	for i, s := range report.Samples {
+		expectedSize := uint(8)
+		if i >= 2 {
+			expectedSize = 16
+		}
+		if s.Size != expectedSize {
+			t.Errorf("Sample %d has size %d, expected %d.", i, s.Size, expectedSize)
+		}
+		if !s.Time.Equal(start.Add(time.Duration(i) * c.Step)) {
+			t.Errorf("Sample %d has unexpected time %s.", i, s.Time)
+		}
+
+		// Every identity is in exactly one set and each set of size k holds k
+		// identities
+		var identities, distinct, colliding int
+		for setSize, count := range s.Distribution {
+			identities += count
+			distinct += count / setSize
+			if setSize > 1 {
+				colliding += count
+			}
+		}
+		if identities != c.Population || distinct != s.DistinctIds ||
+			colliding != s.Colliding {
+			t.Errorf("Sample %d is inconsistent: %d identities, %d distinct, "+
+				"%d colliding: %+v", i, identities, distinct, colliding, s)
+		}
+		reportTotal += identities
+
+		if math.Abs(float64(s.DistinctIds)-s.Expected.DistinctIds) >
+			0.05*s.Expected.DistinctIds {
+			t.Errorf("Sample %d has %d distinct IDs, expected about %.1f.",
+				i, s.DistinctIds, s.Expected.DistinctIds)
+		}
+		if math.Abs(s.MeanSet-s.Expected.MeanSet) > 0.1*s.Expected.MeanSet {
+			t.Errorf("Sample %d has a mean set of %.2f, expected about %.2f.",
+				i, s.MeanSet, s.Expected.MeanSet)
+		}
+	}
+
+	var distributionTotal int
+	for _, setSize := range SetSizes(report.Distribution) {
+		distributionTotal += report.Distribution[setSize]
+	}
+	if distributionTotal != reportTotal {
+		t.Errorf("Report distribution has %d entries, expected %d.",
+			distributionTotal, reportTotal)
+	}
+	if report.MinSet != 1 {
+		t.Errorf("Expected some identity to be alone with size 16.")
+	}
+}
+
+// Tests that Run uses the sample IDs and that all identities share the only ID
+// available with a size of 1.
+func TestRun_Sample(t *testing.T) {
+	start := time.Unix(0, 1614199942358373731)
+	sample := make([]*id.ID, 3)
+	for i := range sample {
+		sample[i] = id.NewIdFromString(strconv.Itoa(i), id.User, t)
+	}
+
+	report, err := Run(Config{
+		Sample:     sample,
+		Population: 100,
+		Schedule:   []ndf.AddressSpace{{Size: 1, Timestamp: start}},
+		Start:      start,
+		Window:     time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Run returned an error: %+v", err)
+	}
+
+	s := report.Samples[0]
+	if len(report.Samples) != 1 || s.Population != 3 || s.DistinctIds != 1 ||
+		s.Colliding != 3 || s.MinSet != 3 || s.MaxSet != 3 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if s.Expected != (Expectation{DistinctIds: 1, Colliding: 3, MeanSet: 3}) {
+		t.Errorf("Unexpected expectation: %+v", s.Expected)
+	}
+}
+
+// Tests Expected against values computed by hand.
+func TestExpected(t *testing.T) {
+	tests := []struct {
+		population int
+		size       uint
+		expected   Expectation
+	}{
+		{0, 16, Expectation{}},
+		{1, 1, Expectation{DistinctIds: 1, MeanSet: 1}},
+		{1, 16, Expectation{DistinctIds: 1, MeanSet: 1}},
+		// Two identities among 3 IDs: both alone with probability 2/3
+		{2, 2, Expectation{DistinctIds: 5.0 / 3, Colliding: 2.0 / 3,
+			MeanSet: 4.0 / 3}},
+	}
+
+	for i, tt := range tests {
+		e := Expected(tt.population, tt.size)
+		if math.Abs(e.DistinctIds-tt.expected.DistinctIds) > 1e-9 ||
+			math.Abs(e.Colliding-tt.expected.Colliding) > 1e-9 ||
+			math.Abs(e.MeanSet-tt.expected.MeanSet) > 1e-9 {
+			t.Errorf("Unexpected expectation (%d).\nexpected: %+v\nreceived: %+v",
+				i, tt.expected, e)
+		}
+	}
+
+	// With 64 bits, collisions are negligible but not zero
+	if e := Expected(1_000_000, 64); e.Colliding <= 0 || e.Colliding > 1e-6 {
+		t.Errorf("Unexpected collisions for 64 bits: %+v", e)
+	}
+}
+
+// Tests that Expected excludes the IDs reserved by the scheme, which do not
+// change when the deprecated ephemeral.ReservedIDs is modified.
+func TestExpected_Reserved(t *testing.T) {
+	original := ephemeral.ReservedIDs[0]
+	ephemeral.ReservedIDs[0][0] = 0xFF
+	defer func() { ephemeral.ReservedIDs[0] = original }()
+
+	// Of the IDs 0 and 1, only 0 is reserved
+	e := Expected(1, 1)
+	if e.DistinctIds != 1 || e.MeanSet != 1 || e.Colliding != 0 {
+		t.Errorf("Unexpected expectation: %+v", e)
+	}
+	e = Expected(2, 1)
+	if e.DistinctIds != 1 || e.MeanSet != 2 || e.Colliding != 2 {
+		t.Errorf("Unexpected expectation: %+v", e)
+	}
+}
+
+// Error path: Tests that Run rejects invalid configs.
+func TestRun_Error(t *testing.T) {
+	start := time.Unix(0, 1614199942358373731)
+	valid := Config{
+		Population: 10,
+		Schedule:   []ndf.AddressSpace{{Size: 8, Timestamp: start}},
+		Start:      start,
+		Window:     time.Hour,
+	}
+
+	invalid := []func(c *Config){
+		func(c *Config) { c.Population = 0 },
+		func(c *Config) { c.Window = 0 },
+		func(c *Config) { c.Step = -time.Hour },
+		func(c *Config) { c.Schedule = nil },
+		func(c *Config) { c.Start = start.Add(-time.Second) },
+		func(c *Config) { c.Schedule[0].Size = 0 },
+	}
+
+	if _, err := Run(valid); err != nil {
+		t.Fatalf("Run returned an error for a valid config: %+v", err)
+	}
+	for i, modify := range invalid {
+		c := valid
+		c.Schedule = append([]ndf.AddressSpace{}, valid.Schedule...)
+		modify(&c)
+		if _, err := Run(c); err == nil {
+			t.Errorf("No error for invalid config %d.", i)
+		}
+	}
+}
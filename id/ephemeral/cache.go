@@ -0,0 +1,185 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ephemeral
+
+import (
+	"container/list"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Cache is a bounded, least recently used cache of ephemeral IDs keyed by
+// intermediary ID, size, and rotation period. IDs can be precomputed before
+// their period starts so that lookups at rotation time are hits. It is safe for
+// concurrent use.
+type Cache struct {
+	capacity int
+	entries  map[cacheKey]*list.Element
+	order    *list.List // Front is most recently used
+	stats    CacheStats
+	mux      sync.Mutex
+}
+
+// CacheStats contains the metrics of a Cache.
+type CacheStats struct {
+	// Hits is the number of lookups answered from the cache.
+	Hits uint64
+
+	// Misses is the number of lookups that had to compute the ID.
+	Misses uint64
+
+	// Precomputed is the number of IDs added by Cache.Precompute.
+	Precomputed uint64
+
+	// Evictions is the number of IDs removed to make room for new ones.
+	Evictions uint64
+
+	// Entries is the number of IDs currently in the cache.
+	Entries int
+}
+
+// cacheKey identifies an ephemeral ID. The period is the salt number of the
+// rotation period.
+type cacheKey struct {
+	iid    string
+	size   uint
+	period uint64
+}
+
+// cacheEntry is the value stored in the list elements of the cache.
+type cacheEntry struct {
+	key      cacheKey
+	identity ProtoIdentity
+}
+
+// NewCache returns an empty cache that holds up to capacity IDs. A capacity
+// below 1 is treated as 1.
+func NewCache(capacity int) *Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache{
+		capacity: capacity,
+		entries:  make(map[cacheKey]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// Get returns the ephemeral ID of the intermediary ID with the given size in
+// bits for the rotation period containing the timestamp. It returns the same
+// result as GetIdFromIntermediary.
+func (c *Cache) Get(iid []byte, size uint, timestamp time.Time) (
+	ProtoIdentity, error) {
+	if size > MaxSize || size < MinSize {
+		return ProtoIdentity{}, errors.Errorf("Cannot generate ID, size must "+
+			"be between %d and %d", MinSize, MaxSize)
+	}
+
+	start, end, saltNum := GetOffsetBounds(GetOffset(iid), timestamp.UnixNano())
+	key := cacheKey{iid: string(iid), size: size, period: saltNum}
+
+	c.mux.Lock()
+	if elem, exists := c.entries[key]; exists {
+		c.order.MoveToFront(elem)
+		c.stats.Hits++
+		identity := elem.Value.(*cacheEntry).identity
+		c.mux.Unlock()
+		return identity, nil
+	}
+	c.stats.Misses++
+	c.mux.Unlock()
+
+	// Compute the ID without holding the lock
+	salt := make([]byte, 8)
+	binary.BigEndian.PutUint64(salt, saltNum)
+	eid, err := v1.unreservedId(v1.Hash.New(), iid, salt, size)
+	if err != nil {
+		return ProtoIdentity{}, err
+	}
+	identity := ProtoIdentity{Id: eid, Start: start, End: end}
+
+	c.mux.Lock()
+	c.add(key, identity)
+	c.mux.Unlock()
+
+	return identity, nil
+}
+
+// Precompute adds the ephemeral IDs of the intermediary ID for the period
+// containing now and the following periods, for a total of periods IDs. IDs
+// already in the cache are not recomputed. Precomputed IDs do not count as hits
+// or misses.
+func (c *Cache) Precompute(
+	iid []byte, size uint, now time.Time, periods int) error {
+	it, err := NewIteratorFromIntermediary(iid, size, now)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < periods; i++ {
+		_, _, saltNum := it.scheme.offsetBounds(it.offset, it.timestamp)
+		key := cacheKey{iid: string(iid), size: size, period: saltNum}
+
+		c.mux.Lock()
+		_, exists := c.entries[key]
+		c.mux.Unlock()
+		if exists {
+			it.Skip()
+			continue
+		}
+
+		identity, err := it.Next()
+		if err != nil {
+			return err
+		}
+
+		c.mux.Lock()
+		c.add(key, identity)
+		c.stats.Precomputed++
+		c.mux.Unlock()
+	}
+
+	return nil
+}
+
+// Stats returns the current metrics of the cache.
+func (c *Cache) Stats() CacheStats {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+// Len returns the number of IDs in the cache.
+func (c *Cache) Len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.entries)
+}
+
+// add inserts the ID as the most recently used entry, evicting the least
+// recently used entry if the cache is full. The caller must hold the lock.
+func (c *Cache) add(key cacheKey, identity ProtoIdentity) {
+	if elem, exists := c.entries[key]; exists {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	if len(c.entries) >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, identity: identity})
+}
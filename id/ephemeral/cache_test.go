@@ -0,0 +1,149 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ephemeral
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// newTestIntermediaryIds returns the intermediary IDs of n test user IDs.
+func newTestIntermediaryIds(t *testing.T, n int) [][]byte {
+	iids := make([][]byte, n)
+	for i := range iids {
+		iid, err := GetIntermediaryId(
+			id.NewIdFromString(strconv.Itoa(i), id.User, t))
+		if err != nil {
+			t.Fatalf("Failed to get intermediary ID: %+v", err)
+		}
+		iids[i] = iid
+	}
+	return iids
+}
+
+// Tests that Cache.Get returns the same IDs as GetIdFromIntermediary and
+// counts hits and misses.
+func TestCache_Get(t *testing.T) {
+	c := NewCache(10)
+	iid := newTestIntermediaryIds(t, 1)[0]
+	now := time.Unix(0, 1614199942358373731)
+
+	for i := 0; i < 3; i++ {
+		identity, err := c.Get(iid, 16, now)
+		if err != nil {
+			t.Fatalf("Get returned an error: %+v", err)
+		}
+
+		eid, start, end, _ := GetIdFromIntermediary(iid, 16, now.UnixNano())
+		if identity.Id != eid || !identity.Start.Equal(start) ||
+			!identity.End.Equal(end) {
+			t.Errorf("Unexpected identity: %+v", identity)
+		}
+	}
+
+	// A different size is a different entry
+	if _, err := c.Get(iid, 8, now); err != nil {
+		t.Fatalf("Get returned an error: %+v", err)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if _, err := c.Get(iid, 0, now); err == nil {
+		t.Errorf("No error for invalid size.")
+	}
+}
+
+// Tests that IDs precomputed for upcoming periods are hits when their period
+// starts.
+func TestCache_Precompute(t *testing.T) {
+	c := NewCache(100)
+	iids := newTestIntermediaryIds(t, 5)
+	now := time.Unix(0, 1614199942358373731)
+
+	for _, iid := range iids {
+		if err := c.Precompute(iid, 16, now, 3); err != nil {
+			t.Fatalf("Precompute returned an error: %+v", err)
+		}
+	}
+	if err := c.Precompute(iids[0], 16, now, 3); err != nil {
+		t.Fatalf("Precompute returned an error: %+v", err)
+	}
+
+	for _, iid := range iids {
+		for p := int64(0); p < 3; p++ {
+			timestamp := now.Add(time.Duration(p * Period))
+			identity, err := c.Get(iid, 16, timestamp)
+			if err != nil {
+				t.Fatalf("Get returned an error: %+v", err)
+			}
+			eid, _, _, _ := GetIdFromIntermediary(iid, 16, timestamp.UnixNano())
+			if identity.Id != eid {
+				t.Errorf("Precomputed ID does not match.")
+			}
+		}
+	}
+
+	stats := c.Stats()
+	if stats.Precomputed != 15 || stats.Hits != 15 || stats.Misses != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+// Tests that the least recently used entry is evicted when the cache is full.
+func TestCache_Eviction(t *testing.T) {
+	c := NewCache(2)
+	iids := newTestIntermediaryIds(t, 3)
+	now := time.Now()
+
+	_, _ = c.Get(iids[0], 16, now)
+	_, _ = c.Get(iids[1], 16, now)
+	_, _ = c.Get(iids[0], 16, now) // iids[1] is now least recently used
+	_, _ = c.Get(iids[2], 16, now)
+
+	if c.Len() != 2 || c.Stats().Evictions != 1 {
+		t.Errorf("Unexpected stats: %+v", c.Stats())
+	}
+
+	_, _ = c.Get(iids[0], 16, now)
+	_, _ = c.Get(iids[1], 16, now)
+	if stats := c.Stats(); stats.Hits != 2 || stats.Misses != 4 {
+		t.Errorf("Unexpected entry evicted: %+v", stats)
+	}
+}
+
+// Tests that Cache can be used from multiple goroutines.
+func TestCache_Concurrent(t *testing.T) {
+	c := NewCache(16)
+	iids := newTestIntermediaryIds(t, 32)
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, iid := range iids {
+				if _, err := c.Get(iid, 16, now); err != nil {
+					t.Errorf("Get returned an error: %+v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := c.Stats(); stats.Hits+stats.Misses != 128 || stats.Entries != 16 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
@@ -8,8 +8,6 @@
 package ephemeral
 
 import (
-	"crypto"
-	"crypto/hmac"
 	"encoding/binary"
 	"encoding/json"
 	"hash"
@@ -29,14 +27,18 @@ const (
 	NumOffsets  int64 = 1 << 16
 	NsPerOffset       = Period / NumOffsets
 
-	// Minimum and maximum size for new Id.
-	maxSize = 64
-	minSize = 1
+	// MaxSize and MinSize are the maximum and minimum size, in bits, of a new
+	// Id.
+	MaxSize = 64
+	MinSize = 1
 )
 
 // ReservedIDs are ephemeral IDs reserved for specific actions:
 //   - All zeros denote a dummy ID
 //   - All ones denote a payment
+//
+// Deprecated: use V1().Reserved. Modifying ReservedIDs does not change the IDs
+// reserved by the package.
 var ReservedIDs = []Id{
 	{0, 0, 0, 0, 0, 0, 0, 0}, // Dummy ID
 	{1, 1, 1, 1, 1, 1, 1, 1}, // Payment
@@ -107,9 +109,9 @@ func Marshal(data []byte) (Id, error) {
 func GetIdsByRange(id *id.ID, size uint, timestamp time.Time,
 	timeRange time.Duration) ([]ProtoIdentity, error) {
 
-	if size > maxSize {
+	if size > MaxSize {
 		return []ProtoIdentity{},
-			errors.Errorf("Cannot generate ID with size > %d", maxSize)
+			errors.Errorf("Cannot generate ID with size > %d", MaxSize)
 	}
 
 	iid, err := GetIntermediaryId(id)
@@ -117,25 +119,23 @@ func GetIdsByRange(id *id.ID, size uint, timestamp time.Time,
 		return []ProtoIdentity{}, err
 	}
 
-	var idList []ProtoIdentity
 	timeStop := timestamp.Add(timeRange)
+	if !timeStop.After(timestamp) {
+		return nil, nil
+	}
 
-	for timeStop.After(timestamp) {
+	it, err := NewIteratorFromIntermediary(iid, size, timestamp)
+	if err != nil {
+		return []ProtoIdentity{}, err
+	}
 
-		newId, start, end, err :=
-			GetIdFromIntermediary(iid, size, timestamp.UnixNano())
+	idList := make([]ProtoIdentity, 0, int64(timeRange)/Period+1)
+	for it.Timestamp().Before(timeStop) {
+		identity, err := it.Next()
 		if err != nil {
 			return []ProtoIdentity{}, err
 		}
-
-		idList = append(idList, ProtoIdentity{
-			Id:    newId,
-			Start: start,
-			End:   end,
-		})
-
-		// Make the timestamp into the next Period
-		timestamp = end.Add(time.Nanosecond)
+		idList = append(idList, identity)
 	}
 	return idList, nil
 }
@@ -154,13 +154,7 @@ func GetId(id *id.ID, size uint, timestamp int64) (Id, time.Time, time.Time, err
 // GetIntermediaryId returns an intermediary ID for the ephemeral ID creation
 // (ID hash).
 func GetIntermediaryId(id *id.ID) ([]byte, error) {
-	b2b := crypto.BLAKE2b_256.New()
-	_, err := b2b.Write(id.Marshal())
-	if err != nil {
-		return nil, err
-	}
-	idHash := b2b.Sum(nil)
-	return idHash, nil
+	return v1.IntermediaryId(id)
 }
 
 // GetIdFromIntermediary returns the ephemeral ID from intermediary (ID hash).
@@ -169,24 +163,7 @@ func GetIntermediaryId(id *id.ID) ([]byte, error) {
 // Returns an ephemeral ID and the start and end timestamps for salt window.
 func GetIdFromIntermediary(iid []byte, size uint, timestamp int64) (
 	Id, time.Time, time.Time, error) {
-	b2b := crypto.BLAKE2b_256.New()
-	if size > maxSize || size < minSize {
-		return Id{}, time.Time{}, time.Time{}, errors.Errorf("Cannot generate "+
-			"ID, size must be between %d and %d", minSize, maxSize)
-	}
-	salt, start, end := getRotationSalt(iid, timestamp)
-
-	// Continually generate an ephemeral ID until we land on an ID not within
-	// the reserved list of IDs
-	var eid Id
-	var err error
-	for reserved := true; reserved; reserved = IsReserved(eid) {
-		eid, err = getIdFromIntermediary(b2b, iid, salt, size)
-		if err != nil {
-			return Id{}, start, end, err
-		}
-	}
-	return eid, start, end, nil
+	return v1.IdFromIntermediary(iid, size, timestamp)
 }
 
 // getIdFromIntermediary generates an ephemeral Id from an intermediary ID and
@@ -215,27 +192,16 @@ func getIdFromIntermediary(
 // IsReserved checks if the Id is among the reserved global reserved ID list.
 // Returns true if reserved, false if non-reserved.
 func IsReserved(eid Id) bool {
-	for _, r := range ReservedIDs {
-		if hmac.Equal(eid[:], r[:]) {
-			return true
-		}
-	}
-	return false
+	return v1.IsReserved(eid)
 }
 
 // getRotationSalt returns rotation salt based on ID hash and timestamp.
 func getRotationSalt(idHash []byte, timestamp int64) ([]byte, time.Time, time.Time) {
-	offset := GetOffset(idHash)
-	start, end, saltNum := GetOffsetBounds(offset, timestamp)
-	salt := make([]byte, 8)
-	binary.BigEndian.PutUint64(salt, saltNum)
-	return salt, start, end
+	return v1.rotationSalt(idHash, timestamp)
 }
 
 func GetOffset(intermediaryId []byte) int64 {
-	hashNum := binary.BigEndian.Uint64(intermediaryId)
-	offset := int64((hashNum % uint64(NumOffsets)) * uint64(NsPerOffset))
-	return offset
+	return v1.offset(intermediaryId)
 }
 
 func GetOffsetNum(offset int64) int64 {
@@ -243,20 +209,7 @@ func GetOffsetNum(offset int64) int64 {
 }
 
 func GetOffsetBounds(offset, timestamp int64) (time.Time, time.Time, uint64) {
-	timestampPhase := timestamp % Period
-	var start, end int64
-	timestampNum := timestamp / Period
-	var saltNum uint64
-	if timestampPhase < offset {
-		start = (timestampNum-1)*Period + offset
-		end = start + Period
-		saltNum = uint64((timestamp - Period) / Period)
-	} else {
-		start = timestampNum*Period + offset
-		end = start + Period
-		saltNum = uint64(timestamp / Period)
-	}
-	return time.Unix(0, start), time.Unix(0, end), saltNum
+	return v1.offsetBounds(offset, timestamp)
 }
 
 func HandleQuantization(start time.Time) (int64, int32) {
@@ -18,8 +18,6 @@ import (
 	"testing"
 	"time"
 
-	_ "golang.org/x/crypto/blake2b"
-
 	"gitlab.com/xx_network/primitives/id"
 )
 
@@ -138,7 +136,7 @@ func TestGetIdFromIntermediary_Reserved(t *testing.T) {
 	if IsReserved(eid) {
 		t.Errorf("Ephemeral ID generated should not be reserved!"+
 			"\nReserved IDs: %v"+
-			"\nGenerated ID: %v", ReservedIDs, eid)
+			"\nGenerated ID: %v", V1().Reserved, eid)
 	}
 
 }
@@ -0,0 +1,387 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ephemeral
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/netTime"
+)
+
+// Error messages.
+const (
+	// Index.Track
+	nilIndexIdErr = "cannot track nil ID"
+	trackSizeErr  = "cannot track ID %s: size must be between %d and %d"
+	trackIdErr    = "cannot track ID %s with size %d: %+v"
+)
+
+// indexWindows is the number of rotation periods indexed for each identity: the
+// previous, current, and next period.
+const indexWindows = 3
+
+// Index maps ephemeral IDs to the tracked identities that they belong to. For
+// each identity, the IDs of the previous, current, and next rotation period are
+// indexed so that messages sent near a rotation boundary are still found. The
+// windows of each identity are moved forward at its own rotation boundary,
+// which depends on its offset (see GetOffset).
+//
+// Because an ephemeral ID of a small size only holds a few bits, several
+// identities can share the same ID; all of them are returned on lookup. An
+// Index is safe for concurrent use.
+type Index struct {
+	identities map[indexKey]*indexEntry
+	ids        map[sizedId][]*indexEntry
+	sizes      map[uint]int // Number of tracked identities of each size
+
+	// Entries ordered by the end of their current rotation period so that
+	// only the identities that are due are rotated
+	rotations rotationHeap
+
+	now  netTime.NowFunc
+	wake chan struct{}
+	mux  sync.Mutex
+}
+
+// IndexMatch is a tracked identity that an ephemeral ID belongs to, with the
+// rotation period in which the identity used the ID.
+type IndexMatch struct {
+	ID   *id.ID
+	Size uint
+	ProtoIdentity
+}
+
+// indexKey identifies a tracked identity. The same ID can be tracked with
+// several sizes.
+type indexKey struct {
+	id   id.ID
+	size uint
+}
+
+// sizedId is an ephemeral ID cleared to the given size.
+type sizedId struct {
+	eid  Id
+	size uint
+}
+
+// indexEntry contains the indexed rotation periods of a tracked identity.
+type indexEntry struct {
+	key     indexKey
+	iid     []byte
+	windows [indexWindows]ProtoIdentity
+	index   int // Position in the rotation heap or -1 if not in it
+}
+
+// NewIndex returns an empty Index that uses netTime.Now as its clock.
+func NewIndex() *Index {
+	return &Index{
+		identities: make(map[indexKey]*indexEntry),
+		ids:        make(map[sizedId][]*indexEntry),
+		sizes:      make(map[uint]int),
+		now:        netTime.Now,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Track adds the ID with the given size in bits to the index. Tracking an ID
+// that is already tracked with the same size does nothing.
+func (idx *Index) Track(trackedId *id.ID, size uint) error {
+	if trackedId == nil {
+		return errors.New(nilIndexIdErr)
+	}
+	if size > MaxSize || size < MinSize {
+		return errors.Errorf(trackSizeErr, trackedId, MinSize, MaxSize)
+	}
+
+	key := indexKey{id: *trackedId, size: size}
+	iid, err := GetIntermediaryId(trackedId)
+	if err != nil {
+		return errors.Errorf(trackIdErr, trackedId, size, err)
+	}
+	entry := &indexEntry{key: key, iid: iid}
+
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	if _, exists := idx.identities[key]; exists {
+		return nil
+	}
+
+	if err = entry.setWindows(idx.now()); err != nil {
+		return errors.Errorf(trackIdErr, trackedId, size, err)
+	}
+
+	idx.identities[key] = entry
+	idx.sizes[size]++
+	idx.addIds(entry)
+	heap.Push(&idx.rotations, entry)
+
+	// Wake the update thread in case this identity rotates first
+	select {
+	case idx.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Untrack removes the ID with the given size from the index. It returns false
+// if the ID was not tracked with that size.
+func (idx *Index) Untrack(trackedId *id.ID, size uint) bool {
+	key := indexKey{id: *trackedId, size: size}
+
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	entry, exists := idx.identities[key]
+	if !exists {
+		return false
+	}
+
+	idx.removeIds(entry)
+	if entry.index >= 0 {
+		heap.Remove(&idx.rotations, entry.index)
+	}
+	idx.deleteEntry(entry)
+
+	return true
+}
+
+// Lookup returns all tracked identities that use the ephemeral ID in one of
+// their indexed rotation periods. The ID may have been filled with random bits
+// above its size (see Id.Fill); it is cleared to the size of each identity
+// before comparing. The matches are sorted by ID, size, and period start.
+func (idx *Index) Lookup(eid Id) []IndexMatch {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+
+	idx.update(idx.now())
+
+	var matches []IndexMatch
+	for size := range idx.sizes {
+		cleared := eid.Clear(size)
+		for _, entry := range idx.ids[sizedId{cleared, size}] {
+			for _, window := range entry.windows {
+				if window.Id == cleared {
+					matches = append(matches, IndexMatch{
+						ID:            entry.key.id.DeepCopy(),
+						Size:          size,
+						ProtoIdentity: window,
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if c := matches[i].ID.Compare(matches[j].ID); c != 0 {
+			return c < 0
+		}
+		if matches[i].Size != matches[j].Size {
+			return matches[i].Size < matches[j].Size
+		}
+		return matches[i].Start.Before(matches[j].Start)
+	})
+
+	return matches
+}
+
+// Len returns the number of tracked identities.
+func (idx *Index) Len() int {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	return len(idx.identities)
+}
+
+// Update moves the windows of every identity whose current rotation period
+// ended at or before now. Lookup calls this automatically, so it only needs to be
+// called directly to control when the work is done.
+func (idx *Index) Update(now time.Time) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	idx.update(now)
+}
+
+// NextRotation returns the earliest time at which the current rotation period
+// of a tracked identity ends. It returns false if no identities are tracked.
+func (idx *Index) NextRotation() (time.Time, bool) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	return idx.nextRotation()
+}
+
+// Start updates the index at each rotation boundary until the quit channel is
+// closed or receives a value. This function is meant to be run in its own
+// thread.
+func (idx *Index) Start(quit <-chan struct{}) {
+	jww.DEBUG.Printf("Starting ephemeral ID index updates.")
+
+	for {
+		// With no tracked identities, the nil channel blocks until woken
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if next, exists := idx.NextRotation(); exists {
+			timer = time.NewTimer(next.Sub(idx.now()))
+			timerC = timer.C
+		}
+
+		select {
+		case <-timerC:
+			idx.Update(idx.now())
+		case <-idx.wake:
+		case <-quit:
+			jww.DEBUG.Printf("Stopping ephemeral ID index updates.")
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// update moves the windows of every identity whose current rotation period
+// ended at or before now. Only the identities that are due are visited. An
+// identity whose windows cannot be computed is removed from the index. The
+// caller must hold the lock.
+func (idx *Index) update(now time.Time) {
+	for len(idx.rotations) > 0 &&
+		!now.Before(idx.rotations[0].windows[1].End) {
+		entry := heap.Pop(&idx.rotations).(*indexEntry)
+
+		idx.removeIds(entry)
+		if err := entry.setWindows(now); err != nil {
+			jww.ERROR.Printf("Failed to update ephemeral IDs of %s with "+
+				"size %d; it is no longer tracked: %+v",
+				&entry.key.id, entry.key.size, err)
+			idx.deleteEntry(entry)
+			continue
+		}
+		heap.Push(&idx.rotations, entry)
+		idx.addIds(entry)
+	}
+}
+
+// nextRotation returns the earliest end of a current rotation period. The
+// caller must hold the lock.
+func (idx *Index) nextRotation() (time.Time, bool) {
+	if len(idx.rotations) == 0 {
+		return time.Time{}, false
+	}
+	return idx.rotations[0].windows[1].End, true
+}
+
+// deleteEntry removes the entry from the tracked identities. Its IDs must
+// already be removed and it must not be in the rotation heap. The caller must
+// hold the lock.
+func (idx *Index) deleteEntry(entry *indexEntry) {
+	delete(idx.identities, entry.key)
+	if idx.sizes[entry.key.size]--; idx.sizes[entry.key.size] == 0 {
+		delete(idx.sizes, entry.key.size)
+	}
+}
+
+// addIds adds the windows of the entry to the ID map. The caller must hold the
+// lock.
+func (idx *Index) addIds(entry *indexEntry) {
+	for _, window := range entry.windows {
+		key := sizedId{window.Id, entry.key.size}
+		if !containsEntry(idx.ids[key], entry) {
+			idx.ids[key] = append(idx.ids[key], entry)
+		}
+	}
+}
+
+// removeIds removes the windows of the entry from the ID map. The caller must
+// hold the lock.
+func (idx *Index) removeIds(entry *indexEntry) {
+	for _, window := range entry.windows {
+		key := sizedId{window.Id, entry.key.size}
+		entries := idx.ids[key]
+		for i := range entries {
+			if entries[i] == entry {
+				entries = append(entries[:i], entries[i+1:]...)
+				break
+			}
+		}
+
+		if len(entries) == 0 {
+			delete(idx.ids, key)
+		} else {
+			idx.ids[key] = entries
+		}
+	}
+}
+
+// setWindows computes the IDs of the rotation periods before, containing, and
+// after now.
+func (entry *indexEntry) setWindows(now time.Time) error {
+	it, err := NewIteratorFromIntermediary(
+		entry.iid, entry.key.size, now.Add(-time.Duration(Period)))
+	if err != nil {
+		return err
+	}
+
+	for i := range entry.windows {
+		if entry.windows[i], err = it.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotationHeap is a min-heap of index entries ordered by the end of their
+// current rotation period. It implements heap.Interface.
+type rotationHeap []*indexEntry
+
+func (h rotationHeap) Len() int { return len(h) }
+
+func (h rotationHeap) Less(i, j int) bool {
+	return h[i].windows[1].End.Before(h[j].windows[1].End)
+}
+
+func (h rotationHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *rotationHeap) Push(x interface{}) {
+	entry := x.(*indexEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *rotationHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	entry.index = -1
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// containsEntry returns true if the entry is in the list.
+func containsEntry(entries []*indexEntry, entry *indexEntry) bool {
+	for _, e := range entries {
+		if e == entry {
+			return true
+		}
+	}
+	return false
+}
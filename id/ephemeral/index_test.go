@@ -0,0 +1,349 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ephemeral
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// newTestIndex returns an Index with a clock fixed at the returned time
+// pointer.
+func newTestIndex(now time.Time) (*Index, *time.Time) {
+	idx := NewIndex()
+	clock := now
+	idx.now = func() time.Time { return clock }
+	return idx, &clock
+}
+
+// Tests that the ephemeral IDs of the previous, current, and next periods of
+// each tracked identity are found by Index.Lookup.
+func TestIndex_Lookup(t *testing.T) {
+	now := time.Unix(0, 1614199942358373731)
+	idx, _ := newTestIndex(now)
+
+	ids := make([]*id.ID, 10)
+	for i := range ids {
+		ids[i] = id.NewIdFromString(strconv.Itoa(i), id.User, t)
+		if err := idx.Track(ids[i], 32); err != nil {
+			t.Fatalf("Failed to track ID %d: %+v", i, err)
+		}
+	}
+	if idx.Len() != len(ids) {
+		t.Errorf("Unexpected length %d.", idx.Len())
+	}
+
+	for i, trackedId := range ids {
+		for _, p := range []int64{-1, 0, 1} {
+			timestamp := now.Add(time.Duration(p * Period))
+			eid, start, _, _ := GetId(trackedId, 32, timestamp.UnixNano())
+
+			matches := idx.Lookup(eid)
+			if len(matches) != 1 {
+				t.Fatalf("Expected 1 match for ID %d in period %d, "+
+					"received %d.", i, p, len(matches))
+			}
+			if !matches[0].ID.Equal(trackedId) || matches[0].Size != 32 ||
+				!matches[0].Start.Equal(start) {
+				t.Errorf("Unexpected match for ID %d in period %d: %+v",
+					i, p, matches[0])
+			}
+		}
+
+		eid, _, _, _ := GetId(trackedId, 32, now.Add(
+			time.Duration(2*Period)).UnixNano())
+		if matches := idx.Lookup(eid); len(matches) != 0 {
+			t.Errorf("Unexpected match for ID %d two periods ahead: %+v",
+				i, matches)
+		}
+	}
+}
+
+// Tests that Index.Lookup finds an ID filled with random bits above its size.
+func TestIndex_Lookup_Filled(t *testing.T) {
+	now := time.Unix(0, 1614199942358373731)
+	idx, _ := newTestIndex(now)
+	trackedId := id.NewIdFromString("zezima", id.User, t)
+	if err := idx.Track(trackedId, 16); err != nil {
+		t.Fatalf("Failed to track ID: %+v", err)
+	}
+
+	eid, _, _, _ := GetId(trackedId, 16, now.UnixNano())
+	filled, err := eid.Fill(16, rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatalf("Failed to fill ID: %+v", err)
+	}
+	if filled == eid {
+		t.Fatalf("Filled ID should differ from the original.")
+	}
+
+	if matches := idx.Lookup(filled); len(matches) != 1 ||
+		!matches[0].ID.Equal(trackedId) {
+		t.Errorf("Unexpected matches for filled ID: %+v", matches)
+	}
+}
+
+// Tests that all identities sharing an ephemeral ID, because of a small size,
+// are returned by Index.Lookup.
+func TestIndex_Lookup_Collisions(t *testing.T) {
+	now := time.Unix(0, 1614199942358373731)
+	idx, _ := newTestIndex(now)
+
+	for i := 0; i < 20; i++ {
+		trackedId := id.NewIdFromString(strconv.Itoa(i), id.User, t)
+		if err := idx.Track(trackedId, 1); err != nil {
+			t.Fatalf("Failed to track ID %d: %+v", i, err)
+		}
+	}
+
+	// With a size of 1, the only unreserved ID is 1
+	var eid Id
+	eid[IdLen-1] = 1
+	matches := idx.Lookup(eid)
+	if len(matches) != 20*indexWindows {
+		t.Errorf("Expected %d matches, received %d.",
+			20*indexWindows, len(matches))
+	}
+
+	for i := 1; i < len(matches); i++ {
+		if matches[i-1].ID.Equal(matches[i].ID) &&
+			!matches[i-1].Start.Before(matches[i].Start) {
+			t.Errorf("Matches %d and %d are not sorted.", i-1, i)
+		}
+	}
+}
+
+// Tests that Index.Lookup moves the windows of an identity after its rotation
+// boundary.
+func TestIndex_Lookup_Rotation(t *testing.T) {
+	now := time.Unix(0, 1614199942358373731)
+	idx, clock := newTestIndex(now)
+	trackedId := id.NewIdFromString("zezima", id.User, t)
+	if err := idx.Track(trackedId, 32); err != nil {
+		t.Fatalf("Failed to track ID: %+v", err)
+	}
+
+	next, exists := idx.NextRotation()
+	_, _, end, _ := GetId(trackedId, 32, now.UnixNano())
+	if !exists || !next.Equal(end) {
+		t.Fatalf("Unexpected next rotation %s (%t), expected %s.",
+			next, exists, end)
+	}
+
+	prevEid, _, _, _ := GetId(trackedId, 32, now.Add(
+		-time.Duration(Period)).UnixNano())
+	aheadEid, _, _, _ := GetId(trackedId, 32, end.Add(
+		time.Duration(Period)).UnixNano())
+
+	*clock = end
+	if matches := idx.Lookup(aheadEid); len(matches) != 1 {
+		t.Errorf("ID of the new next period not found after rotation.")
+	}
+	if matches := idx.Lookup(prevEid); len(matches) != 0 {
+		t.Errorf("ID of the old previous period found after rotation.")
+	}
+	if next, _ = idx.NextRotation(); !next.Equal(end.Add(time.Duration(Period))) {
+		t.Errorf("Next rotation not moved forward: %s", next)
+	}
+}
+
+// Tests that Index.Update rotates exactly the identities that are due as the
+// clock moves across many rotation boundaries, including after identities are
+// untracked, and that NextRotation is always the earliest boundary.
+func TestIndex_Update_Rotations(t *testing.T) {
+	now := time.Unix(0, 1614199942358373731)
+	idx, clock := newTestIndex(now)
+	ids := make([]*id.ID, 50)
+	for i := range ids {
+		ids[i] = id.NewIdFromString(strconv.Itoa(i), id.User, t)
+		if err := idx.Track(ids[i], 16); err != nil {
+			t.Fatalf("Failed to track ID %d: %+v", i, err)
+		}
+	}
+
+	prng := rand.New(rand.NewSource(42))
+	for step := 0; step < 100; step++ {
+		*clock = clock.Add(time.Duration(prng.Int63n(Period / 10)))
+		if step == 50 {
+			for _, untracked := range ids[:10] {
+				idx.Untrack(untracked, 16)
+			}
+			ids = ids[10:]
+		}
+		idx.Update(*clock)
+
+		var earliest time.Time
+		for _, trackedId := range ids {
+			entry := idx.identities[indexKey{*trackedId, 16}]
+			_, start, end, _ := GetId(trackedId, 16, clock.UnixNano())
+			if !entry.windows[1].Start.Equal(start) {
+				t.Fatalf("Step %d: identity %s not rotated: %s != %s",
+					step, trackedId, entry.windows[1].Start, start)
+			}
+			if earliest.IsZero() || end.Before(earliest) {
+				earliest = end
+			}
+		}
+
+		if next, _ := idx.NextRotation(); !next.Equal(earliest) {
+			t.Fatalf("Step %d: unexpected next rotation %s, expected %s.",
+				step, next, earliest)
+		}
+	}
+}
+
+// Tests that Index.Untrack removes an identity and that the other identities
+// sharing its ephemeral ID are kept.
+func TestIndex_Untrack(t *testing.T) {
+	now := time.Unix(0, 1614199942358373731)
+	idx, _ := newTestIndex(now)
+	ids := []*id.ID{
+		id.NewIdFromString("a", id.User, t),
+		id.NewIdFromString("b", id.User, t),
+	}
+	for _, trackedId := range ids {
+		if err := idx.Track(trackedId, 1); err != nil {
+			t.Fatalf("Failed to track ID: %+v", err)
+		}
+	}
+
+	if !idx.Untrack(ids[0], 1) {
+		t.Errorf("Failed to untrack tracked ID.")
+	}
+	if idx.Untrack(ids[0], 1) || idx.Untrack(ids[1], 2) {
+		t.Errorf("Untracked an ID that is not tracked.")
+	}
+
+	eid, _, _, _ := GetId(ids[1], 1, now.UnixNano())
+	matches := idx.Lookup(eid)
+	if len(matches) != indexWindows || !matches[0].ID.Equal(ids[1]) {
+		t.Errorf("Unexpected matches after untracking: %+v", matches)
+	}
+	if idx.Len() != 1 {
+		t.Errorf("Unexpected length %d.", idx.Len())
+	}
+}
+
+// Error path: Tests that an identity whose windows cannot be computed when it
+// rotates is removed from the index as if it were untracked.
+func TestIndex_Update_Error(t *testing.T) {
+	now := time.Unix(0, 1614199942358373731)
+	idx, clock := newTestIndex(now)
+	broken := id.NewIdFromString("broken", id.User, t)
+	kept := id.NewIdFromString("kept", id.User, t)
+	for _, trackedId := range []*id.ID{broken, kept} {
+		if err := idx.Track(trackedId, 8); err != nil {
+			t.Fatalf("Failed to track ID: %+v", err)
+		}
+	}
+
+	brokenEid, _, _, _ := GetId(broken, 8, now.UnixNano())
+
+	// An intermediary ID that is too short makes setWindows fail
+	entry := idx.identities[indexKey{*broken, 8}]
+	entry.iid = nil
+
+	*clock = clock.Add(time.Duration(Period))
+	idx.Update(*clock)
+
+	if idx.Len() != 1 || idx.sizes[8] != 1 || len(idx.rotations) != 1 {
+		t.Errorf("Broken identity not removed: %d identities, %d of size "+
+			"8, %d rotating.", idx.Len(), idx.sizes[8], len(idx.rotations))
+	}
+	if _, exists := idx.identities[indexKey{*broken, 8}]; exists {
+		t.Errorf("Broken identity is still tracked.")
+	}
+	for key, entries := range idx.ids {
+		if containsEntry(entries, entry) {
+			t.Errorf("Broken identity is still indexed under %v.", key)
+		}
+	}
+	for _, match := range idx.Lookup(brokenEid) {
+		if match.ID.Equal(broken) {
+			t.Errorf("Lookup returned the broken identity: %+v", match)
+		}
+	}
+
+	keptEid, _, _, _ := GetId(kept, 8, clock.UnixNano())
+	if matches := idx.Lookup(keptEid); len(matches) == 0 {
+		t.Errorf("Lookup did not return the kept identity.")
+	}
+
+	// The identity can be tracked again
+	if err := idx.Track(broken, 8); err != nil || idx.Len() != 2 {
+		t.Errorf("Failed to track the removed identity again: %+v", err)
+	}
+}
+
+// Tests that Index.Start updates the index at the rotation boundary without a
+// lookup and stops when the quit channel is closed.
+func TestIndex_Start(t *testing.T) {
+	idx := NewIndex()
+	trackedId := id.NewIdFromString("zezima", id.User, t)
+	iid, _ := GetIntermediaryId(trackedId)
+
+	// Shift the clock so that the identity rotates shortly after starting
+	_, end, _ := GetOffsetBounds(GetOffset(iid), time.Now().UnixNano())
+	shift := end.Sub(time.Now()) - 200*time.Millisecond
+	idx.now = func() time.Time { return time.Now().Add(shift) }
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		idx.Start(quit)
+		close(done)
+	}()
+
+	if err := idx.Track(trackedId, 32); err != nil {
+		t.Fatalf("Failed to track ID: %+v", err)
+	}
+	first, _ := idx.NextRotation()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		idx.mux.Lock()
+		next, _ := idx.nextRotation()
+		idx.mux.Unlock()
+		if next.After(first) {
+			break
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("Index was not updated at the rotation boundary.")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	close(quit)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Start did not stop after quit was closed.")
+	}
+}
+
+// Error path: Tests that Index.Track rejects nil IDs and invalid sizes.
+func TestIndex_Track_Error(t *testing.T) {
+	idx := NewIndex()
+	if err := idx.Track(nil, 16); err == nil {
+		t.Errorf("No error for nil ID.")
+	}
+	for _, size := range []uint{0, MaxSize + 1} {
+		if err := idx.Track(id.NewIdFromString("a", id.User, t), size); err == nil {
+			t.Errorf("No error for size %d.", size)
+		}
+	}
+	if idx.Len() != 0 {
+		t.Errorf("Identity tracked after error.")
+	}
+}
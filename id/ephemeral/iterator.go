@@ -0,0 +1,119 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ephemeral
+
+import (
+	"encoding/binary"
+	"hash"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// Iterator streams the ephemeral IDs of an identity, one rotation period at a
+// time. The intermediary ID and the rotation offset are computed once and the
+// hash is reused, so iterating is cheaper than calling GetIdFromIntermediary
+// for each period. An Iterator is not safe for concurrent use.
+type Iterator struct {
+	scheme    Scheme
+	iid       []byte
+	size      uint
+	offset    int64
+	timestamp int64
+	b2b       hash.Hash
+	salt      []byte
+}
+
+// NewIterator returns an iterator over the ephemeral IDs of the ID with the
+// given size in bits, starting with the ID in effect at the start time.
+func NewIterator(id *id.ID, size uint, start time.Time) (*Iterator, error) {
+	iid, err := GetIntermediaryId(id)
+	if err != nil {
+		return nil, err
+	}
+	return NewIteratorFromIntermediary(iid, size, start)
+}
+
+// NewIteratorFromIntermediary returns an iterator over the ephemeral IDs of the
+// intermediary ID with the given size in bits, starting with the ID in effect
+// at the start time.
+func NewIteratorFromIntermediary(
+	iid []byte, size uint, start time.Time) (*Iterator, error) {
+	return v1.NewIterator(iid, size, start)
+}
+
+// NewIterator returns an iterator over the ephemeral IDs derived with the
+// scheme from the intermediary ID with the given size in bits, starting with
+// the ID in effect at the start time. An error is returned if the scheme is
+// invalid.
+func (s Scheme) NewIterator(
+	iid []byte, size uint, start time.Time) (*Iterator, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkIntermediary(iid); err != nil {
+		return nil, err
+	}
+	if size > MaxSize || size < MinSize {
+		return nil, errors.Errorf("Cannot generate ID, size must be between "+
+			"%d and %d", MinSize, MaxSize)
+	}
+
+	return &Iterator{
+		scheme:    s,
+		iid:       append([]byte{}, iid...),
+		size:      size,
+		offset:    s.offset(iid),
+		timestamp: start.UnixNano(),
+		b2b:       s.Hash.New(),
+		salt:      make([]byte, 8),
+	}, nil
+}
+
+// Next returns the ephemeral ID for the current rotation period and advances
+// the iterator to the following period. The ID matches the one returned by
+// GetIdFromIntermediary for any time in the period.
+func (it *Iterator) Next() (ProtoIdentity, error) {
+	start, end, saltNum := it.scheme.offsetBounds(it.offset, it.timestamp)
+	binary.BigEndian.PutUint64(it.salt, saltNum)
+
+	it.b2b.Reset()
+	eid, err := it.scheme.unreservedId(it.b2b, it.iid, it.salt, it.size)
+	if err != nil {
+		return ProtoIdentity{}, err
+	}
+
+	it.advance(end)
+
+	return ProtoIdentity{Id: eid, Start: start, End: end}, nil
+}
+
+// Skip advances the iterator to the following period without computing the ID
+// for the current period.
+func (it *Iterator) Skip() {
+	_, end, _ := it.scheme.offsetBounds(it.offset, it.timestamp)
+	it.advance(end)
+}
+
+// advance moves the timestamp into the period following the one ending at end.
+func (it *Iterator) advance(end time.Time) {
+	it.timestamp = end.Add(time.Nanosecond).UnixNano()
+}
+
+// Timestamp returns a time within the period of the ID that Next will return.
+func (it *Iterator) Timestamp() time.Time {
+	return time.Unix(0, it.timestamp)
+}
+
+// Offset returns the rotation offset of the identity, which is the time into
+// each Period at which its ephemeral ID changes.
+func (it *Iterator) Offset() time.Duration {
+	return time.Duration(it.offset)
+}
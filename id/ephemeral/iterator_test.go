@@ -0,0 +1,126 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ephemeral
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// Tests that each ID returned by Iterator.Next matches the ID returned by
+// GetIdFromIntermediary for the same period and that the periods are
+// consecutive.
+func TestIterator_Next(t *testing.T) {
+	testId := id.NewIdFromString("zezima", id.User, t)
+	iid, _ := GetIntermediaryId(testId)
+	start := time.Unix(0, 1614199942358373731)
+
+	it, err := NewIterator(testId, 16, start)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %+v", err)
+	}
+	if it.Offset() != time.Duration(GetOffset(iid)) {
+		t.Errorf("Unexpected offset %s.", it.Offset())
+	}
+
+	var prev ProtoIdentity
+	for i := 0; i < 30; i++ {
+		timestamp := it.Timestamp()
+		identity, err := it.Next()
+		if err != nil {
+			t.Fatalf("Next returned an error: %+v", err)
+		}
+
+		eid, s, e, _ := GetIdFromIntermediary(iid, 16, timestamp.UnixNano())
+		if identity.Id != eid || !identity.Start.Equal(s) || !identity.End.Equal(e) {
+			t.Errorf("Identity %d does not match GetIdFromIntermediary."+
+				"\nexpected: %v %s %s\nreceived: %+v", i, eid, s, e, identity)
+		}
+		if i > 0 && !identity.Start.Equal(prev.End) {
+			t.Errorf("Identity %d does not start when identity %d ends.", i, i-1)
+		}
+		prev = identity
+	}
+}
+
+// Tests that Iterator.Next skips reserved IDs in the same way as
+// GetIdFromIntermediary, using the input from
+// TestGetIdFromIntermediary_Reserved that generates a reserved ID.
+func TestIterator_Next_Reserved(t *testing.T) {
+	timestamp := int64(1614199942358373731)
+	testId := id.NewIdFromString(strconv.Itoa(41), id.User, t)
+	iid, _ := GetIntermediaryId(testId)
+
+	expected, _, _, _ := GetIdFromIntermediary(iid, 4, timestamp)
+
+	// Start the iterator a period early to check that the hash is reset
+	// between periods, and also check skipping the first period
+	for _, skip := range []bool{false, true} {
+		it, err := NewIteratorFromIntermediary(
+			iid, 4, time.Unix(0, timestamp-Period))
+		if err != nil {
+			t.Fatalf("Failed to create iterator: %+v", err)
+		}
+		if skip {
+			it.Skip()
+		} else if _, err = it.Next(); err != nil {
+			t.Fatalf("Next returned an error: %+v", err)
+		}
+
+		identity, err := it.Next()
+		if err != nil {
+			t.Fatalf("Next returned an error: %+v", err)
+		}
+		if identity.Id != expected || IsReserved(identity.Id) {
+			t.Errorf("Unexpected ID (skip %t).\nexpected: %v\nreceived: %v",
+				skip, expected, identity.Id)
+		}
+	}
+}
+
+// Tests that GetIdsByRange, which uses the iterator, covers the whole range
+// with the expected IDs.
+func TestGetIdsByRange_Iterator(t *testing.T) {
+	testId := id.NewIdFromString("zezima", id.User, t)
+	iid, _ := GetIntermediaryId(testId)
+	start := time.Unix(0, 1614199942358373731)
+
+	identities, err := GetIdsByRange(testId, 8, start, 3*time.Duration(Period))
+	if err != nil {
+		t.Fatalf("GetIdsByRange returned an error: %+v", err)
+	}
+	if len(identities) != 4 {
+		t.Fatalf("Expected 4 identities, received %d.", len(identities))
+	}
+
+	for i, identity := range identities {
+		expected, _, _, _ := GetIdFromIntermediary(iid, 8, identity.Start.UnixNano())
+		if identity.Id != expected {
+			t.Errorf("Unexpected ID %d.\nexpected: %v\nreceived: %v",
+				i, expected, identity.Id)
+		}
+	}
+
+	if identities, err = GetIdsByRange(testId, 8, start, 0); err != nil ||
+		len(identities) != 0 {
+		t.Errorf("Unexpected result for empty range: %v, %+v", identities, err)
+	}
+}
+
+// Error path: Tests that NewIteratorFromIntermediary rejects invalid sizes.
+func TestNewIteratorFromIntermediary_SizeError(t *testing.T) {
+	for _, size := range []uint{0, MaxSize + 1} {
+		if _, err := NewIteratorFromIntermediary(make([]byte, 32), size,
+			time.Now()); err == nil {
+			t.Errorf("No error for size %d.", size)
+		}
+	}
+}
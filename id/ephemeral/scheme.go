@@ -0,0 +1,333 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ephemeral
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	// Registers crypto.BLAKE2b_256, which version 1 of the scheme uses
+	_ "golang.org/x/crypto/blake2b"
+
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// Error messages.
+const (
+	// Scheme.Validate
+	schemeHashErr       = "scheme v%d: hash %s is not available"
+	schemeHashSizeErr   = "scheme v%d: hash size %d is smaller than %d bytes"
+	schemePeriodErr     = "scheme v%d: period %s must be positive"
+	schemeNumOffsetsErr = "scheme v%d: number of offsets %d must be between " +
+		"1 and the number of nanoseconds in the period"
+	schemeReservedErr = "scheme v%d: reserves every ID of size %d"
+
+	// Scheme.checkIntermediary
+	schemeIidLenErr = "scheme v%d: intermediary ID is %d bytes, must be at " +
+		"least %d bytes"
+
+	// RegisterScheme
+	schemeExistsErr = "scheme v%d is already registered"
+
+	// GetScheme
+	schemeUnknownErr = "unknown scheme v%d"
+)
+
+// Scheme defines how ephemeral IDs are derived: the hash used for the
+// intermediary ID and the ID itself, the length of the rotation period, the
+// number of offsets that the period is divided into, and the IDs that are
+// reserved. The package level functions use version 1 (see V1). Other versions
+// can be defined to trial a new hash or rotation period without changing the
+// IDs of existing clients.
+type Scheme struct {
+	// Version identifies the scheme.
+	Version uint8
+
+	// Hash is used to derive the intermediary ID and the ephemeral ID. Its
+	// output must be at least IdLen bytes.
+	Hash crypto.Hash
+
+	// Period is the length of time an ephemeral ID is in use.
+	Period time.Duration
+
+	// NumOffsets is the number of possible offsets into the period at which
+	// an identity's ephemeral ID rotates.
+	NumOffsets int64
+
+	// Reserved contains the ephemeral IDs that are never assigned.
+	Reserved []Id
+}
+
+// v1 is the original derivation scheme that the package level functions use.
+// It has its own copy of ReservedIDs so that modifying that slice does not
+// change the IDs derived by the package.
+var v1 = Scheme{
+	Version:    1,
+	Hash:       crypto.BLAKE2b_256,
+	Period:     time.Duration(Period),
+	NumOffsets: NumOffsets,
+	Reserved:   append([]Id{}, ReservedIDs...),
+}
+
+// schemes contains all known schemes keyed on their version.
+var schemes = struct {
+	m   map[uint8]Scheme
+	mux sync.RWMutex
+}{m: map[uint8]Scheme{v1.Version: v1}}
+
+// V1 returns version 1 of the derivation scheme, which is used by the package
+// level functions such as GetId and GetIdFromIntermediary.
+func V1() Scheme {
+	return v1.copy()
+}
+
+// RegisterScheme adds a new scheme so that it can be found with GetScheme. An
+// error is returned if the scheme is invalid or its version is already used.
+func RegisterScheme(s Scheme) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	schemes.mux.Lock()
+	defer schemes.mux.Unlock()
+	if _, exists := schemes.m[s.Version]; exists {
+		return errors.Errorf(schemeExistsErr, s.Version)
+	}
+	schemes.m[s.Version] = s.copy()
+	return nil
+}
+
+// GetScheme returns the registered scheme with the given version.
+func GetScheme(version uint8) (Scheme, error) {
+	schemes.mux.RLock()
+	defer schemes.mux.RUnlock()
+	s, exists := schemes.m[version]
+	if !exists {
+		return Scheme{}, errors.Errorf(schemeUnknownErr, version)
+	}
+	return s.copy(), nil
+}
+
+// SchemeVersions returns the versions of all registered schemes in ascending
+// order.
+func SchemeVersions() []uint8 {
+	schemes.mux.RLock()
+	defer schemes.mux.RUnlock()
+	versions := make([]uint8, 0, len(schemes.m))
+	for version := range schemes.m {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// Validate checks that IDs can be derived with the scheme.
+func (s Scheme) Validate() error {
+	if !s.Hash.Available() {
+		return errors.Errorf(schemeHashErr, s.Version, s.Hash)
+	}
+	if s.Hash.Size() < IdLen {
+		return errors.Errorf(schemeHashSizeErr, s.Version, s.Hash.Size(), IdLen)
+	}
+	if s.Period <= 0 {
+		return errors.Errorf(schemePeriodErr, s.Version, s.Period)
+	}
+	if s.NumOffsets < 1 || s.NumOffsets > int64(s.Period) {
+		return errors.Errorf(schemeNumOffsetsErr, s.Version, s.NumOffsets)
+	}
+
+	// With a size of 1, only the IDs 0 and 1 exist
+	var zero, one Id
+	one[IdLen-1] = 1
+	if s.IsReserved(zero) && s.IsReserved(one) {
+		return errors.Errorf(schemeReservedErr, s.Version, 1)
+	}
+
+	return nil
+}
+
+// IntermediaryId returns the intermediary ID (ID hash) used to derive the
+// ephemeral IDs of the ID. An error is returned if the scheme is invalid.
+func (s Scheme) IntermediaryId(id *id.ID) ([]byte, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s.intermediaryId(id)
+}
+
+// GetId returns the ephemeral ID of the ID with the given size in bits at the
+// timestamp in nanoseconds, and the start and end of its rotation period. An
+// error is returned if the scheme is invalid.
+func (s Scheme) GetId(id *id.ID, size uint, timestamp int64) (
+	Id, time.Time, time.Time, error) {
+	if err := s.Validate(); err != nil {
+		return Id{}, time.Time{}, time.Time{}, err
+	}
+	iid, err := s.intermediaryId(id)
+	if err != nil {
+		return Id{}, time.Time{}, time.Time{}, err
+	}
+	return s.idFromIntermediary(iid, size, timestamp)
+}
+
+// IdFromIntermediary returns the ephemeral ID of the intermediary ID with the
+// given size in bits at the timestamp in nanoseconds, and the start and end of
+// its rotation period. An error is returned if the scheme is invalid.
+func (s Scheme) IdFromIntermediary(iid []byte, size uint, timestamp int64) (
+	Id, time.Time, time.Time, error) {
+	if err := s.Validate(); err != nil {
+		return Id{}, time.Time{}, time.Time{}, err
+	}
+	return s.idFromIntermediary(iid, size, timestamp)
+}
+
+// Offset returns the time in nanoseconds into each period at which the
+// ephemeral ID of the intermediary ID rotates. An error is returned if the
+// scheme is invalid or the intermediary ID is too short.
+func (s Scheme) Offset(iid []byte) (int64, error) {
+	if err := s.Validate(); err != nil {
+		return 0, err
+	}
+	if err := s.checkIntermediary(iid); err != nil {
+		return 0, err
+	}
+	return s.offset(iid), nil
+}
+
+// OffsetBounds returns the start and end of the rotation period containing the
+// timestamp for an identity with the given offset, and the salt number of the
+// period. An error is returned if the scheme is invalid.
+func (s Scheme) OffsetBounds(offset, timestamp int64) (
+	time.Time, time.Time, uint64, error) {
+	if err := s.Validate(); err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+	start, end, saltNum := s.offsetBounds(offset, timestamp)
+	return start, end, saltNum, nil
+}
+
+// IsReserved returns true if the ID is reserved by the scheme.
+func (s Scheme) IsReserved(eid Id) bool {
+	for _, r := range s.Reserved {
+		if hmac.Equal(eid[:], r[:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// intermediaryId returns the intermediary ID of the ID without validating the
+// scheme.
+func (s Scheme) intermediaryId(id *id.ID) ([]byte, error) {
+	h := s.Hash.New()
+	_, err := h.Write(id.Marshal())
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// idFromIntermediary returns the ephemeral ID of the intermediary ID and the
+// start and end of its rotation period without validating the scheme.
+func (s Scheme) idFromIntermediary(iid []byte, size uint, timestamp int64) (
+	Id, time.Time, time.Time, error) {
+	if size > MaxSize || size < MinSize {
+		return Id{}, time.Time{}, time.Time{}, errors.Errorf("Cannot generate "+
+			"ID, size must be between %d and %d", MinSize, MaxSize)
+	}
+	if err := s.checkIntermediary(iid); err != nil {
+		return Id{}, time.Time{}, time.Time{}, err
+	}
+
+	salt, start, end := s.rotationSalt(iid, timestamp)
+
+	eid, err := s.unreservedId(s.Hash.New(), iid, salt, size)
+	if err != nil {
+		return Id{}, start, end, err
+	}
+	return eid, start, end, nil
+}
+
+// offset returns the rotation offset of the intermediary ID without validating
+// the scheme or the intermediary ID.
+func (s Scheme) offset(iid []byte) int64 {
+	hashNum := binary.BigEndian.Uint64(iid)
+	return int64((hashNum % uint64(s.NumOffsets)) * uint64(s.nsPerOffset()))
+}
+
+// offsetBounds returns the start and end of the rotation period and its salt
+// number without validating the scheme.
+func (s Scheme) offsetBounds(offset, timestamp int64) (
+	time.Time, time.Time, uint64) {
+	period := int64(s.Period)
+	timestampPhase := timestamp % period
+	var start, end int64
+	timestampNum := timestamp / period
+	var saltNum uint64
+	if timestampPhase < offset {
+		start = (timestampNum-1)*period + offset
+		end = start + period
+		saltNum = uint64((timestamp - period) / period)
+	} else {
+		start = timestampNum*period + offset
+		end = start + period
+		saltNum = uint64(timestamp / period)
+	}
+	return time.Unix(0, start), time.Unix(0, end), saltNum
+}
+
+// checkIntermediary returns an error if the intermediary ID is too short to
+// derive an offset from.
+func (s Scheme) checkIntermediary(iid []byte) error {
+	if len(iid) < IdLen {
+		return errors.Errorf(schemeIidLenErr, s.Version, len(iid), IdLen)
+	}
+	return nil
+}
+
+// unreservedId continually generates an ephemeral ID until it lands on an ID
+// not reserved by the scheme. Each attempt writes to the hash again without
+// resetting it, so the hash must be new or reset.
+func (s Scheme) unreservedId(h hash.Hash, iid, salt []byte, size uint) (Id, error) {
+	var eid Id
+	var err error
+	for reserved := true; reserved; reserved = s.IsReserved(eid) {
+		eid, err = getIdFromIntermediary(h, iid, salt, size)
+		if err != nil {
+			return Id{}, err
+		}
+	}
+	return eid, nil
+}
+
+// rotationSalt returns the salt of the rotation period containing the
+// timestamp for the intermediary ID and the start and end of the period.
+func (s Scheme) rotationSalt(iid []byte, timestamp int64) (
+	[]byte, time.Time, time.Time) {
+	start, end, saltNum := s.offsetBounds(s.offset(iid), timestamp)
+	salt := make([]byte, 8)
+	binary.BigEndian.PutUint64(salt, saltNum)
+	return salt, start, end
+}
+
+// nsPerOffset returns the length of each offset in nanoseconds.
+func (s Scheme) nsPerOffset() int64 {
+	return int64(s.Period) / s.NumOffsets
+}
+
+// copy returns a copy of the scheme that does not share the reserved list.
+func (s Scheme) copy() Scheme {
+	s.Reserved = append([]Id{}, s.Reserved...)
+	return s
+}
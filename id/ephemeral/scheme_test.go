@@ -0,0 +1,269 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ephemeral
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// Tests that version 1 of the scheme, and the package level functions that use
+// it, produce the IDs they produced before schemes were introduced.
+func TestV1_Vectors(t *testing.T) {
+	vectors := []struct {
+		id         string
+		size       uint
+		timestamp  int64
+		iid        string
+		offset     int64
+		eid        string
+		start, end int64
+	}{
+		{"zezima", 16, 1614199942358373731,
+			"2901c6aae4cd994743f77805f91b6c20a755876db0f074957f2784003d9a0c94",
+			51731103515625, "000000000000c6e3",
+			1614176531103515625, 1614262931103515625},
+		{"zezima", 64, 1614199942358373731,
+			"2901c6aae4cd994743f77805f91b6c20a755876db0f074957f2784003d9a0c94",
+			51731103515625, "609de56579e0c6e3",
+			1614176531103515625, 1614262931103515625},
+		// The first ID derived for this input is reserved
+		{"41", 4, 1614199942358373731,
+			"1f227a1df7f6e0cd13fbf3270eba0b3bb59dd0a1e2698177f6493ededb364450",
+			75870263671875, "0000000000000001",
+			1614114270263671875, 1614200670263671875},
+		{"xx", 8, 0,
+			"10d7ae33788f4ad5c41a6e7a45b001c9276e336dcd3aa567f3c7e776545ca8dd",
+			25255810546875, "0000000000000073", -61144189453125, 25255810546875},
+		{"xx", 32, 1700000000000000000,
+			"10d7ae33788f4ad5c41a6e7a45b001c9276e336dcd3aa567f3c7e776545ca8dd",
+			25255810546875, "0000000071087b08",
+			1699945255810546875, 1700031655810546875},
+	}
+
+	for i, v := range vectors {
+		testId := id.NewIdFromString(v.id, id.User, t)
+		for _, s := range []Scheme{V1(), v1} {
+			iid, err := s.IntermediaryId(testId)
+			if err != nil {
+				t.Fatalf("Failed to get intermediary ID (%d): %+v", i, err)
+			}
+			if hex.EncodeToString(iid) != v.iid {
+				t.Errorf("Unexpected intermediary ID (%d).\nexpected: %s"+
+					"\nreceived: %x", i, v.iid, iid)
+			}
+			offset, err := s.Offset(iid)
+			if err != nil {
+				t.Fatalf("Failed to get offset (%d): %+v", i, err)
+			}
+			if offset != v.offset {
+				t.Errorf("Unexpected offset (%d).\nexpected: %d\nreceived: %d",
+					i, v.offset, offset)
+			}
+
+			eid, start, end, err := s.GetId(testId, v.size, v.timestamp)
+			if err != nil {
+				t.Fatalf("Failed to get ID (%d): %+v", i, err)
+			}
+			if hex.EncodeToString(eid[:]) != v.eid ||
+				start.UnixNano() != v.start || end.UnixNano() != v.end {
+				t.Errorf("Unexpected ID (%d).\nexpected: %s %d %d"+
+					"\nreceived: %x %d %d", i, v.eid, v.start, v.end,
+					eid, start.UnixNano(), end.UnixNano())
+			}
+		}
+
+		eid, _, _, _ := GetId(testId, v.size, v.timestamp)
+		if hex.EncodeToString(eid[:]) != v.eid {
+			t.Errorf("GetId does not use v1 (%d): %x", i, eid)
+		}
+	}
+}
+
+// Tests that a scheme with a different hash and period produces different IDs
+// that rotate at its own period, and that it can be registered and retrieved.
+func TestScheme_Custom(t *testing.T) {
+	s := Scheme{
+		Version:    200,
+		Hash:       crypto.SHA256,
+		Period:     time.Hour,
+		NumOffsets: 3600,
+		Reserved:   V1().Reserved,
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Failed to validate scheme: %+v", err)
+	}
+
+	testId := id.NewIdFromString("zezima", id.User, t)
+	timestamp := int64(1614199942358373731)
+	eid, start, end, err := s.GetId(testId, 64, timestamp)
+	if err != nil {
+		t.Fatalf("Failed to get ID: %+v", err)
+	}
+	if end.Sub(start) != time.Hour || start.UnixNano() > timestamp ||
+		end.UnixNano() <= timestamp {
+		t.Errorf("Unexpected period [%s, %s).", start, end)
+	}
+	if v1Eid, _, _, _ := GetId(testId, 64, timestamp); v1Eid == eid {
+		t.Errorf("Custom scheme produced the v1 ID.")
+	}
+
+	iid, _ := s.IntermediaryId(testId)
+	offset, err := s.Offset(iid)
+	if err != nil {
+		t.Fatalf("Failed to get offset: %+v", err)
+	}
+	if offset%int64(time.Second) != 0 {
+		t.Errorf("Offset %d is not a multiple of the offset length.", offset)
+	}
+
+	it, err := s.NewIterator(iid, 64, time.Unix(0, timestamp))
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %+v", err)
+	}
+	for i := 0; i < 5; i++ {
+		ts := it.Timestamp().UnixNano()
+		identity, err := it.Next()
+		if err != nil {
+			t.Fatalf("Next returned an error: %+v", err)
+		}
+		expected, _, _, _ := s.IdFromIntermediary(iid, 64, ts)
+		if identity.Id != expected {
+			t.Errorf("Iterator ID %d does not match the scheme.", i)
+		}
+	}
+
+	if err = RegisterScheme(s); err != nil {
+		t.Fatalf("Failed to register scheme: %+v", err)
+	}
+	defer func() {
+		schemes.mux.Lock()
+		delete(schemes.m, s.Version)
+		schemes.mux.Unlock()
+	}()
+	if err = RegisterScheme(s); err == nil {
+		t.Errorf("Registered scheme twice.")
+	}
+
+	received, err := GetScheme(s.Version)
+	if err != nil || !reflect.DeepEqual(received, s) {
+		t.Errorf("Unexpected scheme %+v: %+v", received, err)
+	}
+	if versions := SchemeVersions(); !reflect.DeepEqual(
+		versions, []uint8{1, 200}) {
+		t.Errorf("Unexpected versions %v.", versions)
+	}
+}
+
+// Tests that modifying the scheme returned by V1 or ReservedIDs does not change
+// the scheme used by the package.
+func TestV1_Copy(t *testing.T) {
+	s := V1()
+	s.Reserved[0][0] = 0xFF
+	if !IsReserved(Id{}) || v1.Reserved[0] != (Id{}) {
+		t.Errorf("Modifying the returned scheme changed v1.")
+	}
+
+	if _, err := GetScheme(1); err != nil {
+		t.Errorf("v1 is not registered: %+v", err)
+	}
+
+	original := ReservedIDs[0]
+	ReservedIDs[0][0] = 0xFF
+	defer func() { ReservedIDs[0] = original }()
+	if !IsReserved(Id{}) || v1.Reserved[0] != (Id{}) {
+		t.Errorf("Modifying ReservedIDs changed v1.")
+	}
+}
+
+// Error path: Tests that Scheme.Validate rejects invalid schemes and that
+// they cannot be registered.
+func TestScheme_Validate_Error(t *testing.T) {
+	var one Id
+	one[IdLen-1] = 1
+
+	invalid := []Scheme{
+		{Version: 2, Hash: crypto.MD4, Period: time.Hour, NumOffsets: 1},
+		{Version: 2, Hash: crypto.SHA256, Period: 0, NumOffsets: 1},
+		{Version: 2, Hash: crypto.SHA256, Period: time.Hour, NumOffsets: 0},
+		{Version: 2, Hash: crypto.SHA256, Period: 10, NumOffsets: 11},
+		{Version: 2, Hash: crypto.SHA256, Period: time.Hour, NumOffsets: 1,
+			Reserved: []Id{{}, one}},
+	}
+
+	for i, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("No error for invalid scheme %d.", i)
+		}
+		if err := RegisterScheme(s); err == nil {
+			t.Errorf("Registered invalid scheme %d.", i)
+		}
+	}
+
+	if _, err := GetScheme(255); err == nil {
+		t.Errorf("No error for unknown scheme.")
+	}
+}
+
+// Error path: Tests that the methods of an invalid scheme return an error
+// instead of panicking or dividing by zero.
+func TestScheme_InvalidScheme(t *testing.T) {
+	testId := id.NewIdFromString("zezima", id.User, t)
+	iid := make([]byte, 32)
+	invalid := []Scheme{
+		{},
+		{Version: 2, Hash: crypto.SHA256, Period: 0, NumOffsets: 1},
+	}
+
+	for i, s := range invalid {
+		if _, err := s.IntermediaryId(testId); err == nil {
+			t.Errorf("IntermediaryId returned no error (%d).", i)
+		}
+		if _, _, _, err := s.GetId(testId, 16, 0); err == nil {
+			t.Errorf("GetId returned no error (%d).", i)
+		}
+		if _, _, _, err := s.IdFromIntermediary(iid, 16, 0); err == nil {
+			t.Errorf("IdFromIntermediary returned no error (%d).", i)
+		}
+		if _, err := s.Offset(iid); err == nil {
+			t.Errorf("Offset returned no error (%d).", i)
+		}
+		if _, _, _, err := s.OffsetBounds(0, 0); err == nil {
+			t.Errorf("OffsetBounds returned no error (%d).", i)
+		}
+		if _, err := s.NewIterator(iid, 16, time.Unix(0, 0)); err == nil {
+			t.Errorf("NewIterator returned no error (%d).", i)
+		}
+		if _, err := s.GenerateVectors(); err == nil {
+			t.Errorf("GenerateVectors returned no error (%d).", i)
+		}
+	}
+}
+
+// Error path: Tests that the methods of a valid scheme return an error for an
+// intermediary ID that is too short to derive an offset from.
+func TestScheme_ShortIntermediary(t *testing.T) {
+	s := V1()
+	iid := make([]byte, IdLen-1)
+
+	if _, err := s.Offset(iid); err == nil {
+		t.Errorf("Offset returned no error.")
+	}
+	if _, _, _, err := s.IdFromIntermediary(iid, 16, 0); err == nil {
+		t.Errorf("IdFromIntermediary returned no error.")
+	}
+	if _, err := s.NewIterator(iid, 16, time.Unix(0, 0)); err == nil {
+		t.Errorf("NewIterator returned no error.")
+	}
+}
@@ -0,0 +1,208 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ephemeral
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// Error messages.
+const (
+	vectorErr      = "failed to generate vector for ID %s at %d: %+v"
+	writeVectorErr = "failed to write test vectors: %+v"
+)
+
+// vectorIdCount is the number of IDs that vectors are generated for.
+const vectorIdCount = 16
+
+// vectorSizes are the ID sizes in bits used in the vectors.
+var vectorSizes = []uint{1, 2, 4, 8, 16, 24, 32, 48, 63, 64}
+
+// vectorDays are the periods that the timestamps of the vectors are chosen
+// around. Timestamps before the epoch are skipped.
+var vectorDays = []int64{0, 1, 19000}
+
+// Vectors contains test vectors for the derivation of ephemeral IDs, to be
+// shared with other implementations. All 64-bit integers are encoded as decimal
+// strings so that they are not rounded by JSON parsers that use floating point
+// numbers; all byte strings are hex encoded.
+type Vectors struct {
+	// Scheme, Period, and NumOffsets describe the scheme the vectors were
+	// generated with.
+	Scheme     uint8 `json:"scheme"`
+	Period     int64 `json:"period,string"`
+	NumOffsets int64 `json:"numOffsets,string"`
+
+	Ids   []Vector      `json:"ids"`
+	Int64 []Int64Vector `json:"int64"`
+}
+
+// Vector is the ephemeral ID of an ID at a timestamp, with the intermediate
+// values of the derivation.
+type Vector struct {
+	// ID is the marshalled id.ID.
+	ID             string `json:"id"`
+	IntermediaryID string `json:"intermediaryId"`
+	Offset         int64  `json:"offset,string"`
+	Size           uint   `json:"size"`
+	Timestamp      int64  `json:"timestamp,string"`
+	EphemeralID    string `json:"ephemeralId"`
+	Start          int64  `json:"start,string"`
+	End            int64  `json:"end,string"`
+}
+
+// Int64Vector is the result of Id.UInt64 and Id.Int64 for an ephemeral ID.
+type Int64Vector struct {
+	EphemeralID string `json:"ephemeralId"`
+	UInt64      uint64 `json:"uint64,string"`
+	Int64       int64  `json:"int64,string"`
+}
+
+// GenerateVectors returns the test vectors for version 1 of the scheme, which
+// are checked in at testdata/vectors.json.
+func GenerateVectors() (*Vectors, error) {
+	return v1.GenerateVectors()
+}
+
+// WriteVectors writes the test vectors for version 1 of the scheme as indented
+// JSON. It is used to regenerate testdata/vectors.json.
+func WriteVectors(w io.Writer) error {
+	vectors, err := GenerateVectors()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(vectors, "", "\t")
+	if err != nil {
+		return errors.Errorf(writeVectorErr, err)
+	}
+	if _, err = w.Write(append(data, '\n')); err != nil {
+		return errors.Errorf(writeVectorErr, err)
+	}
+	return nil
+}
+
+// GenerateVectors returns test vectors for the scheme. The IDs are generated
+// deterministically and the timestamps are chosen on and next to the rotation
+// boundaries of each ID, where implementations most often differ.
+func (s Scheme) GenerateVectors() (*Vectors, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	vectors := &Vectors{
+		Scheme:     s.Version,
+		Period:     int64(s.Period),
+		NumOffsets: s.NumOffsets,
+	}
+
+	types := []id.Type{id.Generic, id.Gateway, id.Node, id.User, id.Group}
+	period := int64(s.Period)
+
+	for i := 0; i < vectorIdCount; i++ {
+		vectorId := vectorTestId(i, types[i%len(types)])
+		iid, err := s.IntermediaryId(vectorId)
+		if err != nil {
+			return nil, errors.Errorf(vectorErr, vectorId, 0, err)
+		}
+		offset := s.offset(iid)
+
+		var timestamps []int64
+		for _, day := range vectorDays {
+			base := day * period
+			timestamps = append(timestamps,
+				base-1, base, // Period boundary of the network
+				base+offset-1, base+offset, base+offset+1, // Rotation boundary
+				base+offset+period-1) // Last nanosecond of the rotation
+		}
+
+		j := 0
+		for _, timestamp := range timestamps {
+			if timestamp < 0 {
+				continue
+			}
+			size := vectorSizes[(i+j)%len(vectorSizes)]
+			j++
+
+			v, err := s.vector(vectorId, iid, size, timestamp)
+			if err != nil {
+				return nil, err
+			}
+			vectors.Ids = append(vectors.Ids, v)
+		}
+	}
+
+	// Input from TestGetIdFromIntermediary_Reserved, where the first ID
+	// derived is reserved and a second one must be derived
+	reservedId := &id.ID{'4', '1'}
+	reservedId.SetType(id.User)
+	reservedIid, err := s.IntermediaryId(reservedId)
+	if err != nil {
+		return nil, errors.Errorf(vectorErr, reservedId, 0, err)
+	}
+	v, err := s.vector(reservedId, reservedIid, 4, 1614199942358373731)
+	if err != nil {
+		return nil, err
+	}
+	vectors.Ids = append(vectors.Ids, v)
+
+	for _, u := range []uint64{0, 1, 2, 3, math.MaxInt64 - 1, math.MaxInt64,
+		math.MaxInt64 + 1, math.MaxUint64 - 1, math.MaxUint64,
+		0x0101010101010101} {
+		var eid Id
+		binary.BigEndian.PutUint64(eid[:], u)
+		vectors.Int64 = append(vectors.Int64, Int64Vector{
+			EphemeralID: hex.EncodeToString(eid[:]),
+			UInt64:      eid.UInt64(),
+			Int64:       eid.Int64(),
+		})
+	}
+
+	return vectors, nil
+}
+
+// vector derives the ephemeral ID of the ID at the timestamp.
+func (s Scheme) vector(vectorId *id.ID, iid []byte, size uint,
+	timestamp int64) (Vector, error) {
+	eid, start, end, err := s.IdFromIntermediary(iid, size, timestamp)
+	if err != nil {
+		return Vector{}, errors.Errorf(vectorErr, vectorId, timestamp, err)
+	}
+
+	return Vector{
+		ID:             hex.EncodeToString(vectorId.Marshal()),
+		IntermediaryID: hex.EncodeToString(iid),
+		Offset:         s.offset(iid),
+		Size:           size,
+		Timestamp:      timestamp,
+		EphemeralID:    hex.EncodeToString(eid[:]),
+		Start:          start.UnixNano(),
+		End:            end.UnixNano(),
+	}, nil
+}
+
+// vectorTestId returns the ID with the i-th test vector data and the type.
+func vectorTestId(i int, t id.Type) *id.ID {
+	h := sha256.New()
+	h.Write([]byte("ephemeral ID test vector"))
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(i)))
+
+	var vectorId id.ID
+	copy(vectorId[:], h.Sum(nil))
+	vectorId.SetType(t)
+	return &vectorId
+}
@@ -0,0 +1,167 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ephemeral
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// vectorsPath is the path of the checked-in test vectors.
+var vectorsPath = filepath.Join("testdata", "vectors.json")
+
+// updateVectors regenerates the checked-in test vectors when set:
+//
+//	go test ./id/ephemeral -run TestVectors -update
+var updateVectors = flag.Bool(
+	"update", false, "regenerate "+vectorsPath+" with WriteVectors")
+
+// loadVectors reads the checked-in test vectors, regenerating them first if
+// the update flag is set.
+func loadVectors(t *testing.T) ([]byte, *Vectors) {
+	if *updateVectors {
+		var buf bytes.Buffer
+		if err := WriteVectors(&buf); err != nil {
+			t.Fatalf("Failed to generate vectors: %+v", err)
+		}
+		if err := os.WriteFile(vectorsPath, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %+v", vectorsPath, err)
+		}
+	}
+
+	data, err := os.ReadFile(vectorsPath)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %+v", vectorsPath, err)
+	}
+
+	var vectors Vectors
+	if err = json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("Failed to unmarshal %s: %+v", vectorsPath, err)
+	}
+	return data, &vectors
+}
+
+// Conformance test: Tests that the package produces every value in the
+// checked-in test vectors.
+func TestVectors(t *testing.T) {
+	_, vectors := loadVectors(t)
+
+	if vectors.Scheme != 1 || vectors.Period != Period ||
+		vectors.NumOffsets != NumOffsets {
+		t.Errorf("Vectors are for a different scheme: %d %d %d",
+			vectors.Scheme, vectors.Period, vectors.NumOffsets)
+	}
+	if len(vectors.Ids) == 0 || len(vectors.Int64) == 0 {
+		t.Fatalf("No vectors in %s.", vectorsPath)
+	}
+
+	for i, v := range vectors.Ids {
+		idBytes, _ := hex.DecodeString(v.ID)
+		vectorId, err := id.Unmarshal(idBytes)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal ID of vector %d: %+v", i, err)
+		}
+
+		iid, err := GetIntermediaryId(vectorId)
+		if err != nil {
+			t.Fatalf("Failed to get intermediary ID of vector %d: %+v", i, err)
+		}
+		if hex.EncodeToString(iid) != v.IntermediaryID {
+			t.Errorf("Vector %d: unexpected intermediary ID %x.", i, iid)
+		}
+		if offset := GetOffset(iid); offset != v.Offset {
+			t.Errorf("Vector %d: unexpected offset %d.", i, offset)
+		}
+
+		start, end, _ := GetOffsetBounds(v.Offset, v.Timestamp)
+		if start.UnixNano() != v.Start || end.UnixNano() != v.End {
+			t.Errorf("Vector %d: unexpected bounds [%d, %d).",
+				i, start.UnixNano(), end.UnixNano())
+		}
+
+		eid, start, end, err := GetId(vectorId, v.Size, v.Timestamp)
+		if err != nil {
+			t.Fatalf("Failed to get ID of vector %d: %+v", i, err)
+		}
+		if hex.EncodeToString(eid[:]) != v.EphemeralID ||
+			start.UnixNano() != v.Start || end.UnixNano() != v.End {
+			t.Errorf("Vector %d: unexpected ID.\nexpected: %s [%d, %d)"+
+				"\nreceived: %x [%d, %d)", i, v.EphemeralID, v.Start, v.End,
+				eid, start.UnixNano(), end.UnixNano())
+		}
+	}
+
+	for i, v := range vectors.Int64 {
+		eidBytes, _ := hex.DecodeString(v.EphemeralID)
+		eid, err := Marshal(eidBytes)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal Int64 vector %d: %+v", i, err)
+		}
+		if eid.UInt64() != v.UInt64 || eid.Int64() != v.Int64 {
+			t.Errorf("Int64 vector %d: unexpected values %d %d.",
+				i, eid.UInt64(), eid.Int64())
+		}
+	}
+}
+
+// Tests that the checked-in test vectors match the output of WriteVectors, so
+// that changes to the generator are checked in.
+func TestVectors_UpToDate(t *testing.T) {
+	data, _ := loadVectors(t)
+
+	var buf bytes.Buffer
+	if err := WriteVectors(&buf); err != nil {
+		t.Fatalf("Failed to generate vectors: %+v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("%s is out of date; regenerate it with the -update flag.",
+			vectorsPath)
+	}
+}
+
+// Tests that the vectors cover the rotation boundaries, including timestamps
+// exactly at and one nanosecond before the start of a rotation period.
+func TestGenerateVectors_Boundaries(t *testing.T) {
+	vectors, err := GenerateVectors()
+	if err != nil {
+		t.Fatalf("Failed to generate vectors: %+v", err)
+	}
+
+	var atStart, beforeStart int
+	sizes := make(map[uint]bool)
+	for _, v := range vectors.Ids {
+		if v.Timestamp == v.Start {
+			atStart++
+		}
+		if v.Timestamp == v.End-1 {
+			beforeStart++
+		}
+		sizes[v.Size] = true
+	}
+
+	if atStart < vectorIdCount || beforeStart < vectorIdCount {
+		t.Errorf("Too few boundary vectors: %d at start, %d before the end.",
+			atStart, beforeStart)
+	}
+	if len(sizes) != len(vectorSizes) {
+		t.Errorf("Vectors use %d sizes, expected %d.", len(sizes), len(vectorSizes))
+	}
+
+	again, _ := GenerateVectors()
+	if !reflect.DeepEqual(vectors, again) {
+		t.Errorf("Vectors are not deterministic.")
+	}
+}
@@ -7,9 +7,10 @@
 
 // Package id contains the generic ID type, which is a byte array that
 // represents an entity ID. The first bytes in the array contain the actual ID
-// data while the last byte contains the ID type, which is either generic,
-// gateway, node, or user. IDs can be hard coded or generated using a
-// cryptographic function found in crypto.
+// data while the last byte contains the ID type, which is either one of the
+// built-in types (generic, gateway, node, user, or group) or a type added with
+// RegisterType. IDs can be hard coded or generated using a cryptographic
+// function found in crypto.
 package id
 
 import (
@@ -181,10 +182,18 @@ func (id ID) MarshalText() (text []byte, err error) {
 	return []byte(base64.RawStdEncoding.EncodeToString(id[:])), nil
 }
 
-// UnmarshalText unmarshalls the text into an [ID]. This function adheres to the
+// UnmarshalText unmarshalls the text into an [ID]. The text can be base 64, as
+// produced by ID.MarshalText, or the checksummed encoding produced by
+// ID.MarshalBech32. This function adheres to the
 // [encoding.TextUnmarshaler] interface. This allows for the JSON unmarshalling
 // of non-referenced IDs in maps (e.g., map[ID]int).
 func (id *ID) UnmarshalText(text []byte) error {
+	// Base 64 IDs have a fixed length that is shorter than any bech32 ID
+	if len(text) != base64.RawStdEncoding.EncodedLen(ArrIDLen) &&
+		bytes.IndexByte(text, bech32Separator) > 0 {
+		return id.UnmarshalBech32(text)
+	}
+
 	idBytes, err := base64.RawStdEncoding.DecodeString(string(text))
 	if err != nil {
 		return err
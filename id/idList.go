@@ -28,3 +28,30 @@ func NewIDListFromBytes(topology [][]byte) ([]*ID, error) {
 
 	return list, nil
 }
+
+// MarshalIDList serialises the list of IDs into a single byte slice of
+// concatenated marshalled IDs, each ArrIDLen bytes long.
+func MarshalIDList(ids []*ID) []byte {
+	b := make([]byte, 0, len(ids)*ArrIDLen)
+	for _, id := range ids {
+		b = append(b, id[:]...)
+	}
+	return b
+}
+
+// UnmarshalIDList deserializes a list of IDs marshalled with MarshalIDList. An
+// error is returned if the length of the data is not a multiple of ArrIDLen.
+func UnmarshalIDList(data []byte) ([]*ID, error) {
+	if len(data)%ArrIDLen != 0 {
+		return nil, errors.Errorf("unable to unmarshal ID list: length of "+
+			"data must be a multiple of %d, length received is %d",
+			ArrIDLen, len(data))
+	}
+
+	list := make([]*ID, len(data)/ArrIDLen)
+	for i := range list {
+		list[i] = copyID(data[i*ArrIDLen : (i+1)*ArrIDLen])
+	}
+
+	return list, nil
+}
@@ -62,3 +62,34 @@ func TestNewIDListFromBytes_Error(t *testing.T) {
 	}
 
 }
+
+// Tests that a list marshalled with MarshalIDList is unmarshalled by
+// UnmarshalIDList and that data of the wrong length is rejected.
+func TestMarshalIDList_UnmarshalIDList(t *testing.T) {
+	prng := rand.New(rand.NewSource(3265))
+	ids := make([]*ID, 10)
+	for i := range ids {
+		ids[i] = NewRandomTestID(prng, Node, t)
+	}
+
+	data := MarshalIDList(ids)
+	if len(data) != len(ids)*ArrIDLen {
+		t.Errorf("Unexpected length %d.", len(data))
+	}
+
+	received, err := UnmarshalIDList(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal ID list: %+v", err)
+	}
+	for i := range ids {
+		if !received[i].Equal(ids[i]) {
+			t.Errorf("ID %d does not match.\nexpected: %s\nreceived: %s",
+				i, ids[i], received[i])
+		}
+	}
+
+	if _, err = UnmarshalIDList(data[1:]); err == nil ||
+		!strings.Contains(err.Error(), "multiple of") {
+		t.Errorf("Unexpected error for truncated data: %+v", err)
+	}
+}
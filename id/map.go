@@ -0,0 +1,186 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+// map.go contains Map, a map keyed by ID value with deterministic iteration,
+// and ConcurrentMap, which is safe for concurrent use.
+
+import (
+	"sync"
+)
+
+// Map maps IDs to values of type V. The zero value is an empty map ready to
+// use. A Map is not safe for concurrent use; use ConcurrentMap instead.
+type Map[V any] struct {
+	entries map[ID]V
+}
+
+// NewMap returns an empty map.
+func NewMap[V any]() *Map[V] {
+	return &Map[V]{entries: make(map[ID]V)}
+}
+
+// Load returns the value stored for the ID and true, or the zero value and
+// false if the ID is not in the map.
+func (m *Map[V]) Load(id *ID) (V, bool) {
+	v, exists := m.entries[*id]
+	return v, exists
+}
+
+// Store sets the value for the ID.
+func (m *Map[V]) Store(id *ID, v V) {
+	if m.entries == nil {
+		m.entries = make(map[ID]V)
+	}
+	m.entries[*id] = v
+}
+
+// Delete removes the ID from the map.
+func (m *Map[V]) Delete(id *ID) {
+	delete(m.entries, *id)
+}
+
+// Has returns true if the ID is in the map.
+func (m *Map[V]) Has(id *ID) bool {
+	_, exists := m.entries[*id]
+	return exists
+}
+
+// Len returns the number of entries in the map.
+func (m *Map[V]) Len() int {
+	return len(m.entries)
+}
+
+// Keys returns the IDs in the map in ascending order, as defined by
+// ID.Compare.
+func (m *Map[V]) Keys() []*ID {
+	return sortedKeys(m.entries)
+}
+
+// KeySet returns a set of the IDs in the map.
+func (m *Map[V]) KeySet() *Set {
+	s := &Set{ids: make(map[ID]struct{}, len(m.entries))}
+	for id := range m.entries {
+		s.ids[id] = struct{}{}
+	}
+	return s
+}
+
+// Range calls fn for each entry in the map in ascending order of ID until fn
+// returns false. The map may be modified by fn without affecting the
+// iteration.
+func (m *Map[V]) Range(fn func(id *ID, v V) bool) {
+	for _, id := range m.Keys() {
+		v, exists := m.entries[*id]
+		if exists && !fn(id, v) {
+			return
+		}
+	}
+}
+
+// Clone returns a shallow copy of the map.
+func (m *Map[V]) Clone() *Map[V] {
+	c := &Map[V]{entries: make(map[ID]V, len(m.entries))}
+	for id, v := range m.entries {
+		c.entries[id] = v
+	}
+	return c
+}
+
+// Union returns a new map containing the entries of both maps. For IDs in both
+// maps, the value from other is used.
+func (m *Map[V]) Union(other *Map[V]) *Map[V] {
+	u := m.Clone()
+	for id, v := range other.entries {
+		u.entries[id] = v
+	}
+	return u
+}
+
+// Intersection returns a new map containing the entries of m whose IDs are also
+// in other.
+func (m *Map[V]) Intersection(other *Map[V]) *Map[V] {
+	i := &Map[V]{entries: make(map[ID]V)}
+	for id, v := range m.entries {
+		if _, exists := other.entries[id]; exists {
+			i.entries[id] = v
+		}
+	}
+	return i
+}
+
+// Difference returns a new map containing the entries of m whose IDs are not
+// in other.
+func (m *Map[V]) Difference(other *Map[V]) *Map[V] {
+	d := &Map[V]{entries: make(map[ID]V)}
+	for id, v := range m.entries {
+		if _, exists := other.entries[id]; !exists {
+			d.entries[id] = v
+		}
+	}
+	return d
+}
+
+// ConcurrentMap is a Map that is safe for concurrent use.
+type ConcurrentMap[V any] struct {
+	m   Map[V]
+	mux sync.RWMutex
+}
+
+// NewConcurrentMap returns an empty concurrent map.
+func NewConcurrentMap[V any]() *ConcurrentMap[V] {
+	return &ConcurrentMap[V]{m: Map[V]{entries: make(map[ID]V)}}
+}
+
+// Load returns the value stored for the ID and true, or the zero value and
+// false if the ID is not in the map.
+func (cm *ConcurrentMap[V]) Load(id *ID) (V, bool) {
+	cm.mux.RLock()
+	defer cm.mux.RUnlock()
+	return cm.m.Load(id)
+}
+
+// Store sets the value for the ID.
+func (cm *ConcurrentMap[V]) Store(id *ID, v V) {
+	cm.mux.Lock()
+	defer cm.mux.Unlock()
+	cm.m.Store(id, v)
+}
+
+// LoadOrStore returns the existing value for the ID and true if it is in the
+// map. Otherwise, it stores the value and returns it and false.
+func (cm *ConcurrentMap[V]) LoadOrStore(id *ID, v V) (V, bool) {
+	cm.mux.Lock()
+	defer cm.mux.Unlock()
+	if existing, exists := cm.m.Load(id); exists {
+		return existing, true
+	}
+	cm.m.Store(id, v)
+	return v, false
+}
+
+// Delete removes the ID from the map.
+func (cm *ConcurrentMap[V]) Delete(id *ID) {
+	cm.mux.Lock()
+	defer cm.mux.Unlock()
+	cm.m.Delete(id)
+}
+
+// Len returns the number of entries in the map.
+func (cm *ConcurrentMap[V]) Len() int {
+	cm.mux.RLock()
+	defer cm.mux.RUnlock()
+	return cm.m.Len()
+}
+
+// Snapshot returns a shallow copy of the current contents of the map.
+func (cm *ConcurrentMap[V]) Snapshot() *Map[V] {
+	cm.mux.RLock()
+	defer cm.mux.RUnlock()
+	return cm.m.Clone()
+}
@@ -0,0 +1,136 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// Tests that Map.Store, Map.Load, Map.Delete, and Map.Has track the entries in
+// the map and that the zero value is usable.
+func TestMap_StoreLoadDelete(t *testing.T) {
+	ids := newTestIDs(t, 2)
+
+	var m Map[string]
+	if _, exists := m.Load(ids[0]); exists || m.Len() != 0 {
+		t.Errorf("Zero value map is not empty.")
+	}
+
+	m.Store(ids[0], "a")
+	m.Store(ids[0].DeepCopy(), "b")
+	if v, exists := m.Load(ids[0]); !exists || v != "b" || m.Len() != 1 {
+		t.Errorf("Unexpected value %q (%t) for stored ID.", v, exists)
+	}
+
+	m.Delete(ids[0])
+	if m.Has(ids[0]) || m.Len() != 0 {
+		t.Errorf("ID not deleted.")
+	}
+}
+
+// Tests that Map.Keys and Map.Range iterate in ascending order of ID.
+func TestMap_Keys_Range(t *testing.T) {
+	ids := newTestIDs(t, 5)
+	m := NewMap[int]()
+	for _, i := range []int{3, 0, 4, 1, 2} {
+		m.Store(ids[i], i)
+	}
+
+	if keys := m.Keys(); !reflect.DeepEqual(keys, ids) {
+		t.Errorf("Unexpected keys.\nexpected: %v\nreceived: %v", ids, keys)
+	}
+
+	var values []int
+	m.Range(func(id *ID, v int) bool {
+		values = append(values, v)
+		m.Delete(ids[4])
+		return v < 3
+	})
+	if !reflect.DeepEqual(values, []int{0, 1, 2, 3}) {
+		t.Errorf("Unexpected range values: %v", values)
+	}
+
+	if !m.KeySet().Equal(NewSet(ids[:4]...)) {
+		t.Errorf("Unexpected key set: %v", m.KeySet().IDs())
+	}
+}
+
+// Tests Map.Union, Map.Intersection, and Map.Difference.
+func TestMap_Operations(t *testing.T) {
+	ids := newTestIDs(t, 3)
+	a, b := NewMap[string](), NewMap[string]()
+	a.Store(ids[0], "a0")
+	a.Store(ids[1], "a1")
+	b.Store(ids[1], "b1")
+	b.Store(ids[2], "b2")
+
+	collect := func(m *Map[string]) []string {
+		var values []string
+		m.Range(func(_ *ID, v string) bool {
+			values = append(values, v)
+			return true
+		})
+		return values
+	}
+
+	for name, tt := range map[string]struct {
+		result   *Map[string]
+		expected []string
+	}{
+		"Union":        {a.Union(b), []string{"a0", "b1", "b2"}},
+		"Intersection": {a.Intersection(b), []string{"a1"}},
+		"Difference":   {a.Difference(b), []string{"a0"}},
+	} {
+		if values := collect(tt.result); !reflect.DeepEqual(values, tt.expected) {
+			t.Errorf("%s: expected %v, received %v", name, tt.expected, values)
+		}
+	}
+
+	if a.Len() != 2 || b.Len() != 2 {
+		t.Errorf("Operations modified the original maps.")
+	}
+}
+
+// Tests that ConcurrentMap can be used from multiple goroutines and that
+// LoadOrStore keeps the first value stored.
+func TestConcurrentMap(t *testing.T) {
+	ids := newTestIDs(t, 100)
+	cm := NewConcurrentMap[int]()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for _, id := range ids {
+				cm.LoadOrStore(id, g)
+				_, _ = cm.Load(id)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	snapshot := cm.Snapshot()
+	for _, id := range ids {
+		v, _ := cm.Load(id)
+		if actual, loaded := cm.LoadOrStore(id, -1); !loaded || actual != v {
+			t.Errorf("LoadOrStore replaced value %d with %d.", v, actual)
+		}
+	}
+
+	cm.Delete(ids[0])
+	cm.Store(ids[1], -1)
+	if cm.Len() != 99 || snapshot.Len() != 100 {
+		t.Errorf("Unexpected lengths: %d, %d", cm.Len(), snapshot.Len())
+	}
+	if v, _ := snapshot.Load(ids[1]); v == -1 {
+		t.Errorf("Snapshot changed after Store.")
+	}
+}
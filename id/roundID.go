@@ -10,23 +10,40 @@ package id
 import (
 	"encoding/binary"
 	"strconv"
+
+	"github.com/pkg/errors"
 )
 
+// RoundLen is the length of a marshalled Round ID.
+const RoundLen = 8
+
 // Round is the round ID for each round run in cMix.
 type Round uint64
 
 // Marshal serialises the Round ID into a byte slice.
 func (rid Round) Marshal() []byte {
-	b := make([]byte, 8)
+	b := make([]byte, RoundLen)
 	binary.LittleEndian.PutUint64(b, uint64(rid))
 	return b
 }
 
-// UnmarshalRound deserializes the byte slice into a Round ID.
+// UnmarshalRound deserializes the byte slice into a Round ID. Bytes past
+// RoundLen are ignored and the function panics if the slice is too short; use
+// UnmarshalRoundStrict for untrusted data.
 func UnmarshalRound(b []byte) Round {
 	return Round(binary.LittleEndian.Uint64(b))
 }
 
+// UnmarshalRoundStrict deserializes the byte slice into a Round ID. An error is
+// returned if the slice is not exactly RoundLen bytes long.
+func UnmarshalRoundStrict(b []byte) (Round, error) {
+	if len(b) != RoundLen {
+		return 0, errors.Errorf("Failed to unmarshal round ID: length of "+
+			"data must be %d, length received is %d", RoundLen, len(b))
+	}
+	return UnmarshalRound(b), nil
+}
+
 // String returns the string representation of the Round ID. This functions
 // adheres to the fmt.Stringer interface.
 func (rid Round) String() string {
@@ -46,3 +46,20 @@ func TestRound_String(t *testing.T) {
 		}
 	}
 }
+
+// Tests that UnmarshalRoundStrict unmarshalls marshalled rounds and returns an
+// error for data of the wrong length.
+func TestUnmarshalRoundStrict(t *testing.T) {
+	rid := Round(rand.New(rand.NewSource(42)).Uint64())
+	received, err := UnmarshalRoundStrict(rid.Marshal())
+	if err != nil || received != rid {
+		t.Errorf("Unexpected round %d: %+v", received, err)
+	}
+
+	for _, data := range [][]byte{nil, make([]byte, RoundLen-1),
+		make([]byte, RoundLen+1)} {
+		if _, err = UnmarshalRoundStrict(data); err == nil {
+			t.Errorf("No error for data of length %d.", len(data))
+		}
+	}
+}
@@ -0,0 +1,102 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+// roundRange.go contains RoundRange, a contiguous range of Round IDs, and its
+// fixed-size wire format.
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// RoundRangeLen is the length of a marshalled RoundRange.
+const RoundRangeLen = 2 * RoundLen
+
+// RoundRange is the range of Round IDs from Start up to, but not including,
+// End. A range with End equal to Start is empty.
+type RoundRange struct {
+	Start Round
+	End   Round
+}
+
+// NewRoundRange returns the range [start, end). An error is returned if end is
+// before start.
+func NewRoundRange(start, end Round) (RoundRange, error) {
+	rr := RoundRange{Start: start, End: end}
+	if err := rr.Validate(); err != nil {
+		return RoundRange{}, err
+	}
+	return rr, nil
+}
+
+// Validate returns an error if End is before Start.
+func (rr RoundRange) Validate() error {
+	if rr.End < rr.Start {
+		return errors.Errorf(
+			"invalid round range: end %d is before start %d", rr.End, rr.Start)
+	}
+	return nil
+}
+
+// Len returns the number of rounds in the range.
+func (rr RoundRange) Len() uint64 {
+	if rr.End < rr.Start {
+		return 0
+	}
+	return uint64(rr.End - rr.Start)
+}
+
+// Contains returns true if the round is in the range.
+func (rr RoundRange) Contains(rid Round) bool {
+	return rid >= rr.Start && rid < rr.End
+}
+
+// Rounds returns every round in the range in ascending order.
+func (rr RoundRange) Rounds() []Round {
+	rounds := make([]Round, 0, rr.Len())
+	for rid := rr.Start; rid < rr.End; rid++ {
+		rounds = append(rounds, rid)
+	}
+	return rounds
+}
+
+// Marshal serialises the range into RoundRangeLen bytes.
+func (rr RoundRange) Marshal() []byte {
+	b := make([]byte, RoundRangeLen)
+	binary.LittleEndian.PutUint64(b, uint64(rr.Start))
+	binary.LittleEndian.PutUint64(b[RoundLen:], uint64(rr.End))
+	return b
+}
+
+// UnmarshalRoundRange deserializes a range marshalled with RoundRange.Marshal.
+// An error is returned if the data is not RoundRangeLen bytes long or the range
+// is invalid.
+func UnmarshalRoundRange(b []byte) (RoundRange, error) {
+	if len(b) != RoundRangeLen {
+		return RoundRange{}, errors.Errorf("Failed to unmarshal round range: "+
+			"length of data must be %d, length received is %d",
+			RoundRangeLen, len(b))
+	}
+
+	rr := RoundRange{
+		Start: UnmarshalRound(b[:RoundLen]),
+		End:   UnmarshalRound(b[RoundLen:]),
+	}
+	if err := rr.Validate(); err != nil {
+		return RoundRange{}, errors.WithMessage(err, "Failed to unmarshal round range")
+	}
+	return rr, nil
+}
+
+// String returns the range in the form "[start, end)". This functions adheres
+// to the fmt.Stringer interface.
+func (rr RoundRange) String() string {
+	return "[" + rr.Start.String() + ", " + rr.End.String() + ")"
+}
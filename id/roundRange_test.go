@@ -0,0 +1,65 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Tests the basic properties of a RoundRange.
+func TestRoundRange(t *testing.T) {
+	rr, err := NewRoundRange(10, 14)
+	if err != nil {
+		t.Fatalf("Failed to create range: %+v", err)
+	}
+
+	if rr.Len() != 4 {
+		t.Errorf("Unexpected length %d.", rr.Len())
+	}
+	if !rr.Contains(10) || !rr.Contains(13) || rr.Contains(14) || rr.Contains(9) {
+		t.Errorf("Contains returned unexpected results for %s.", rr)
+	}
+	if rounds := rr.Rounds(); !reflect.DeepEqual(rounds, []Round{10, 11, 12, 13}) {
+		t.Errorf("Unexpected rounds: %v", rounds)
+	}
+	if rr.String() != "[10, 14)" {
+		t.Errorf("Unexpected string: %s", rr)
+	}
+
+	if empty := (RoundRange{Start: 5, End: 5}); empty.Len() != 0 || empty.Contains(5) {
+		t.Errorf("Empty range is not empty.")
+	}
+
+	if _, err = NewRoundRange(14, 10); err == nil {
+		t.Errorf("No error for range that ends before it starts.")
+	}
+}
+
+// Tests that a range marshalled with RoundRange.Marshal is unmarshalled by
+// UnmarshalRoundRange and that invalid data is rejected.
+func TestRoundRange_Marshal_UnmarshalRoundRange(t *testing.T) {
+	rr := RoundRange{Start: 1 << 40, End: 1<<40 + 5000}
+
+	data := rr.Marshal()
+	if len(data) != RoundRangeLen {
+		t.Errorf("Unexpected length %d.", len(data))
+	}
+
+	received, err := UnmarshalRoundRange(data)
+	if err != nil || received != rr {
+		t.Errorf("Unexpected range %s: %+v", received, err)
+	}
+
+	if _, err = UnmarshalRoundRange(data[1:]); err == nil {
+		t.Errorf("No error for short data.")
+	}
+	if _, err = UnmarshalRoundRange(RoundRange{Start: 2, End: 1}.Marshal()); err == nil {
+		t.Errorf("No error for invalid range.")
+	}
+}
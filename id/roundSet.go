@@ -0,0 +1,278 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+// roundSet.go contains compact encodings of sets of Round IDs, such as the
+// rounds a gateway has stored. The first byte of an encoded set selects one of
+// two formats:
+//
+// Delta (roundSetDelta): the number of rounds, the first round, and the
+// difference between each round and the previous one, each as a uvarint.
+// Sparse sets of nearby rounds encode to one or two bytes per round.
+//
+//	0x01 | count | first | delta_1 | ... | delta_(count-1)
+//
+// Bitmap (roundSetBitmap): the first round as a uvarint followed by a bitmap in
+// which bit i (least significant bit first) is set if round first+i is in the
+// set. Dense sets encode to one bit per round in the span of the set.
+//
+//	0x02 | first | bitmap
+//
+// MarshalRounds picks whichever format is smaller. Decoding is strict: every
+// encoding of a set is unique, so non-minimal varints, zero deltas, overflow,
+// unset first or trailing zero bitmap bytes, and trailing data are errors.
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// MaxRangeRounds is the maximum number of rounds that MarshalRoundRanges
+// encodes. It bounds the memory used to expand the ranges.
+const MaxRangeRounds = 1 << 20
+
+// Round set formats.
+const (
+	roundSetDelta  byte = 1
+	roundSetBitmap byte = 2
+)
+
+// Error messages.
+const (
+	roundSetEmptyErr    = "round set data is empty"
+	roundSetFormatErr   = "unknown round set format %d"
+	roundSetVarintErr   = "invalid round set varint at byte %d"
+	roundSetCountErr    = "round set count %d exceeds the remaining %d bytes"
+	roundSetDeltaErr    = "round set delta %d at index %d must be positive"
+	roundSetOverflowErr = "round set overflows at index %d"
+	roundSetTrailingErr = "round set has %d bytes of trailing data"
+	roundSetBitmapErr   = "round set bitmap must start with a set bit and " +
+		"end with a non-zero byte"
+	roundRangesLenErr = "round ranges cover more than the maximum of %d rounds"
+)
+
+// MarshalRounds encodes the set of rounds in the smaller of the delta and
+// bitmap formats. The rounds may be in any order and duplicates are ignored.
+func MarshalRounds(rounds []Round) []byte {
+	sorted := sortRounds(rounds)
+
+	delta := marshalRoundsDelta(sorted)
+	if len(sorted) == 0 {
+		return delta
+	}
+
+	// Only build the bitmap when it could be smaller than the delta encoding
+	span := uint64(sorted[len(sorted)-1] - sorted[0])
+	if span/8 < uint64(len(delta)) {
+		if bitmap := marshalRoundsBitmap(sorted); len(bitmap) < len(delta) {
+			return bitmap
+		}
+	}
+
+	return delta
+}
+
+// UnmarshalRounds decodes a set of rounds encoded by MarshalRounds. The rounds
+// are returned in ascending order.
+func UnmarshalRounds(data []byte) ([]Round, error) {
+	if len(data) == 0 {
+		return nil, errors.New(roundSetEmptyErr)
+	}
+
+	r := &roundSetReader{data: data, pos: 1}
+	var rounds []Round
+	var err error
+	switch data[0] {
+	case roundSetDelta:
+		rounds, err = r.delta()
+	case roundSetBitmap:
+		rounds, err = r.bitmap()
+	default:
+		return nil, errors.Errorf(roundSetFormatErr, data[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if r.pos != len(data) {
+		return nil, errors.Errorf(roundSetTrailingErr, len(data)-r.pos)
+	}
+	return rounds, nil
+}
+
+// MarshalRoundRanges encodes the set of rounds covered by the ranges. Every
+// round in the ranges is expanded, so an error is returned if the lengths of
+// the ranges add up to more than MaxRangeRounds. An error is also returned if a
+// range is invalid (see RoundRange.Validate).
+func MarshalRoundRanges(ranges ...RoundRange) ([]byte, error) {
+	var total uint64
+	for _, rr := range ranges {
+		if err := rr.Validate(); err != nil {
+			return nil, err
+		}
+		if rr.Len() > MaxRangeRounds-total {
+			return nil, errors.Errorf(roundRangesLenErr, MaxRangeRounds)
+		}
+		total += rr.Len()
+	}
+
+	rounds := make([]Round, 0, total)
+	for _, rr := range ranges {
+		rounds = append(rounds, rr.Rounds()...)
+	}
+	return MarshalRounds(rounds), nil
+}
+
+// RoundsToRanges returns the minimal list of ranges covering the rounds, in
+// ascending order. Round math.MaxUint64 cannot be the start of a RoundRange and
+// is omitted.
+func RoundsToRanges(rounds []Round) []RoundRange {
+	var ranges []RoundRange
+	for _, rid := range sortRounds(rounds) {
+		if rid == math.MaxUint64 {
+			continue
+		} else if n := len(ranges); n > 0 && ranges[n-1].End == rid {
+			ranges[n-1].End++
+		} else {
+			ranges = append(ranges, RoundRange{Start: rid, End: rid + 1})
+		}
+	}
+	return ranges
+}
+
+// marshalRoundsDelta encodes the sorted, unique rounds in the delta format.
+func marshalRoundsDelta(sorted []Round) []byte {
+	b := make([]byte, 0, 1+binary.MaxVarintLen64*(len(sorted)+1))
+	b = append(b, roundSetDelta)
+	b = binary.AppendUvarint(b, uint64(len(sorted)))
+	for i, rid := range sorted {
+		if i == 0 {
+			b = binary.AppendUvarint(b, uint64(rid))
+		} else {
+			b = binary.AppendUvarint(b, uint64(rid-sorted[i-1]))
+		}
+	}
+	return b
+}
+
+// marshalRoundsBitmap encodes the sorted, unique, non-empty rounds in the
+// bitmap format.
+func marshalRoundsBitmap(sorted []Round) []byte {
+	first := sorted[0]
+	span := uint64(sorted[len(sorted)-1] - first)
+
+	b := make([]byte, 0, 1+binary.MaxVarintLen64+span/8+1)
+	b = append(b, roundSetBitmap)
+	b = binary.AppendUvarint(b, uint64(first))
+
+	bitmap := make([]byte, span/8+1)
+	for _, rid := range sorted {
+		offset := uint64(rid - first)
+		bitmap[offset/8] |= 1 << (offset % 8)
+	}
+	return append(b, bitmap...)
+}
+
+// sortRounds returns a sorted copy of the rounds with duplicates removed.
+func sortRounds(rounds []Round) []Round {
+	sorted := append([]Round{}, rounds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	unique := sorted[:0]
+	for i, rid := range sorted {
+		if i == 0 || rid != sorted[i-1] {
+			unique = append(unique, rid)
+		}
+	}
+	return unique
+}
+
+// roundSetReader reads the fields of an encoded round set.
+type roundSetReader struct {
+	data []byte
+	pos  int
+}
+
+// uvarint reads a minimally encoded uvarint.
+func (r *roundSetReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 || n != len(binary.AppendUvarint(nil, v)) {
+		return 0, errors.Errorf(roundSetVarintErr, r.pos)
+	}
+	r.pos += n
+	return v, nil
+}
+
+// delta reads the body of the delta format.
+func (r *roundSetReader) delta() ([]Round, error) {
+	count, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every round takes at least one byte, which bounds the allocation
+	if remaining := uint64(len(r.data) - r.pos); count > remaining {
+		return nil, errors.Errorf(roundSetCountErr, count, remaining)
+	}
+
+	rounds := make([]Round, 0, count)
+	for i := uint64(0); i < count; i++ {
+		v, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			rounds = append(rounds, Round(v))
+			continue
+		}
+
+		prev := uint64(rounds[i-1])
+		if v == 0 {
+			return nil, errors.Errorf(roundSetDeltaErr, v, i)
+		} else if v > math.MaxUint64-prev {
+			return nil, errors.Errorf(roundSetOverflowErr, i)
+		}
+		rounds = append(rounds, Round(prev+v))
+	}
+
+	return rounds, nil
+}
+
+// bitmap reads the body of the bitmap format.
+func (r *roundSetReader) bitmap() ([]Round, error) {
+	first, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap := r.data[r.pos:]
+	r.pos = len(r.data)
+	if len(bitmap) == 0 || bitmap[0]&1 == 0 || bitmap[len(bitmap)-1] == 0 {
+		return nil, errors.New(roundSetBitmapErr)
+	}
+
+	var rounds []Round
+	for i, b := range bitmap {
+		for bit := uint64(0); b != 0; bit++ {
+			if b&1 == 1 {
+				offset := uint64(i)*8 + bit
+				if offset > math.MaxUint64-first {
+					return nil, errors.Errorf(roundSetOverflowErr, len(rounds))
+				}
+				rounds = append(rounds, Round(first+offset))
+			}
+			b >>= 1
+		}
+	}
+
+	return rounds, nil
+}
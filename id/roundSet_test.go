@@ -0,0 +1,172 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Tests that sets of rounds round trip through MarshalRounds and
+// UnmarshalRounds and are encoded in the expected format.
+func TestMarshalRounds_UnmarshalRounds(t *testing.T) {
+	prng := rand.New(rand.NewSource(42))
+
+	dense := RoundRange{Start: 1_000_000, End: 1_005_000}.Rounds()
+	for i := range dense {
+		if prng.Intn(10) == 0 {
+			dense[i] = dense[0]
+		}
+	}
+
+	sparse := make([]Round, 1000)
+	for i := range sparse {
+		sparse[i] = Round(1_000_000 + prng.Intn(10_000_000))
+	}
+
+	tests := []struct {
+		name   string
+		rounds []Round
+		format byte
+	}{
+		{"Empty", nil, roundSetDelta},
+		{"Single", []Round{42}, roundSetDelta},
+		{"Unordered", []Round{9, 3, 3, 7, 1}, roundSetBitmap},
+		{"Maximum", []Round{0, math.MaxUint64}, roundSetDelta},
+		{"Dense", dense, roundSetBitmap},
+		{"Sparse", sparse, roundSetDelta},
+	}
+
+	for _, tt := range tests {
+		data := MarshalRounds(tt.rounds)
+		if data[0] != tt.format {
+			t.Errorf("%s: expected format %d, received %d",
+				tt.name, tt.format, data[0])
+		}
+
+		received, err := UnmarshalRounds(data)
+		if err != nil {
+			t.Errorf("%s: failed to unmarshal: %+v", tt.name, err)
+			continue
+		}
+		if expected := sortRounds(tt.rounds); len(expected) != 0 &&
+			!reflect.DeepEqual(received, expected) {
+			t.Errorf("%s: unexpected rounds.\nexpected: %v\nreceived: %v",
+				tt.name, expected, received)
+		} else if len(expected) == 0 && len(received) != 0 {
+			t.Errorf("%s: expected no rounds, received %v", tt.name, received)
+		}
+	}
+
+	if n := len(MarshalRounds(dense)); n > 700 {
+		t.Errorf("Dense set of %d rounds encoded to %d bytes.", len(dense), n)
+	}
+}
+
+// Tests that MarshalRoundRanges and RoundsToRanges convert between ranges and
+// sets of rounds.
+func TestMarshalRoundRanges_RoundsToRanges(t *testing.T) {
+	ranges := []RoundRange{{Start: 5, End: 10}, {Start: 20, End: 21},
+		{Start: 30, End: 100}}
+
+	data, err := MarshalRoundRanges(ranges...)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %+v", err)
+	}
+	rounds, err := UnmarshalRounds(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %+v", err)
+	}
+
+	if received := RoundsToRanges(rounds); !reflect.DeepEqual(received, ranges) {
+		t.Errorf("Unexpected ranges.\nexpected: %v\nreceived: %v",
+			ranges, received)
+	}
+
+	if received := RoundsToRanges([]Round{math.MaxUint64 - 1, math.MaxUint64}); !reflect.DeepEqual(
+		received, []RoundRange{{Start: math.MaxUint64 - 1, End: math.MaxUint64}}) {
+		t.Errorf("Unexpected ranges at the maximum round: %v", received)
+	}
+}
+
+// Error path: Tests that MarshalRoundRanges rejects ranges that cover more
+// than MaxRangeRounds rounds without expanding them.
+func TestMarshalRoundRanges_Error(t *testing.T) {
+	tests := [][]RoundRange{
+		{{Start: 0, End: 1 << 40}},
+		{{Start: 0, End: math.MaxUint64}},
+		{{Start: 0, End: MaxRangeRounds}, {Start: 5, End: 6}},
+		{{Start: 1, End: 2}, {Start: 0, End: math.MaxUint64}},
+	}
+
+	for i, ranges := range tests {
+		_, err := MarshalRoundRanges(ranges...)
+		if err == nil || !strings.Contains(err.Error(), "maximum") {
+			t.Errorf("Unexpected error for test %d: %+v", i, err)
+		}
+	}
+
+	if _, err := MarshalRoundRanges(
+		RoundRange{Start: 0, End: MaxRangeRounds}); err != nil {
+		t.Errorf("Failed to marshal %d rounds: %+v", MaxRangeRounds, err)
+	}
+}
+
+// Error path: Tests that MarshalRoundRanges rejects a range that ends before it
+// starts instead of treating it as empty.
+func TestMarshalRoundRanges_InvalidRange(t *testing.T) {
+	tests := [][]RoundRange{
+		{{Start: 6, End: 5}},
+		{{Start: 1, End: 2}, {Start: math.MaxUint64, End: 0}},
+	}
+
+	for i, ranges := range tests {
+		data, err := MarshalRoundRanges(ranges...)
+		if err == nil || !strings.Contains(err.Error(), "before start") {
+			t.Errorf("Unexpected error for test %d: %+v", i, err)
+		}
+		if data != nil {
+			t.Errorf("Data returned for invalid ranges in test %d.", i)
+		}
+	}
+}
+
+// Error path: Tests that UnmarshalRounds rejects malformed and non-canonical
+// encodings.
+func TestUnmarshalRounds_Error(t *testing.T) {
+	tests := []struct {
+		data []byte
+		err  string
+	}{
+		{nil, "empty"},
+		{[]byte{0}, "unknown round set format"},
+		{[]byte{roundSetDelta}, "varint"},
+		{[]byte{roundSetDelta, 0x80, 0x00}, "varint"},
+		{[]byte{roundSetDelta, 5, 1}, "exceeds"},
+		{[]byte{roundSetDelta, 2, 1, 0}, "must be positive"},
+		{[]byte{roundSetDelta, 2, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+			0xFF, 0xFF, 0x01, 1}, "overflows"},
+		{[]byte{roundSetDelta, 1, 1, 1}, "trailing"},
+		{[]byte{roundSetBitmap, 1}, "bitmap"},
+		{[]byte{roundSetBitmap, 1, 0x02}, "bitmap"},
+		{[]byte{roundSetBitmap, 1, 0x01, 0x00}, "bitmap"},
+		{[]byte{roundSetBitmap, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+			0xFF, 0xFF, 0x01, 0x03}, "overflows"},
+	}
+
+	for i, tt := range tests {
+		_, err := UnmarshalRounds(tt.data)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Unexpected error for test %d (%x).\nexpected: %s"+
+				"\nreceived: %+v", i, tt.data, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+// set.go contains Set, an unordered collection of unique IDs with constant
+// time membership checks, and ConcurrentSet, which is safe for concurrent use.
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Set is a set of IDs. The zero value is an empty set ready to use. A Set is
+// not safe for concurrent use; use ConcurrentSet instead.
+type Set struct {
+	ids map[ID]struct{}
+}
+
+// NewSet returns a set containing the IDs.
+func NewSet(ids ...*ID) *Set {
+	s := &Set{ids: make(map[ID]struct{}, len(ids))}
+	s.Add(ids...)
+	return s
+}
+
+// NewSetFromBytes creates a set from a list of marshalled IDs in the format
+// returned by Set.Marshal. An error is returned if any ID fails to unmarshal.
+func NewSetFromBytes(topology [][]byte) (*Set, error) {
+	list, err := NewIDListFromBytes(topology)
+	if err != nil {
+		return nil, err
+	}
+	return NewSet(list...), nil
+}
+
+// Add adds the IDs to the set.
+func (s *Set) Add(ids ...*ID) {
+	if s.ids == nil {
+		s.ids = make(map[ID]struct{}, len(ids))
+	}
+	for _, id := range ids {
+		s.ids[*id] = struct{}{}
+	}
+}
+
+// Remove removes the IDs from the set.
+func (s *Set) Remove(ids ...*ID) {
+	for _, id := range ids {
+		delete(s.ids, *id)
+	}
+}
+
+// Has returns true if the ID is in the set.
+func (s *Set) Has(id *ID) bool {
+	_, exists := s.ids[*id]
+	return exists
+}
+
+// Len returns the number of IDs in the set.
+func (s *Set) Len() int {
+	return len(s.ids)
+}
+
+// IDs returns the IDs in the set in ascending order, as defined by ID.Compare.
+func (s *Set) IDs() []*ID {
+	return sortedKeys(s.ids)
+}
+
+// Range calls fn for each ID in the set in ascending order until fn returns
+// false. The set may be modified by fn without affecting the iteration.
+func (s *Set) Range(fn func(id *ID) bool) {
+	for _, id := range s.IDs() {
+		if !fn(id) {
+			return
+		}
+	}
+}
+
+// Equal returns true if both sets contain the same IDs.
+func (s *Set) Equal(other *Set) bool {
+	if s.Len() != other.Len() {
+		return false
+	}
+	for id := range s.ids {
+		if _, exists := other.ids[id]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// Clone returns a copy of the set.
+func (s *Set) Clone() *Set {
+	c := &Set{ids: make(map[ID]struct{}, len(s.ids))}
+	for id := range s.ids {
+		c.ids[id] = struct{}{}
+	}
+	return c
+}
+
+// Union returns a new set containing the IDs in either set.
+func (s *Set) Union(other *Set) *Set {
+	u := s.Clone()
+	for id := range other.ids {
+		u.ids[id] = struct{}{}
+	}
+	return u
+}
+
+// Intersection returns a new set containing the IDs in both sets.
+func (s *Set) Intersection(other *Set) *Set {
+	small, large := s, other
+	if small.Len() > large.Len() {
+		small, large = large, small
+	}
+
+	i := &Set{ids: make(map[ID]struct{})}
+	for id := range small.ids {
+		if _, exists := large.ids[id]; exists {
+			i.ids[id] = struct{}{}
+		}
+	}
+	return i
+}
+
+// Difference returns a new set containing the IDs in s that are not in other.
+func (s *Set) Difference(other *Set) *Set {
+	d := &Set{ids: make(map[ID]struct{})}
+	for id := range s.ids {
+		if _, exists := other.ids[id]; !exists {
+			d.ids[id] = struct{}{}
+		}
+	}
+	return d
+}
+
+// Marshal returns the IDs in ascending order as a list of marshalled IDs, the
+// format used for topologies and read by NewSetFromBytes and
+// NewIDListFromBytes.
+func (s *Set) Marshal() [][]byte {
+	ids := s.IDs()
+	topology := make([][]byte, len(ids))
+	for i, id := range ids {
+		topology[i] = id.Marshal()
+	}
+	return topology
+}
+
+// Unmarshal replaces the contents of the set with the marshalled IDs. The set
+// is unchanged if any ID fails to unmarshal.
+func (s *Set) Unmarshal(topology [][]byte) error {
+	newSet, err := NewSetFromBytes(topology)
+	if err != nil {
+		return errors.WithMessage(err, "failed to unmarshal ID set")
+	}
+	s.ids = newSet.ids
+	return nil
+}
+
+// ConcurrentSet is a Set that is safe for concurrent use.
+type ConcurrentSet struct {
+	set Set
+	mux sync.RWMutex
+}
+
+// NewConcurrentSet returns a concurrent set containing the IDs.
+func NewConcurrentSet(ids ...*ID) *ConcurrentSet {
+	return &ConcurrentSet{set: *NewSet(ids...)}
+}
+
+// Add adds the IDs to the set.
+func (cs *ConcurrentSet) Add(ids ...*ID) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+	cs.set.Add(ids...)
+}
+
+// AddIfAbsent adds the ID to the set and returns true if it was not already in
+// the set.
+func (cs *ConcurrentSet) AddIfAbsent(id *ID) bool {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+	if cs.set.Has(id) {
+		return false
+	}
+	cs.set.Add(id)
+	return true
+}
+
+// Remove removes the IDs from the set.
+func (cs *ConcurrentSet) Remove(ids ...*ID) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+	cs.set.Remove(ids...)
+}
+
+// Has returns true if the ID is in the set.
+func (cs *ConcurrentSet) Has(id *ID) bool {
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+	return cs.set.Has(id)
+}
+
+// Len returns the number of IDs in the set.
+func (cs *ConcurrentSet) Len() int {
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+	return cs.set.Len()
+}
+
+// Snapshot returns a copy of the current contents of the set.
+func (cs *ConcurrentSet) Snapshot() *Set {
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+	return cs.set.Clone()
+}
+
+// sortedKeys returns the keys of the map in ascending order.
+func sortedKeys[V any](m map[ID]V) []*ID {
+	ids := make([]*ID, 0, len(m))
+	for id := range m {
+		ids = append(ids, copyID(id[:]))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+	return ids
+}
@@ -0,0 +1,169 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// newTestIDs returns n IDs whose data is the numbers 1 through n.
+func newTestIDs(t *testing.T, n int) []*ID {
+	ids := make([]*ID, n)
+	for i := range ids {
+		ids[i] = NewIdFromUInt(uint64(i+1), Node, t)
+	}
+	return ids
+}
+
+// Tests that Set.Add, Set.Remove, Set.Has, and Set.Len track the IDs in the set
+// and that the zero value is usable.
+func TestSet_AddRemoveHas(t *testing.T) {
+	ids := newTestIDs(t, 3)
+
+	var s Set
+	if s.Has(ids[0]) || s.Len() != 0 {
+		t.Errorf("Zero value set is not empty.")
+	}
+
+	s.Add(ids[0], ids[1], ids[1].DeepCopy())
+	if s.Len() != 2 || !s.Has(ids[0]) || !s.Has(ids[1]) || s.Has(ids[2]) {
+		t.Errorf("Unexpected set after adding: %v", s.IDs())
+	}
+
+	s.Remove(ids[0], ids[2])
+	if s.Len() != 1 || s.Has(ids[0]) || !s.Has(ids[1]) {
+		t.Errorf("Unexpected set after removing: %v", s.IDs())
+	}
+}
+
+// Tests that Set.IDs and Set.Range return IDs in ascending order.
+func TestSet_IDs_Range(t *testing.T) {
+	prng := rand.New(rand.NewSource(42))
+	s := NewSet()
+	for i := 0; i < 50; i++ {
+		s.Add(NewRandomTestID(prng, User, t))
+	}
+
+	ids := s.IDs()
+	for i := 1; i < len(ids); i++ {
+		if !ids[i-1].Less(ids[i]) {
+			t.Fatalf("IDs %d and %d are out of order.", i-1, i)
+		}
+	}
+
+	var ranged []*ID
+	s.Range(func(id *ID) bool {
+		ranged = append(ranged, id)
+		return len(ranged) < 10
+	})
+	if !reflect.DeepEqual(ranged, ids[:10]) {
+		t.Errorf("Range did not stop or is out of order.")
+	}
+}
+
+// Tests Set.Union, Set.Intersection, and Set.Difference.
+func TestSet_Operations(t *testing.T) {
+	ids := newTestIDs(t, 4)
+	a := NewSet(ids[0], ids[1], ids[2])
+	b := NewSet(ids[1], ids[2], ids[3])
+
+	tests := []struct {
+		name     string
+		result   *Set
+		expected *Set
+	}{
+		{"Union", a.Union(b), NewSet(ids...)},
+		{"Intersection", a.Intersection(b), NewSet(ids[1], ids[2])},
+		{"Difference", a.Difference(b), NewSet(ids[0])},
+		{"Reverse difference", b.Difference(a), NewSet(ids[3])},
+		{"Empty intersection", a.Intersection(&Set{}), NewSet()},
+	}
+
+	for _, tt := range tests {
+		if !tt.result.Equal(tt.expected) {
+			t.Errorf("%s: expected %v, received %v",
+				tt.name, tt.expected.IDs(), tt.result.IDs())
+		}
+	}
+
+	if a.Len() != 3 || b.Len() != 3 {
+		t.Errorf("Operations modified the original sets.")
+	}
+}
+
+// Tests that a set marshalled with Set.Marshal is unmarshalled by
+// NewSetFromBytes, Set.Unmarshal, and NewIDListFromBytes.
+func TestSet_Marshal_Unmarshal(t *testing.T) {
+	ids := newTestIDs(t, 5)
+	s := NewSet(ids[4], ids[2], ids[0], ids[3], ids[1])
+
+	topology := s.Marshal()
+
+	list, err := NewIDListFromBytes(topology)
+	if err != nil || !reflect.DeepEqual(list, ids) {
+		t.Errorf("Unexpected ID list %v: %+v", list, err)
+	}
+
+	newSet, err := NewSetFromBytes(topology)
+	if err != nil || !newSet.Equal(s) {
+		t.Errorf("Unexpected set %v: %+v", newSet.IDs(), err)
+	}
+
+	var unmarshalled Set
+	if err = unmarshalled.Unmarshal(topology); err != nil || !unmarshalled.Equal(s) {
+		t.Errorf("Unexpected unmarshalled set %v: %+v", unmarshalled.IDs(), err)
+	}
+
+	if err = unmarshalled.Unmarshal([][]byte{{1, 2, 3}}); err == nil {
+		t.Errorf("Unmarshal did not return an error for invalid data.")
+	} else if !unmarshalled.Equal(s) {
+		t.Errorf("Failed Unmarshal modified the set.")
+	}
+}
+
+// Tests that ConcurrentSet can be used from multiple goroutines and that only
+// one caller of AddIfAbsent adds each ID.
+func TestConcurrentSet(t *testing.T) {
+	ids := newTestIDs(t, 100)
+	cs := NewConcurrentSet()
+
+	var wg sync.WaitGroup
+	added := make([]int, len(ids))
+	var addedMux sync.Mutex
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, id := range ids {
+				if cs.AddIfAbsent(id) {
+					addedMux.Lock()
+					added[i]++
+					addedMux.Unlock()
+				}
+				_ = cs.Has(id)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, n := range added {
+		if n != 1 {
+			t.Errorf("ID %d was added %d times.", i, n)
+		}
+	}
+
+	snapshot := cs.Snapshot()
+	cs.Remove(ids[0])
+	if cs.Len() != 99 || snapshot.Len() != 100 || cs.Has(ids[0]) {
+		t.Errorf("Unexpected lengths after removing: %d, %d",
+			cs.Len(), snapshot.Len())
+	}
+}
@@ -21,26 +21,17 @@ const (
 	Node
 	User
 	Group
-	NumTypes // Gives number of ID types
+	NumTypes // Gives number of built-in ID types
 )
 
-// String returns the ID Type in a human-readable form for use in logging and
-// debugging. This functions adheres to the fmt.Stringer interface.
+// String returns the registered name of the ID Type in a human-readable form
+// for use in logging and debugging. This functions adheres to the fmt.Stringer
+// interface.
 func (t Type) String() string {
-	switch t {
-	case Generic:
-		return "generic"
-	case Gateway:
-		return "gateway"
-	case Node:
-		return "node"
-	case User:
-		return "user"
-	case Group:
-		return "group"
-	case NumTypes:
+	if name, exists := typeName(t); exists {
+		return name
+	} else if t == NumTypes {
 		return strconv.Itoa(int(NumTypes))
-	default:
-		return "UNKNOWN ID TYPE: " + strconv.Itoa(int(t))
 	}
+	return "UNKNOWN ID TYPE: " + strconv.Itoa(int(t))
 }
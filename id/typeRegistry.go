@@ -0,0 +1,253 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+// typeRegistry.go contains the registry of ID types. The built-in types are
+// always registered; downstream projects can register additional types with
+// RegisterType, usually from an init function.
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// Error messages.
+const (
+	typeRegisteredErr  = "ID type %d is already registered as %q"
+	typeNameInvalidErr = "invalid ID type name %q: must start with a letter " +
+		"and contain only letters, numbers, hyphens, and underscores"
+	typeNameTakenErr    = "ID type name %q is already used by type %d"
+	typeReservedTypeErr = "reserved ID %s has type %d; expected type %d"
+	typeReservedDupErr  = "reserved ID %s is listed more than once"
+	typeUnknownErr      = "unknown ID type %q"
+	typeJsonErr         = "could not parse ID type JSON: %+v"
+	typeInvalidIdErr    = "invalid %s ID %s"
+)
+
+// typeNameRegex matches valid type names. Names cannot start with a digit so
+// that they are never confused with the numerical form of a type.
+var typeNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+// TypeInfo describes a registered ID type.
+type TypeInfo struct {
+	// Name is the human-readable name of the type returned by Type.String and
+	// accepted by ParseType. Names are case-insensitively unique.
+	Name string
+
+	// Reserved is the list of IDs of this type that are set aside for
+	// well-known entities and must not be assigned to anything else.
+	Reserved []ID
+
+	// Validate is an optional hook called by ID.Validate for IDs of this type.
+	Validate func(id *ID) error
+}
+
+// typeRegistry stores the registered ID types.
+var typeRegistry = struct {
+	types map[Type]TypeInfo
+	names map[string]Type
+	mux   sync.RWMutex
+}{
+	types: make(map[Type]TypeInfo),
+	names: make(map[string]Type),
+}
+
+// Registers the built-in ID types and their hard coded IDs.
+func init() {
+	for t, info := range map[Type]TypeInfo{
+		Generic: {Name: "generic", Reserved: []ID{
+			Permissioning, Authorizer, ClientRegistration, NotificationBot}},
+		Gateway: {Name: "gateway", Reserved: []ID{TempGateway}},
+		Node:    {Name: "node"},
+		User:    {Name: "user", Reserved: []ID{ZeroUser, DummyUser, UDB}},
+		Group:   {Name: "group"},
+	} {
+		if err := RegisterType(t, info); err != nil {
+			jww.FATAL.Panicf("Failed to register built-in ID type: %+v", err)
+		}
+	}
+}
+
+// RegisterType adds the ID type to the registry. An error is returned if the
+// type or name is already registered, the name is invalid, or a reserved ID
+// does not have the type.
+func RegisterType(t Type, info TypeInfo) error {
+	if !typeNameRegex.MatchString(info.Name) {
+		return errors.Errorf(typeNameInvalidErr, info.Name)
+	}
+
+	seen := make(map[ID]bool, len(info.Reserved))
+	for i := range info.Reserved {
+		reserved := &info.Reserved[i]
+		if reserved.GetType() != t {
+			return errors.Errorf(
+				typeReservedTypeErr, reserved, reserved.GetType(), t)
+		} else if seen[*reserved] {
+			return errors.Errorf(typeReservedDupErr, reserved)
+		}
+		seen[*reserved] = true
+	}
+
+	typeRegistry.mux.Lock()
+	defer typeRegistry.mux.Unlock()
+
+	if existing, exists := typeRegistry.types[t]; exists {
+		return errors.Errorf(typeRegisteredErr, t, existing.Name)
+	}
+	key := strings.ToLower(info.Name)
+	if existing, exists := typeRegistry.names[key]; exists {
+		return errors.Errorf(typeNameTakenErr, info.Name, existing)
+	}
+
+	info.Reserved = append([]ID{}, info.Reserved...)
+	typeRegistry.types[t] = info
+	typeRegistry.names[key] = t
+
+	return nil
+}
+
+// LookupType returns the registration of the type and true, or false if the
+// type is not registered.
+func LookupType(t Type) (TypeInfo, bool) {
+	typeRegistry.mux.RLock()
+	defer typeRegistry.mux.RUnlock()
+
+	info, exists := typeRegistry.types[t]
+	info.Reserved = append([]ID(nil), info.Reserved...)
+	return info, exists
+}
+
+// RegisteredTypes returns all registered types in ascending order.
+func RegisteredTypes() []Type {
+	typeRegistry.mux.RLock()
+	defer typeRegistry.mux.RUnlock()
+
+	types := make([]Type, 0, len(typeRegistry.types))
+	for t := range typeRegistry.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// IsRegistered returns true if the type is in the registry.
+func (t Type) IsRegistered() bool {
+	_, exists := typeName(t)
+	return exists
+}
+
+// typeName returns the registered name of the type and true, or false if the
+// type is not registered.
+func typeName(t Type) (string, bool) {
+	typeRegistry.mux.RLock()
+	defer typeRegistry.mux.RUnlock()
+
+	info, exists := typeRegistry.types[t]
+	return info.Name, exists
+}
+
+// ParseType returns the registered type with the given name, compared
+// case-insensitively, or with the given decimal value.
+func ParseType(s string) (Type, error) {
+	typeRegistry.mux.RLock()
+	defer typeRegistry.mux.RUnlock()
+
+	if t, exists := typeRegistry.names[strings.ToLower(s)]; exists {
+		return t, nil
+	}
+
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err == nil {
+		if _, exists := typeRegistry.types[Type(n)]; exists {
+			return Type(n), nil
+		}
+	}
+
+	return 0, errors.Errorf(typeUnknownErr, s)
+}
+
+// UnmarshalText parses the name of a registered type or the decimal value of
+// any type. Unregistered values are accepted so that data from peers that know
+// of more types can still be decoded; use Type.Validate to check that the type
+// is registered. This function adheres to the [encoding.TextUnmarshaler]
+// interface.
+func (t *Type) UnmarshalText(text []byte) error {
+	if parsed, err := ParseType(string(text)); err == nil {
+		*t = parsed
+		return nil
+	}
+
+	n, err := strconv.ParseUint(string(text), 10, 8)
+	if err != nil {
+		return errors.Errorf(typeUnknownErr, text)
+	}
+	*t = Type(n)
+	return nil
+}
+
+// UnmarshalJSON unmarshalls a type from either its JSON number form, which is
+// how a Type is marshalled, or its name or decimal value as a JSON string. Like
+// UnmarshalText, it accepts unregistered values. This function adheres to the
+// [json.Unmarshaler] interface.
+func (t *Type) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n uint8
+		if err = json.Unmarshal(data, &n); err != nil {
+			return errors.Errorf(typeJsonErr, err)
+		}
+		s = strconv.Itoa(int(n))
+	}
+
+	return t.UnmarshalText([]byte(s))
+}
+
+// Validate returns an error if the type is not registered.
+func (t Type) Validate() error {
+	if !t.IsRegistered() {
+		return errors.Errorf(typeUnknownErr, t)
+	}
+	return nil
+}
+
+// IsReserved returns true if the ID is in the reserved list of its type.
+func IsReserved(id *ID) bool {
+	typeRegistry.mux.RLock()
+	defer typeRegistry.mux.RUnlock()
+
+	for _, reserved := range typeRegistry.types[id.GetType()].Reserved {
+		if reserved == *id {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the ID type is registered and that the ID passes the
+// validation hook of the type, if it has one.
+func (id *ID) Validate() error {
+	info, exists := LookupType(id.GetType())
+	if !exists {
+		return errors.Errorf(typeUnknownErr, id.GetType())
+	}
+
+	if info.Validate != nil {
+		if err := info.Validate(id); err != nil {
+			return errors.WithMessagef(err, typeInvalidIdErr, info.Name, id)
+		}
+	}
+
+	return nil
+}
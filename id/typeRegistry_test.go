@@ -0,0 +1,194 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package id
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// testType is the type registered by registerTestType.
+const testType = Type(200)
+
+// registerTestType registers testType with the name "channel", a reserved ID,
+// and a hook that rejects IDs whose first byte is zero. The registration is
+// removed when the test completes.
+func registerTestType(t *testing.T) *ID {
+	reserved := NewIdFromString("channel-root", testType, t)
+	err := RegisterType(testType, TypeInfo{
+		Name:     "channel",
+		Reserved: []ID{*reserved},
+		Validate: func(id *ID) error {
+			if id[0] == 0 {
+				return errors.New("first byte is zero")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register type: %+v", err)
+	}
+	t.Cleanup(func() { unregisterType(testType) })
+
+	return reserved
+}
+
+// unregisterType removes the type from the registry.
+func unregisterType(t Type) {
+	typeRegistry.mux.Lock()
+	defer typeRegistry.mux.Unlock()
+
+	if info, exists := typeRegistry.types[t]; exists {
+		delete(typeRegistry.names, strings.ToLower(info.Name))
+		delete(typeRegistry.types, t)
+	}
+}
+
+// Tests that the built-in types are registered with their hard coded IDs
+// reserved.
+func TestRegisteredTypes_BuiltIn(t *testing.T) {
+	expected := []Type{Generic, Gateway, Node, User, Group}
+	if types := RegisteredTypes(); !reflect.DeepEqual(types, expected) {
+		t.Errorf("Unexpected registered types.\nexpected: %v\nreceived: %v",
+			expected, types)
+	}
+
+	for _, hardCoded := range GetHardCodedIDs() {
+		if !IsReserved(hardCoded) {
+			t.Errorf("Hard coded ID %s is not reserved.", hardCoded)
+		}
+	}
+
+	if NumTypes.IsRegistered() {
+		t.Errorf("NumTypes is registered.")
+	}
+}
+
+// Tests that a registered type is used by Type.String, ParseType,
+// Type.UnmarshalJSON, IsReserved, and ID.Validate.
+func TestRegisterType(t *testing.T) {
+	reserved := registerTestType(t)
+
+	if testType.String() != "channel" {
+		t.Errorf("Unexpected name: %s", testType)
+	}
+
+	for _, s := range []string{"channel", "CHANNEL", "200"} {
+		if parsed, err := ParseType(s); err != nil || parsed != testType {
+			t.Errorf("ParseType(%q) returned %d: %+v", s, parsed, err)
+		}
+	}
+
+	var parsed []Type
+	err := json.Unmarshal([]byte(`["channel", 200, "node", 2]`), &parsed)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %+v", err)
+	}
+	if expected := []Type{testType, testType, Node, Node}; !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("Unexpected types.\nexpected: %v\nreceived: %v", expected, parsed)
+	}
+
+	if !IsReserved(reserved) {
+		t.Errorf("Reserved ID %s is not reserved.", reserved)
+	}
+
+	if err = reserved.Validate(); err != nil {
+		t.Errorf("Valid ID failed validation: %+v", err)
+	}
+	invalid := NewIdFromString("", testType, t)
+	if err = invalid.Validate(); err == nil ||
+		!strings.Contains(err.Error(), "first byte is zero") {
+		t.Errorf("Unexpected error for invalid ID: %+v", err)
+	}
+}
+
+// Error path: Tests that RegisterType rejects registrations that clash with
+// existing types or are invalid.
+func TestRegisterType_Error(t *testing.T) {
+	registerTestType(t)
+
+	tests := []struct {
+		t    Type
+		info TypeInfo
+		err  string
+	}{
+		{Node, TypeInfo{Name: "relay"}, "already registered"},
+		{testType, TypeInfo{Name: "relay"}, "already registered"},
+		{201, TypeInfo{Name: "Channel"}, "already used"},
+		{201, TypeInfo{Name: "gateway"}, "already used"},
+		{201, TypeInfo{Name: ""}, "invalid ID type name"},
+		{201, TypeInfo{Name: "7days"}, "invalid ID type name"},
+		{201, TypeInfo{Name: "has space"}, "invalid ID type name"},
+		{201, TypeInfo{Name: "relay", Reserved: []ID{Permissioning}},
+			"expected type 201"},
+		{201, TypeInfo{Name: "relay", Reserved: []ID{
+			*NewIdFromUInt(1, 201, t), *NewIdFromUInt(1, 201, t)}},
+			"more than once"},
+	}
+
+	for i, tt := range tests {
+		err := RegisterType(tt.t, tt.info)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Unexpected error for registration %d."+
+				"\nexpected: %s\nreceived: %+v", i, tt.err, err)
+		}
+	}
+
+	if Type(201).IsRegistered() {
+		t.Errorf("Rejected type was registered.")
+	}
+}
+
+// Error path: Tests that ParseType, Type.Validate, and ID.Validate reject
+// unregistered types.
+func TestParseType_Unknown(t *testing.T) {
+	for _, s := range []string{"channel", "200", "5", "256", ""} {
+		if _, err := ParseType(s); err == nil {
+			t.Errorf("ParseType(%q) did not return an error.", s)
+		}
+	}
+
+	if err := testType.Validate(); err == nil {
+		t.Errorf("Type.Validate did not return an error for an unknown type.")
+	}
+	if err := NewIdFromUInt(1, testType, t).Validate(); err == nil {
+		t.Errorf("Validate did not return an error for an unknown type.")
+	}
+}
+
+// Tests that Type.UnmarshalJSON and Type.UnmarshalText accept any byte value,
+// including unregistered types, and only reject unknown names and values that
+// are not bytes.
+func TestType_UnmarshalJSON_Unregistered(t *testing.T) {
+	var parsed []Type
+	err := json.Unmarshal([]byte(`[200, "200", 255, "node"]`), &parsed)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal unregistered types: %+v", err)
+	}
+	expected := []Type{testType, testType, 255, Node}
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("Unexpected types.\nexpected: %v\nreceived: %v", expected, parsed)
+	}
+	if testType.IsRegistered() {
+		t.Errorf("Type %d should not be registered.", testType)
+	}
+
+	var single Type
+	for _, data := range []string{`"channel"`, `256`, `-1`, `{}`, `""`} {
+		if err = json.Unmarshal([]byte(data), &single); err == nil {
+			t.Errorf("UnmarshalJSON(%s) did not return an error.", data)
+		}
+	}
+	if err = single.UnmarshalText([]byte("7")); err != nil || single != 7 {
+		t.Errorf("UnmarshalText returned %d: %+v", single, err)
+	}
+}
@@ -0,0 +1,162 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// addressSpace.go contains queries on the AddressSpace schedule, which lists
+// the ephemeral ID size in bits that takes effect at each timestamp.
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/id/ephemeral"
+)
+
+// Error messages.
+const (
+	addressSpaceSizeErr    = "AddressSpace[%d]: size %d is not in the range [%d, %d]"
+	addressSpaceInvalidErr = "invalid address space schedule: %s"
+	addressSpaceEmptyErr   = "address space schedule is empty"
+	addressSpaceBeforeErr  = "no address space size in effect at %s; the " +
+		"schedule starts at %s"
+	addressSpaceWindowErr = "window end %s is before start %s"
+)
+
+// EphemeralIdentity is an ephemeral ID and the address space size it was
+// generated with.
+type EphemeralIdentity struct {
+	ephemeral.ProtoIdentity
+	AddressSize uint8
+}
+
+// ValidateAddressSpace checks that the AddressSpace timestamps are strictly
+// increasing and that every size is within the range supported by the
+// ephemeral package. Returns an error describing every problem or nil if the
+// schedule is valid.
+func (ndf *NetworkDefinition) ValidateAddressSpace() error {
+	errs := validateAddressSpace(nil, ndf.AddressSpace)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.Errorf(addressSpaceInvalidErr, strings.Join(msgs, "; "))
+}
+
+// AddressSpaceSize returns the address space size in effect at the given
+// time, which is the size of the newest entry with a timestamp at or before
+// it. An error is returned if the schedule is invalid or starts after the
+// time.
+func (ndf *NetworkDefinition) AddressSpaceSize(t time.Time) (uint8, error) {
+	i, err := ndf.addressSpaceIndex(t)
+	if err != nil {
+		return 0, err
+	}
+	return ndf.AddressSpace[i].Size, nil
+}
+
+// AddressSpaceTransitions returns the entries of the schedule that take effect
+// at or after start and before end.
+func (ndf *NetworkDefinition) AddressSpaceTransitions(
+	start, end time.Time) ([]AddressSpace, error) {
+	if err := ndf.ValidateAddressSpace(); err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, errors.Errorf(addressSpaceWindowErr, end, start)
+	}
+
+	var transitions []AddressSpace
+	for _, as := range ndf.AddressSpace {
+		if !as.Timestamp.Before(start) && as.Timestamp.Before(end) {
+			transitions = append(transitions, as)
+		}
+	}
+	return transitions, nil
+}
+
+// GetEphemeralIds returns the ephemeral IDs of the ID for the time range
+// starting at the timestamp, using the address space size in effect at each
+// point in the range. When the size changes within a rotation period, the IDs
+// for that period are returned for both sizes.
+func (ndf *NetworkDefinition) GetEphemeralIds(userID *id.ID,
+	timestamp time.Time, timeRange time.Duration) ([]EphemeralIdentity, error) {
+	i, err := ndf.addressSpaceIndex(timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []EphemeralIdentity
+	end := timestamp.Add(timeRange)
+	for start := timestamp; start.Before(end); i++ {
+		// The segment ends at the next size change or the end of the range
+		segmentEnd := end
+		if i+1 < len(ndf.AddressSpace) &&
+			ndf.AddressSpace[i+1].Timestamp.Before(end) {
+			segmentEnd = ndf.AddressSpace[i+1].Timestamp
+		}
+
+		size := ndf.AddressSpace[i].Size
+		protoIds, err := ephemeral.GetIdsByRange(
+			userID, uint(size), start, segmentEnd.Sub(start))
+		if err != nil {
+			return nil, err
+		}
+		for _, protoId := range protoIds {
+			identities = append(identities,
+				EphemeralIdentity{ProtoIdentity: protoId, AddressSize: size})
+		}
+
+		start = segmentEnd
+	}
+
+	return identities, nil
+}
+
+// addressSpaceIndex returns the index of the schedule entry in effect at the
+// time.
+func (ndf *NetworkDefinition) addressSpaceIndex(t time.Time) (int, error) {
+	if err := ndf.ValidateAddressSpace(); err != nil {
+		return 0, err
+	}
+	if len(ndf.AddressSpace) == 0 {
+		return 0, errors.New(addressSpaceEmptyErr)
+	}
+
+	i := sort.Search(len(ndf.AddressSpace), func(i int) bool {
+		return ndf.AddressSpace[i].Timestamp.After(t)
+	})
+	if i == 0 {
+		return 0, errors.Errorf(
+			addressSpaceBeforeErr, t, ndf.AddressSpace[0].Timestamp)
+	}
+	return i - 1, nil
+}
+
+// validateAddressSpace checks the order and sizes of the schedule entries and
+// appends any problems to errs.
+func validateAddressSpace(errs []error, schedule []AddressSpace) []error {
+	for i, as := range schedule {
+		if as.Size < ephemeral.MinSize || as.Size > ephemeral.MaxSize {
+			errs = append(errs, errors.Errorf(addressSpaceSizeErr,
+				i, as.Size, ephemeral.MinSize, ephemeral.MaxSize))
+		}
+		if i > 0 && !as.Timestamp.After(schedule[i-1].Timestamp) {
+			errs = append(errs, errors.Errorf(
+				addressSpaceOrderErr, i, as.Timestamp, schedule[i-1].Timestamp))
+		}
+	}
+	return errs
+}
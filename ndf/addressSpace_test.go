@@ -0,0 +1,205 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/id/ephemeral"
+)
+
+// newScheduleNdf returns an NDF whose address space grows from 16 to 18 bits
+// at the given times.
+func newScheduleNdf(start time.Time, changes ...time.Duration) *NetworkDefinition {
+	netDef := &NetworkDefinition{}
+	netDef.AddressSpace = append(netDef.AddressSpace,
+		AddressSpace{Size: 16, Timestamp: start})
+	for i, change := range changes {
+		netDef.AddressSpace = append(netDef.AddressSpace,
+			AddressSpace{Size: uint8(17 + i), Timestamp: start.Add(change)})
+	}
+	return netDef
+}
+
+// Tests that NetworkDefinition.ValidateAddressSpace accepts a valid schedule
+// and reports every problem in an invalid one.
+func TestNetworkDefinition_ValidateAddressSpace(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	netDef := newScheduleNdf(start, time.Hour, 2*time.Hour)
+	if err := netDef.ValidateAddressSpace(); err != nil {
+		t.Errorf("ValidateAddressSpace returned an error: %+v", err)
+	}
+
+	netDef.AddressSpace[0].Size = 0
+	netDef.AddressSpace[1].Size = 65
+	netDef.AddressSpace[2].Timestamp = start
+	err := netDef.ValidateAddressSpace()
+	if err == nil {
+		t.Fatalf("ValidateAddressSpace did not return an error.")
+	}
+	for _, expected := range []string{
+		"AddressSpace[0]: size 0", "AddressSpace[1]: size 65",
+		"AddressSpace[2]: timestamp"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Error missing %q: %s", expected, err)
+		}
+	}
+}
+
+// Tests that NetworkDefinition.AddressSpaceSize returns the size in effect at
+// each time.
+func TestNetworkDefinition_AddressSpaceSize(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	netDef := newScheduleNdf(start, time.Hour, 2*time.Hour)
+
+	for _, tt := range []struct {
+		offset   time.Duration
+		expected uint8
+	}{
+		{0, 16},
+		{time.Hour - time.Nanosecond, 16},
+		{time.Hour, 17},
+		{2 * time.Hour, 18},
+		{1000 * time.Hour, 18},
+	} {
+		size, err := netDef.AddressSpaceSize(start.Add(tt.offset))
+		if err != nil {
+			t.Errorf("AddressSpaceSize(%s) returned an error: %+v", tt.offset, err)
+		} else if size != tt.expected {
+			t.Errorf("Unexpected size at %s.\nexpected: %d\nreceived: %d",
+				tt.offset, tt.expected, size)
+		}
+	}
+
+	if _, err := netDef.AddressSpaceSize(start.Add(-time.Second)); err == nil {
+		t.Errorf("No error for a time before the schedule.")
+	}
+	if _, err := (&NetworkDefinition{}).AddressSpaceSize(start); err == nil {
+		t.Errorf("No error for an empty schedule.")
+	}
+}
+
+// Tests that NetworkDefinition.AddressSpaceTransitions returns the entries
+// taking effect within the window.
+func TestNetworkDefinition_AddressSpaceTransitions(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	netDef := newScheduleNdf(start, time.Hour, 2*time.Hour)
+
+	transitions, err := netDef.AddressSpaceTransitions(
+		start.Add(time.Minute), start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("AddressSpaceTransitions returned an error: %+v", err)
+	}
+	if !reflect.DeepEqual(transitions, netDef.AddressSpace[1:2]) {
+		t.Errorf("Unexpected transitions: %+v", transitions)
+	}
+
+	transitions, err = netDef.AddressSpaceTransitions(start, start)
+	if err != nil || len(transitions) != 0 {
+		t.Errorf("Unexpected result for empty window: %+v, %+v", transitions, err)
+	}
+
+	_, err = netDef.AddressSpaceTransitions(start, start.Add(-time.Second))
+	if err == nil {
+		t.Errorf("No error for a window that ends before it starts.")
+	}
+}
+
+// Tests that ephemeral.GetId works in a package that does not import the
+// BLAKE2b hash itself, i.e., that the ephemeral package registers the hash
+// used by version 1 of its scheme.
+func TestEphemeralGetId_HashRegistered(t *testing.T) {
+	if err := ephemeral.V1().Validate(); err != nil {
+		t.Errorf("Version 1 of the ephemeral scheme is invalid: %+v", err)
+	}
+
+	userID := id.NewIdFromString("zezima", id.User, t)
+	_, _, _, err := ephemeral.GetId(userID, 16, time.Now().UnixNano())
+	if err != nil {
+		t.Errorf("GetId returned an error: %+v", err)
+	}
+}
+
+// Tests that NetworkDefinition.GetEphemeralIds matches
+// ephemeral.GetIdsByRange when the size does not change.
+func TestNetworkDefinition_GetEphemeralIds(t *testing.T) {
+	userID := id.NewIdFromString("zezima", id.User, t)
+	start := time.Unix(1700000000, 0)
+	netDef := newScheduleNdf(start.Add(-time.Hour))
+	timeRange := 3 * time.Duration(ephemeral.Period)
+
+	identities, err := netDef.GetEphemeralIds(userID, start, timeRange)
+	if err != nil {
+		t.Fatalf("GetEphemeralIds returned an error: %+v", err)
+	}
+
+	expected, err := ephemeral.GetIdsByRange(userID, 16, start, timeRange)
+	if err != nil {
+		t.Fatalf("GetIdsByRange returned an error: %+v", err)
+	}
+
+	if len(identities) != len(expected) {
+		t.Fatalf("Expected %d identities, received %d.",
+			len(expected), len(identities))
+	}
+	for i, identity := range identities {
+		if identity.ProtoIdentity != expected[i] || identity.AddressSize != 16 {
+			t.Errorf("Unexpected identity %d.\nexpected: %+v\nreceived: %+v",
+				i, expected[i], identity)
+		}
+	}
+}
+
+// Tests that NetworkDefinition.GetEphemeralIds switches size at a size change
+// and returns the IDs of the period containing the change for both sizes.
+func TestNetworkDefinition_GetEphemeralIds_SizeChange(t *testing.T) {
+	userID := id.NewIdFromString("zezima", id.User, t)
+	start := time.Unix(1700000000, 0)
+	change := start.Add(36 * time.Hour)
+	netDef := newScheduleNdf(start, change.Sub(start))
+	timeRange := 3 * time.Duration(ephemeral.Period)
+
+	identities, err := netDef.GetEphemeralIds(userID, start, timeRange)
+	if err != nil {
+		t.Fatalf("GetEphemeralIds returned an error: %+v", err)
+	}
+
+	var straddling []EphemeralIdentity
+	for _, identity := range identities {
+		expected, _, _, err := ephemeral.GetId(
+			userID, uint(identity.AddressSize), identity.Start.UnixNano())
+		if err != nil {
+			t.Fatalf("GetId returned an error: %+v", err)
+		}
+		if identity.Id != expected {
+			t.Errorf("Identity %+v does not match its size.", identity)
+		}
+
+		if identity.Start.Before(change) && identity.End.After(change) {
+			straddling = append(straddling, identity)
+		}
+		if !identity.End.After(change) && identity.AddressSize != 16 {
+			t.Errorf("Identity before the change has size %d.",
+				identity.AddressSize)
+		}
+		if !identity.Start.Before(change) && identity.AddressSize != 17 {
+			t.Errorf("Identity after the change has size %d.",
+				identity.AddressSize)
+		}
+	}
+
+	if len(straddling) != 2 || straddling[0].AddressSize != 16 ||
+		straddling[1].AddressSize != 17 {
+		t.Errorf("Expected the period containing the change for both sizes: "+
+			"%+v", straddling)
+	}
+}
@@ -0,0 +1,423 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// canonical.go contains the versioned canonical binary encoding of the
+// NetworkDefinition. Unlike Serialize, every field is included and every
+// variable-length value is prefixed with its length, so two different NDFs
+// never produce the same bytes.
+//
+// The encoding starts with a single version byte followed by each field of the
+// NetworkDefinition in declaration order:
+//   - byte slices and strings are a uvarint length followed by the data
+//   - lists are a uvarint count followed by each element
+//   - timestamps are the instant only, as big-endian int64 seconds and uint32
+//     nanoseconds since the Unix epoch, so the zone of the time.Time does not
+//     change the encoding
+//   - SchemaVersion is a big-endian uint32
+//   - region.GeoBin, Status, and AddressSpace.Size are a single byte
+//   - the RateLimiting values are big-endian uint64s
+//
+// Empty and nil byte slices, strings, and lists encode identically and are
+// always decoded as nil.
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/xx_network/primitives/region"
+)
+
+// CanonicalVersion is the version of the canonical encoding produced by
+// SerializeCanonical.
+const CanonicalVersion = 1
+
+// Error messages.
+const (
+	canonicalEmptyErr    = "canonical NDF data is empty"
+	canonicalVersionErr  = "unsupported canonical NDF version %d; expected %d"
+	canonicalTrailingErr = "canonical NDF data has %d unexpected trailing bytes"
+	encodedShortErr      = "encoded NDF data ended unexpectedly reading %s"
+	encodedLengthErr     = "invalid length for %s in encoded NDF data"
+	encodedVarintErr     = "invalid varint for %s in encoded NDF data"
+	encodedTimeErr       = "invalid nanoseconds %d for %s in encoded NDF data"
+)
+
+// SerializeCanonical returns the canonical binary encoding of the
+// NetworkDefinition. It covers every field and can be decoded with
+// DeserializeCanonical.
+func (ndf *NetworkDefinition) SerializeCanonical() []byte {
+	var w canonicalWriter
+	w.byte(CanonicalVersion)
+
+	w.uint32(ndf.SchemaVersion)
+	w.time(ndf.Timestamp)
+
+	w.count(len(ndf.Gateways))
+	for _, gw := range ndf.Gateways {
+		w.bytes(gw.ID)
+		w.string(gw.Address)
+		w.string(gw.TlsCertificate)
+		w.byte(byte(gw.Bin))
+	}
+
+	w.count(len(ndf.Nodes))
+	for _, node := range ndf.Nodes {
+		w.bytes(node.ID)
+		w.string(node.Address)
+		w.string(node.TlsCertificate)
+		w.bytes(node.Ed25519)
+		w.byte(byte(node.Status))
+	}
+
+	w.string(ndf.Registration.Address)
+	w.string(ndf.Registration.ClientRegistrationAddress)
+	w.string(ndf.Registration.TlsCertificate)
+	w.string(ndf.Registration.EllipticPubKey)
+
+	w.string(ndf.Notification.Address)
+	w.string(ndf.Notification.TlsCertificate)
+
+	w.bytes(ndf.UDB.ID)
+	w.string(ndf.UDB.Cert)
+	w.string(ndf.UDB.Address)
+	w.bytes(ndf.UDB.DhPubKey)
+	w.bytes(ndf.UDB.ChannelSigningPubKeyEd25519)
+
+	w.group(ndf.E2E)
+	w.group(ndf.CMIX)
+
+	w.count(len(ndf.AddressSpace))
+	for _, as := range ndf.AddressSpace {
+		w.byte(as.Size)
+		w.time(as.Timestamp)
+	}
+
+	w.string(ndf.ClientVersion)
+
+	w.count(len(ndf.WhitelistedIds))
+	for _, s := range ndf.WhitelistedIds {
+		w.string(s)
+	}
+
+	w.count(len(ndf.WhitelistedIpAddresses))
+	for _, s := range ndf.WhitelistedIpAddresses {
+		w.string(s)
+	}
+
+	w.uint64(uint64(ndf.RateLimits.Capacity))
+	w.uint64(uint64(ndf.RateLimits.LeakedTokens))
+	w.uint64(ndf.RateLimits.LeakDuration)
+
+	return w.buf
+}
+
+// DeserializeCanonical decodes data produced by SerializeCanonical into a
+// NetworkDefinition. An error is returned if the data is truncated, has
+// trailing bytes, or is of an unsupported version.
+func DeserializeCanonical(data []byte) (*NetworkDefinition, error) {
+	if len(data) == 0 {
+		return nil, errors.New(canonicalEmptyErr)
+	}
+
+	r := &canonicalReader{buf: data}
+	if v := r.byte("version"); r.err == nil && v != CanonicalVersion {
+		return nil, errors.Errorf(canonicalVersionErr, v, CanonicalVersion)
+	}
+
+	ndf := &NetworkDefinition{}
+	ndf.SchemaVersion = r.uint32("SchemaVersion")
+	ndf.Timestamp = r.time("Timestamp")
+
+	if n := r.count("Gateways"); n > 0 {
+		ndf.Gateways = make([]Gateway, n)
+		for i := range ndf.Gateways {
+			ndf.Gateways[i] = Gateway{
+				ID:             r.bytes("Gateway.ID"),
+				Address:        r.string("Gateway.Address"),
+				TlsCertificate: r.string("Gateway.TlsCertificate"),
+				Bin:            region.GeoBin(r.byte("Gateway.Bin")),
+			}
+		}
+	}
+
+	if n := r.count("Nodes"); n > 0 {
+		ndf.Nodes = make([]Node, n)
+		for i := range ndf.Nodes {
+			ndf.Nodes[i] = Node{
+				ID:             r.bytes("Node.ID"),
+				Address:        r.string("Node.Address"),
+				TlsCertificate: r.string("Node.TlsCertificate"),
+				Ed25519:        r.bytes("Node.Ed25519"),
+				Status:         Status(r.byte("Node.Status")),
+			}
+		}
+	}
+
+	ndf.Registration = Registration{
+		Address:                   r.string("Registration.Address"),
+		ClientRegistrationAddress: r.string("Registration.ClientRegistrationAddress"),
+		TlsCertificate:            r.string("Registration.TlsCertificate"),
+		EllipticPubKey:            r.string("Registration.EllipticPubKey"),
+	}
+
+	ndf.Notification = Notification{
+		Address:        r.string("Notification.Address"),
+		TlsCertificate: r.string("Notification.TlsCertificate"),
+	}
+
+	ndf.UDB = UDB{
+		ID:                          r.bytes("UDB.ID"),
+		Cert:                        r.string("UDB.Cert"),
+		Address:                     r.string("UDB.Address"),
+		DhPubKey:                    r.bytes("UDB.DhPubKey"),
+		ChannelSigningPubKeyEd25519: r.bytes("UDB.ChannelSigningPubKeyEd25519"),
+	}
+
+	ndf.E2E = r.group("E2E")
+	ndf.CMIX = r.group("CMIX")
+
+	if n := r.count("AddressSpace"); n > 0 {
+		ndf.AddressSpace = make([]AddressSpace, n)
+		for i := range ndf.AddressSpace {
+			ndf.AddressSpace[i] = AddressSpace{
+				Size:      r.byte("AddressSpace.Size"),
+				Timestamp: r.time("AddressSpace.Timestamp"),
+			}
+		}
+	}
+
+	ndf.ClientVersion = r.string("ClientVersion")
+	ndf.WhitelistedIds = r.strings("WhitelistedIds")
+	ndf.WhitelistedIpAddresses = r.strings("WhitelistedIpAddresses")
+
+	ndf.RateLimits = RateLimiting{
+		Capacity:     uint(r.uint64("RateLimits.Capacity")),
+		LeakedTokens: uint(r.uint64("RateLimits.LeakedTokens")),
+		LeakDuration: r.uint64("RateLimits.LeakDuration"),
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if len(r.buf) != 0 {
+		return nil, errors.Errorf(canonicalTrailingErr, len(r.buf))
+	}
+
+	return ndf, nil
+}
+
+// Digest returns the SHA-256 hash of the canonical encoding of the
+// NetworkDefinition.
+func (ndf *NetworkDefinition) Digest() []byte {
+	h := sha256.Sum256(ndf.SerializeCanonical())
+	return h[:]
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Writer                                                                     //
+////////////////////////////////////////////////////////////////////////////////
+
+// canonicalWriter appends canonically encoded values to a buffer.
+type canonicalWriter struct {
+	buf []byte
+}
+
+func (w *canonicalWriter) byte(b byte) {
+	w.buf = append(w.buf, b)
+}
+
+func (w *canonicalWriter) count(n int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(n))
+}
+
+func (w *canonicalWriter) bytes(b []byte) {
+	w.count(len(b))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *canonicalWriter) string(s string) {
+	w.count(len(s))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *canonicalWriter) uvarint(u uint64) {
+	w.buf = binary.AppendUvarint(w.buf, u)
+}
+
+func (w *canonicalWriter) uint32(u uint32) {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, u)
+}
+
+func (w *canonicalWriter) uint64(u uint64) {
+	w.buf = binary.BigEndian.AppendUint64(w.buf, u)
+}
+
+func (w *canonicalWriter) time(t time.Time) {
+	w.uint64(uint64(t.Unix()))
+	w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(t.Nanosecond()))
+}
+
+func (w *canonicalWriter) group(g Group) {
+	w.string(g.Prime)
+	w.string(g.SmallPrime)
+	w.string(g.Generator)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Reader                                                                     //
+////////////////////////////////////////////////////////////////////////////////
+
+// canonicalReader consumes canonically encoded values from a buffer. After the
+// first error, all reads return zero values and err holds the error.
+type canonicalReader struct {
+	buf []byte
+	err error
+}
+
+func (r *canonicalReader) byte(field string) byte {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 1 {
+		r.err = errors.Errorf(encodedShortErr, field)
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *canonicalReader) count(field string) int {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(r.buf)
+	if !minimalUvarint(n, size) || n > math.MaxInt32 ||
+		n > uint64(len(r.buf)) {
+		r.err = errors.Errorf(encodedLengthErr, field)
+		return 0
+	}
+	r.buf = r.buf[size:]
+	return int(n)
+}
+
+func (r *canonicalReader) next(field string) []byte {
+	n := r.count(field)
+	if r.err != nil || n == 0 {
+		return nil
+	}
+	if n > len(r.buf) {
+		r.err = errors.Errorf(encodedShortErr, field)
+		return nil
+	}
+	b := r.buf[:n:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *canonicalReader) bytes(field string) []byte {
+	b := r.next(field)
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (r *canonicalReader) string(field string) string {
+	return string(r.next(field))
+}
+
+func (r *canonicalReader) strings(field string) []string {
+	n := r.count(field)
+	if n == 0 {
+		return nil
+	}
+	list := make([]string, n)
+	for i := range list {
+		list[i] = r.string(field)
+	}
+	return list
+}
+
+func (r *canonicalReader) uvarint(field string) uint64 {
+	if r.err != nil {
+		return 0
+	}
+	u, size := binary.Uvarint(r.buf)
+	if !minimalUvarint(u, size) {
+		r.err = errors.Errorf(encodedVarintErr, field)
+		return 0
+	}
+	r.buf = r.buf[size:]
+	return u
+}
+
+func (r *canonicalReader) uint32(field string) uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 4 {
+		r.err = errors.Errorf(encodedShortErr, field)
+		return 0
+	}
+	u := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return u
+}
+
+func (r *canonicalReader) uint64(field string) uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 8 {
+		r.err = errors.Errorf(encodedShortErr, field)
+		return 0
+	}
+	u := binary.BigEndian.Uint64(r.buf)
+	r.buf = r.buf[8:]
+	return u
+}
+
+func (r *canonicalReader) time(field string) time.Time {
+	sec := int64(r.uint64(field))
+	if r.err != nil {
+		return time.Time{}
+	}
+	if len(r.buf) < 4 {
+		r.err = errors.Errorf(encodedShortErr, field)
+		return time.Time{}
+	}
+	nsec := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	if nsec >= uint32(time.Second) {
+		r.err = errors.Errorf(encodedTimeErr, nsec, field)
+		return time.Time{}
+	}
+	return time.Unix(sec, int64(nsec)).UTC()
+}
+
+// minimalUvarint returns true if binary.Uvarint read the value from size bytes
+// and the value has no shorter encoding, so that every value has exactly one
+// encoding.
+func minimalUvarint(v uint64, size int) bool {
+	return size > 0 && size == len(binary.AppendUvarint(nil, v))
+}
+
+func (r *canonicalReader) group(field string) Group {
+	return Group{
+		Prime:      r.string(field + ".Prime"),
+		SmallPrime: r.string(field + ".SmallPrime"),
+		Generator:  r.string(field + ".Generator"),
+	}
+}
@@ -0,0 +1,280 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/xx_network/primitives/region"
+)
+
+// Tests that an NDF encoded with NetworkDefinition.SerializeCanonical and
+// decoded with DeserializeCanonical matches the original.
+func TestNetworkDefinition_SerializeCanonical_DeserializeCanonical(t *testing.T) {
+	for i, netDef := range []*NetworkDefinition{
+		newFullTestNdf(t), exampleNdf(t),
+		{},
+	} {
+		data := netDef.SerializeCanonical()
+
+		decoded, err := DeserializeCanonical(data)
+		if err != nil {
+			t.Errorf("Failed to decode NDF #%d: %+v", i, err)
+			continue
+		}
+
+		if !netDef.Equal(decoded) {
+			t.Errorf("Decoded NDF #%d does not match original."+
+				"\nexpected: %+v\nreceived: %+v", i, netDef, decoded)
+		}
+	}
+}
+
+// Tests that an NDF with a local timestamp has the same digest after a JSON
+// round trip, which decodes the timestamp with a fixed zone offset.
+func TestNetworkDefinition_Digest_JSON(t *testing.T) {
+	for _, loc := range []*time.Location{
+		time.Local, time.UTC, time.FixedZone("EST", -5*3600)} {
+		netDef := newFullTestNdf(t)
+		netDef.Timestamp = time.Now().In(loc)
+		netDef.AddressSpace[0].Timestamp = time.Now().In(loc)
+
+		data, err := netDef.Marshal()
+		if err != nil {
+			t.Fatalf("Failed to marshal NDF: %+v", err)
+		}
+		decoded, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal NDF: %+v", err)
+		}
+
+		if !bytes.Equal(netDef.Digest(), decoded.Digest()) {
+			t.Errorf("Digest changed after a JSON round trip in %s.", loc)
+		}
+		if !netDef.Equal(decoded) {
+			t.Errorf("NDF not equal after a JSON round trip in %s.", loc)
+		}
+	}
+}
+
+// Tests that the same instant in different zones has the same canonical
+// encoding.
+func TestNetworkDefinition_SerializeCanonical_Zone(t *testing.T) {
+	a := newFullTestNdf(t)
+	b := newFullTestNdf(t)
+	b.Timestamp = b.Timestamp.In(time.FixedZone("A", 7200))
+	if !bytes.Equal(a.SerializeCanonical(), b.SerializeCanonical()) {
+		t.Errorf("Zone changed the canonical encoding.")
+	}
+}
+
+// Tests that NetworkDefinition.SerializeCanonical returns the same bytes for
+// the same NDF.
+func TestNetworkDefinition_SerializeCanonical_Consistency(t *testing.T) {
+	a := newFullTestNdf(t).SerializeCanonical()
+	b := newFullTestNdf(t).SerializeCanonical()
+	if !bytes.Equal(a, b) {
+		t.Errorf("Canonical encodings of identical NDFs differ."+
+			"\nfirst:  %v\nsecond: %v", a, b)
+	}
+}
+
+// Tests that two NDFs that produce identical output from the legacy Serialize
+// produce different canonical encodings.
+func TestNetworkDefinition_SerializeCanonical_NoCollision(t *testing.T) {
+	a := &NetworkDefinition{
+		Gateways: []Gateway{{Address: "1.2.3.4", TlsCertificate: "cert"}}}
+	b := &NetworkDefinition{
+		Gateways: []Gateway{{Address: "1.2.3.4c", TlsCertificate: "ert"}}}
+
+	if !bytes.Equal(a.Serialize(), b.Serialize()) {
+		t.Fatalf("Test NDFs must collide with Serialize.")
+	}
+
+	if bytes.Equal(a.SerializeCanonical(), b.SerializeCanonical()) {
+		t.Errorf("SerializeCanonical produced the same bytes for different "+
+			"NDFs: %v", a.SerializeCanonical())
+	}
+}
+
+// Tests that changing any single field of the NDF changes its canonical
+// encoding, including the fields skipped by the legacy Serialize.
+func TestNetworkDefinition_SerializeCanonical_EveryField(t *testing.T) {
+	modifiers := map[string]func(ndf *NetworkDefinition){
+		"Timestamp":        func(n *NetworkDefinition) { n.Timestamp = n.Timestamp.Add(1) },
+		"Gateway.ID":       func(n *NetworkDefinition) { n.Gateways[0].ID[0]++ },
+		"Gateway.Address":  func(n *NetworkDefinition) { n.Gateways[0].Address += "x" },
+		"Gateway.Tls":      func(n *NetworkDefinition) { n.Gateways[0].TlsCertificate += "x" },
+		"Gateway.Bin":      func(n *NetworkDefinition) { n.Gateways[0].Bin++ },
+		"Node.ID":          func(n *NetworkDefinition) { n.Nodes[0].ID[0]++ },
+		"Node.Address":     func(n *NetworkDefinition) { n.Nodes[0].Address += "x" },
+		"Node.Tls":         func(n *NetworkDefinition) { n.Nodes[0].TlsCertificate += "x" },
+		"Node.Ed25519":     func(n *NetworkDefinition) { n.Nodes[0].Ed25519[0]++ },
+		"Node.Status":      func(n *NetworkDefinition) { n.Nodes[0].Status = Stale },
+		"Reg.Address":      func(n *NetworkDefinition) { n.Registration.Address += "x" },
+		"Reg.ClientReg":    func(n *NetworkDefinition) { n.Registration.ClientRegistrationAddress += "x" },
+		"Reg.Tls":          func(n *NetworkDefinition) { n.Registration.TlsCertificate += "x" },
+		"Reg.Elliptic":     func(n *NetworkDefinition) { n.Registration.EllipticPubKey += "x" },
+		"Notif.Address":    func(n *NetworkDefinition) { n.Notification.Address += "x" },
+		"Notif.Tls":        func(n *NetworkDefinition) { n.Notification.TlsCertificate += "x" },
+		"UDB.ID":           func(n *NetworkDefinition) { n.UDB.ID[0]++ },
+		"UDB.Cert":         func(n *NetworkDefinition) { n.UDB.Cert += "x" },
+		"UDB.Address":      func(n *NetworkDefinition) { n.UDB.Address += "x" },
+		"UDB.DhPubKey":     func(n *NetworkDefinition) { n.UDB.DhPubKey[0]++ },
+		"UDB.Channel":      func(n *NetworkDefinition) { n.UDB.ChannelSigningPubKeyEd25519[0]++ },
+		"E2E.Prime":        func(n *NetworkDefinition) { n.E2E.Prime += "0" },
+		"E2E.SmallPrime":   func(n *NetworkDefinition) { n.E2E.SmallPrime += "0" },
+		"E2E.Generator":    func(n *NetworkDefinition) { n.E2E.Generator += "0" },
+		"CMIX.Prime":       func(n *NetworkDefinition) { n.CMIX.Prime += "0" },
+		"CMIX.SmallPrime":  func(n *NetworkDefinition) { n.CMIX.SmallPrime += "0" },
+		"CMIX.Generator":   func(n *NetworkDefinition) { n.CMIX.Generator += "0" },
+		"AddressSpace":     func(n *NetworkDefinition) { n.AddressSpace[0].Size++ },
+		"ClientVersion":    func(n *NetworkDefinition) { n.ClientVersion += "x" },
+		"WhitelistedIds":   func(n *NetworkDefinition) { n.WhitelistedIds[0] += "x" },
+		"WhitelistedIps":   func(n *NetworkDefinition) { n.WhitelistedIpAddresses[0] += "x" },
+		"RL.Capacity":      func(n *NetworkDefinition) { n.RateLimits.Capacity++ },
+		"RL.LeakedTokens":  func(n *NetworkDefinition) { n.RateLimits.LeakedTokens++ },
+		"RL.LeakDuration":  func(n *NetworkDefinition) { n.RateLimits.LeakDuration++ },
+		"Gateways.Removed": func(n *NetworkDefinition) { n.Gateways = n.Gateways[:1] },
+	}
+
+	original := newFullTestNdf(t).SerializeCanonical()
+	for name, modify := range modifiers {
+		netDef := newFullTestNdf(t)
+		modify(netDef)
+		if bytes.Equal(original, netDef.SerializeCanonical()) {
+			t.Errorf("Modifying %s did not change the canonical encoding.", name)
+		}
+	}
+}
+
+// Error path: Tests that DeserializeCanonical returns an error for every
+// truncation of valid data.
+func TestDeserializeCanonical_Truncated(t *testing.T) {
+	data := newFullTestNdf(t).SerializeCanonical()
+	for i := 0; i < len(data); i++ {
+		if _, err := DeserializeCanonical(data[:i]); err == nil {
+			t.Errorf("No error for data truncated to %d of %d bytes.",
+				i, len(data))
+		}
+	}
+}
+
+// Error path: Tests that DeserializeCanonical rejects trailing bytes.
+func TestDeserializeCanonical_TrailingBytes(t *testing.T) {
+	data := append(newFullTestNdf(t).SerializeCanonical(), 0)
+	expectedErr := fmt.Sprintf(canonicalTrailingErr, 1)
+	_, err := DeserializeCanonical(data)
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Unexpected error.\nexpected: %s\nreceived: %+v",
+			expectedErr, err)
+	}
+}
+
+// Error path: Tests that DeserializeCanonical rejects a count that is not
+// minimally encoded, so that every NDF has exactly one encoding.
+func TestDeserializeCanonical_NonMinimalCount(t *testing.T) {
+	data := (&NetworkDefinition{}).SerializeCanonical()
+
+	// The gateway count follows the version, schema version, and timestamp
+	i := 1 + 4 + 12
+	if data[i] != 0 {
+		t.Fatalf("Unexpected gateway count %d.", data[i])
+	}
+	padded := append(append(append([]byte{}, data[:i]...), 0x80, 0x00),
+		data[i+1:]...)
+
+	_, err := DeserializeCanonical(padded)
+	expectedErr := fmt.Sprintf(encodedLengthErr, "Gateways")
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Unexpected error.\nexpected: %s\nreceived: %+v",
+			expectedErr, err)
+	}
+}
+
+// Error path: Tests that DeserializeCanonical rejects an unknown version.
+func TestDeserializeCanonical_VersionError(t *testing.T) {
+	data := newFullTestNdf(t).SerializeCanonical()
+	data[0] = CanonicalVersion + 1
+	_, err := DeserializeCanonical(data)
+	if err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Unexpected error for invalid version: %+v", err)
+	}
+}
+
+// exampleNdf returns the NDF parsed from ExampleNDF.
+func exampleNdf(t testing.TB) *NetworkDefinition {
+	netDef, err := Unmarshal([]byte(ExampleNDF))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal example NDF: %+v", err)
+	}
+	return netDef
+}
+
+// newFullTestNdf returns an NDF with every field set to a non-zero value.
+func newFullTestNdf(t testing.TB) *NetworkDefinition {
+	ts := time.Date(2023, 5, 17, 12, 0, 0, 0, time.UTC)
+	netDef := &NetworkDefinition{
+		SchemaVersion: CurrentSchemaVersion,
+		Timestamp:     ts,
+		Registration: Registration{
+			Address:                   "registration.xx.network:11420",
+			ClientRegistrationAddress: "client-registration.xx.network:11420",
+			TlsCertificate:            "registration cert",
+			EllipticPubKey:            "elliptic key",
+		},
+		Notification: Notification{
+			Address:        "notification.xx.network:1234",
+			TlsCertificate: "notification cert",
+		},
+		UDB: UDB{
+			ID:                          []byte{1, 2, 3},
+			Cert:                        "udb cert",
+			Address:                     "udb.xx.network:18001",
+			DhPubKey:                    []byte{4, 5, 6},
+			ChannelSigningPubKeyEd25519: []byte{7, 8, 9},
+		},
+		E2E:  Group{Prime: "17", SmallPrime: "0B", Generator: "02"},
+		CMIX: Group{Prime: "2F", SmallPrime: "17", Generator: "03"},
+		AddressSpace: []AddressSpace{
+			{Size: 16, Timestamp: ts.Add(-time.Hour)},
+			{Size: 17, Timestamp: ts},
+		},
+		ClientVersion:          "4.6.3",
+		WhitelistedIds:         []string{"id1", "id2"},
+		WhitelistedIpAddresses: []string{"10.0.0.1"},
+		RateLimits: RateLimiting{
+			Capacity: 10, LeakedTokens: 3, LeakDuration: 1000},
+	}
+
+	for i := 0; i < 3; i++ {
+		nodeID := make([]byte, 33)
+		nodeID[0], nodeID[32] = byte(i+1), 2
+		gwID := make([]byte, 33)
+		gwID[0], gwID[32] = byte(i+1), 1
+		netDef.Nodes = append(netDef.Nodes, Node{
+			ID:             nodeID,
+			Address:        fmt.Sprintf("node%d.xx.network:11420", i),
+			TlsCertificate: fmt.Sprintf("node cert %d", i),
+			Ed25519:        []byte{byte(i), 1},
+			Status:         Active,
+		})
+		netDef.Gateways = append(netDef.Gateways, Gateway{
+			ID:             gwID,
+			Address:        fmt.Sprintf("gateway%d.xx.network:22840", i),
+			TlsCertificate: fmt.Sprintf("gateway cert %d", i),
+			Bin:            region.GeoBin(i),
+		})
+	}
+
+	return netDef
+}
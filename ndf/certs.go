@@ -0,0 +1,213 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// certs.go contains accessors that parse the certificates and keys stored in
+// the NDF and a report of certificates that are about to expire.
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxCachedCerts is the number of parsed certificates kept by the cache. When
+// it is full, the cache is cleared; NDF certificates change rarely, so this
+// only happens when many different NDFs are in use at once.
+const maxCachedCerts = 1024
+
+// Error messages.
+const (
+	emptyCertErr       = "no certificate"
+	emptyKeyErr        = "no public key"
+	decodeKeyErr       = "failed to decode base 64 public key"
+	invalidKeyLenErr   = "public key is %d bytes; expected %d"
+	certNotYetValidErr = "certificate is not valid until %s"
+)
+
+// certCache caches certificates parsed by ParseCertificate by their PEM
+// encoding.
+var certCache = struct {
+	certs map[string]*x509.Certificate
+	mux   sync.RWMutex
+}{certs: make(map[string]*x509.Certificate)}
+
+// ParseCertificate parses a PEM encoded x509 certificate. Parsed certificates
+// are cached, so the returned certificate is shared and must not be modified.
+func ParseCertificate(certPEM string) (*x509.Certificate, error) {
+	if certPEM == "" {
+		return nil, errors.New(emptyCertErr)
+	}
+
+	certCache.mux.RLock()
+	cert, exists := certCache.certs[certPEM]
+	certCache.mux.RUnlock()
+	if exists {
+		return cert, nil
+	}
+
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, errors.New(decodePemErr)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, parseCertErr)
+	}
+
+	certCache.mux.Lock()
+	if len(certCache.certs) >= maxCachedCerts {
+		certCache.certs = make(map[string]*x509.Certificate)
+	}
+	certCache.certs[certPEM] = cert
+	certCache.mux.Unlock()
+
+	return cert, nil
+}
+
+// GetCertificate returns the parsed TLS certificate of the gateway.
+func (gw *Gateway) GetCertificate() (*x509.Certificate, error) {
+	return ParseCertificate(gw.TlsCertificate)
+}
+
+// GetCertificate returns the parsed TLS certificate of the node.
+func (n *Node) GetCertificate() (*x509.Certificate, error) {
+	return ParseCertificate(n.TlsCertificate)
+}
+
+// GetEd25519 returns the Ed25519 public key of the node.
+func (n *Node) GetEd25519() (ed25519.PublicKey, error) {
+	return toEd25519(n.Ed25519)
+}
+
+// GetCertificate returns the parsed TLS certificate of the permissioning
+// server.
+func (r *Registration) GetCertificate() (*x509.Certificate, error) {
+	return ParseCertificate(r.TlsCertificate)
+}
+
+// GetEllipticPubKey returns the base 64 encoded Ed25519 public key of the
+// permissioning server.
+func (r *Registration) GetEllipticPubKey() (ed25519.PublicKey, error) {
+	if r.EllipticPubKey == "" {
+		return nil, errors.New(emptyKeyErr)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(r.EllipticPubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, decodeKeyErr)
+	}
+	return toEd25519(key)
+}
+
+// GetCertificate returns the parsed TLS certificate of the notification bot.
+func (n *Notification) GetCertificate() (*x509.Certificate, error) {
+	return ParseCertificate(n.TlsCertificate)
+}
+
+// GetCertificate returns the parsed certificate of user discovery.
+func (u *UDB) GetCertificate() (*x509.Certificate, error) {
+	return ParseCertificate(u.Cert)
+}
+
+// GetChannelSigningPubKey returns the Ed25519 public key user discovery uses
+// to sign channel identities.
+func (u *UDB) GetChannelSigningPubKey() (ed25519.PublicKey, error) {
+	return toEd25519(u.ChannelSigningPubKeyEd25519)
+}
+
+// CertificateStatus describes a certificate in the NDF returned by
+// NetworkDefinition.ExpiringCertificates.
+type CertificateStatus struct {
+	// Field is the path of the certificate in the NDF, in the format used by
+	// NetworkDefinition.Walk (e.g., "Gateways[2].TlsCertificate").
+	Field string
+
+	// ID is the ID of the gateway, node, or user discovery that the
+	// certificate belongs to. It is nil for the other certificates.
+	ID []byte
+
+	// NotAfter is the time the certificate expires. It is zero when the
+	// certificate could not be parsed.
+	NotAfter time.Time
+
+	// Err is set when the certificate could not be parsed or is not yet
+	// valid.
+	Err error
+}
+
+// Expired returns true if the certificate expired at or before the time.
+func (cs CertificateStatus) Expired(now time.Time) bool {
+	return cs.Err == nil && !now.Before(cs.NotAfter)
+}
+
+// ExpiringCertificates returns the status of every certificate in the NDF that
+// expires before now plus within, including certificates that have already
+// expired, ordered by the field order of the NDF. Certificates that cannot be
+// parsed or are not valid until after now are also returned, with Err set, so
+// that they are not silently missed. Empty optional certificates are skipped.
+func (ndf *NetworkDefinition) ExpiringCertificates(
+	now time.Time, within time.Duration) []CertificateStatus {
+	deadline := now.Add(within)
+
+	var report []CertificateStatus
+	check := func(field string, id []byte, certPEM string) {
+		cert, err := ParseCertificate(certPEM)
+		if err != nil {
+			report = append(report, CertificateStatus{Field: field, ID: id, Err: err})
+			return
+		}
+
+		if now.Before(cert.NotBefore) {
+			report = append(report, CertificateStatus{Field: field, ID: id,
+				NotAfter: cert.NotAfter,
+				Err:      errors.Errorf(certNotYetValidErr, cert.NotBefore)})
+		} else if cert.NotAfter.Before(deadline) {
+			report = append(report,
+				CertificateStatus{Field: field, ID: id, NotAfter: cert.NotAfter})
+		}
+	}
+
+	for i, gw := range ndf.Gateways {
+		field := "Gateways[" + strconv.Itoa(i) + "]"
+		check(field+".TlsCertificate", gw.ID, gw.TlsCertificate)
+	}
+	for i, node := range ndf.Nodes {
+		if node.TlsCertificate != "" {
+			field := "Nodes[" + strconv.Itoa(i) + "]"
+			check(field+".TlsCertificate", node.ID, node.TlsCertificate)
+		}
+	}
+	check("Registration.TlsCertificate", nil, ndf.Registration.TlsCertificate)
+	if ndf.Notification.TlsCertificate != "" {
+		check("Notification.TlsCertificate", nil, ndf.Notification.TlsCertificate)
+	}
+	if ndf.UDB.Cert != "" {
+		check("UDB.Cert", ndf.UDB.ID, ndf.UDB.Cert)
+	}
+
+	return report
+}
+
+// toEd25519 checks the length of the key and returns it as an Ed25519 public
+// key.
+func toEd25519(key []byte) (ed25519.PublicKey, error) {
+	if len(key) == 0 {
+		return nil, errors.New(emptyKeyErr)
+	} else if len(key) != ed25519.PublicKeySize {
+		return nil, errors.Errorf(invalidKeyLenErr, len(key), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(append([]byte{}, key...)), nil
+}
@@ -0,0 +1,159 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that the certificate accessors parse each certificate and return the
+// cached certificate on subsequent calls.
+func TestNetworkDefinition_GetCertificate(t *testing.T) {
+	now := time.Now()
+	_, certPEM := newTestRsaCert(t, now.Add(-time.Hour), now.Add(time.Hour))
+	netDef := &NetworkDefinition{
+		Gateways:     []Gateway{{TlsCertificate: certPEM}},
+		Nodes:        []Node{{TlsCertificate: certPEM}},
+		Registration: Registration{TlsCertificate: certPEM},
+		Notification: Notification{TlsCertificate: certPEM},
+		UDB:          UDB{Cert: certPEM},
+	}
+
+	for name, get := range map[string]func() (*x509.Certificate, error){
+		"Gateway":      netDef.Gateways[0].GetCertificate,
+		"Node":         netDef.Nodes[0].GetCertificate,
+		"Registration": netDef.Registration.GetCertificate,
+		"Notification": netDef.Notification.GetCertificate,
+		"UDB":          netDef.UDB.GetCertificate,
+	} {
+		cert, err := get()
+		if err != nil {
+			t.Errorf("Failed to get %s certificate: %+v", name, err)
+			continue
+		}
+		if cert.Subject.CommonName != "registration*.cmix.rip" {
+			t.Errorf("Unexpected %s certificate subject: %s", name, cert.Subject)
+		}
+		if cached, _ := get(); cached != cert {
+			t.Errorf("%s certificate was not cached.", name)
+		}
+	}
+}
+
+// Error path: Tests that ParseCertificate returns an error for empty and
+// invalid certificates.
+func TestParseCertificate_Error(t *testing.T) {
+	for certPEM, expected := range map[string]string{
+		"":        emptyCertErr,
+		"not PEM": decodePemErr,
+		"-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n": parseCertErr,
+	} {
+		_, err := ParseCertificate(certPEM)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Unexpected error for %q.\nexpected: %s\nreceived: %+v",
+				certPEM, expected, err)
+		}
+	}
+}
+
+// Tests that the Ed25519 key accessors return the keys and return an error for
+// keys of the wrong length.
+func TestNetworkDefinition_GetEd25519(t *testing.T) {
+	pubKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	node := Node{Ed25519: pubKey}
+	reg := Registration{EllipticPubKey: base64.StdEncoding.EncodeToString(pubKey)}
+	udb := UDB{ChannelSigningPubKeyEd25519: pubKey}
+
+	for name, get := range map[string]func() (ed25519.PublicKey, error){
+		"Node":         node.GetEd25519,
+		"Registration": reg.GetEllipticPubKey,
+		"UDB":          udb.GetChannelSigningPubKey,
+	} {
+		key, err := get()
+		if err != nil || !bytes.Equal(key, pubKey) {
+			t.Errorf("Unexpected %s key %x: %+v", name, key, err)
+		}
+	}
+
+	for _, reg = range []Registration{
+		{}, {EllipticPubKey: "not base 64"},
+		{EllipticPubKey: base64.StdEncoding.EncodeToString(pubKey[1:])},
+	} {
+		if _, err := reg.GetEllipticPubKey(); err == nil {
+			t.Errorf("No error for invalid key %q.", reg.EllipticPubKey)
+		}
+	}
+
+	if _, err := (&Node{Ed25519: []byte{1, 2, 3}}).GetEd25519(); err == nil {
+		t.Errorf("No error for short node key.")
+	}
+}
+
+// Tests that NetworkDefinition.ExpiringCertificates reports certificates that
+// expire within the duration and certificates that are invalid, and skips the
+// others.
+func TestNetworkDefinition_ExpiringCertificates(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, valid := newTestRsaCert(t, now.Add(-time.Hour), now.Add(90*24*time.Hour))
+	_, expiring := newTestRsaCert(t, now.Add(-time.Hour), now.Add(24*time.Hour))
+	_, expired := newTestRsaCert(t, now.Add(-2*time.Hour), now.Add(-time.Hour))
+	_, future := newTestRsaCert(t, now.Add(time.Hour), now.Add(90*24*time.Hour))
+
+	netDef := &NetworkDefinition{
+		Gateways: []Gateway{
+			{ID: []byte{1}, TlsCertificate: valid},
+			{ID: []byte{2}, TlsCertificate: expiring},
+		},
+		Nodes: []Node{
+			{ID: []byte{3}, TlsCertificate: expired},
+			{ID: []byte{4}},
+		},
+		Registration: Registration{TlsCertificate: "invalid"},
+		Notification: Notification{TlsCertificate: future},
+		UDB:          UDB{ID: []byte{5}, Cert: valid},
+	}
+
+	report := netDef.ExpiringCertificates(now, 7*24*time.Hour)
+
+	var fields []string
+	for _, cs := range report {
+		fields = append(fields, cs.Field)
+	}
+	expected := []string{"Gateways[1].TlsCertificate", "Nodes[0].TlsCertificate",
+		"Registration.TlsCertificate", "Notification.TlsCertificate"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Unexpected fields.\nexpected: %s\nreceived: %s",
+			expected, fields)
+	}
+
+	if !bytes.Equal(report[0].ID, []byte{2}) || report[0].Err != nil ||
+		!report[0].NotAfter.Equal(now.Add(24*time.Hour)) ||
+		report[0].Expired(now) {
+		t.Errorf("Unexpected status for expiring certificate: %+v", report[0])
+	}
+	if !report[1].Expired(now) {
+		t.Errorf("Expired certificate not reported as expired: %+v", report[1])
+	}
+	if report[2].Err == nil || report[3].Err == nil {
+		t.Errorf("Invalid certificates reported without an error: %+v, %+v",
+			report[2], report[3])
+	}
+
+	if report = netDef.ExpiringCertificates(now, 365*24*time.Hour); len(report) != 6 {
+		t.Errorf("Expected 6 certificates within a year, received %d: %+v",
+			len(report), report)
+	}
+}
@@ -0,0 +1,368 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// compact.go contains the compact binary wire format of the NetworkDefinition.
+// It is much smaller than the JSON form and is intended for clients that parse
+// the NDF on startup.
+//
+// The format starts with compactMagic and a version byte followed by the
+// fields in the same order as the canonical encoding (see canonical.go), with
+// these differences:
+//   - PEM certificates are stored as DER with a one-byte tag
+//   - hex group values are stored as raw bytes with a one-byte tag
+//   - SchemaVersion and the RateLimiting values are uvarints
+//
+// Certificates and hex values that cannot be rebuilt exactly from their binary
+// form are stored as-is, so decoding always reproduces the original strings.
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/pem"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/xx_network/primitives/region"
+)
+
+// CompactVersion is the version of the compact format produced by
+// MarshalCompact.
+const CompactVersion = 1
+
+// compactMagic is the prefix of all compact encoded NDFs. It begins with a
+// zero byte so that it can never be mistaken for JSON.
+const compactMagic = "\x00NDF"
+
+// Tags describing how a certificate is stored.
+const (
+	certRaw        = byte(0) // The string as-is
+	certDER        = byte(1) // DER bytes of a PEM block ending in a newline
+	certDERTrimmed = byte(2) // DER bytes of a PEM block without the newline
+)
+
+// Tags describing how a hex string is stored.
+const (
+	hexRaw   = byte(0) // The string as-is
+	hexUpper = byte(1) // Raw bytes of an upper-case hex string
+	hexLower = byte(2) // Raw bytes of a lower-case hex string
+)
+
+// pemCertificateType is the PEM block type of x509 certificates.
+const pemCertificateType = "CERTIFICATE"
+
+// Error messages.
+const (
+	compactMagicErr    = "data is not a compact encoded NDF"
+	compactVersionErr  = "unsupported compact NDF version %d; expected %d"
+	compactTrailingErr = "compact NDF data has %d unexpected trailing bytes"
+	compactTagErr      = "unknown encoding tag %d for %s in compact NDF data"
+	unknownFormatErr   = "unknown NDF format %s"
+)
+
+// Format is an encoding of the NetworkDefinition that can be served to
+// clients.
+type Format uint8
+
+const (
+	// JSON is the JSON encoding produced by NetworkDefinition.Marshal.
+	JSON Format = iota
+
+	// Compact is the binary encoding produced by
+	// NetworkDefinition.MarshalCompact.
+	Compact
+)
+
+// String returns the name of the Format. This function adheres to the
+// fmt.Stringer interface.
+func (f Format) String() string {
+	switch f {
+	case JSON:
+		return "JSON"
+	case Compact:
+		return "Compact"
+	default:
+		return "UNKNOWN FORMAT: " + strconv.Itoa(int(f))
+	}
+}
+
+// MarshalFormat encodes the NetworkDefinition in the given Format.
+func (ndf *NetworkDefinition) MarshalFormat(f Format) ([]byte, error) {
+	switch f {
+	case JSON:
+		return ndf.Marshal()
+	case Compact:
+		return ndf.MarshalCompact(), nil
+	default:
+		return nil, errors.Errorf(unknownFormatErr, f)
+	}
+}
+
+// UnmarshalFormat decodes an NDF in either the JSON or the compact format. The
+// format is detected from the data.
+func UnmarshalFormat(data []byte) (*NetworkDefinition, error) {
+	if IsCompact(data) {
+		return UnmarshalCompact(data)
+	}
+	return Unmarshal(data)
+}
+
+// IsCompact returns true if the data starts with the compact format prefix.
+func IsCompact(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(compactMagic))
+}
+
+// MarshalCompact returns the compact binary encoding of the NetworkDefinition.
+func (ndf *NetworkDefinition) MarshalCompact() []byte {
+	w := &canonicalWriter{buf: []byte(compactMagic)}
+	w.byte(CompactVersion)
+
+	w.uvarint(uint64(ndf.SchemaVersion))
+	w.time(ndf.Timestamp)
+
+	w.count(len(ndf.Gateways))
+	for _, gw := range ndf.Gateways {
+		w.bytes(gw.ID)
+		w.string(gw.Address)
+		writeCompactCert(w, gw.TlsCertificate)
+		w.byte(byte(gw.Bin))
+	}
+
+	w.count(len(ndf.Nodes))
+	for _, node := range ndf.Nodes {
+		w.bytes(node.ID)
+		w.string(node.Address)
+		writeCompactCert(w, node.TlsCertificate)
+		w.bytes(node.Ed25519)
+		w.byte(byte(node.Status))
+	}
+
+	w.string(ndf.Registration.Address)
+	w.string(ndf.Registration.ClientRegistrationAddress)
+	writeCompactCert(w, ndf.Registration.TlsCertificate)
+	w.string(ndf.Registration.EllipticPubKey)
+
+	w.string(ndf.Notification.Address)
+	writeCompactCert(w, ndf.Notification.TlsCertificate)
+
+	w.bytes(ndf.UDB.ID)
+	writeCompactCert(w, ndf.UDB.Cert)
+	w.string(ndf.UDB.Address)
+	w.bytes(ndf.UDB.DhPubKey)
+	w.bytes(ndf.UDB.ChannelSigningPubKeyEd25519)
+
+	for _, g := range []Group{ndf.E2E, ndf.CMIX} {
+		writeCompactHex(w, g.Prime)
+		writeCompactHex(w, g.SmallPrime)
+		writeCompactHex(w, g.Generator)
+	}
+
+	w.count(len(ndf.AddressSpace))
+	for _, as := range ndf.AddressSpace {
+		w.byte(as.Size)
+		w.time(as.Timestamp)
+	}
+
+	w.string(ndf.ClientVersion)
+
+	w.count(len(ndf.WhitelistedIds))
+	for _, s := range ndf.WhitelistedIds {
+		w.string(s)
+	}
+
+	w.count(len(ndf.WhitelistedIpAddresses))
+	for _, s := range ndf.WhitelistedIpAddresses {
+		w.string(s)
+	}
+
+	w.uvarint(uint64(ndf.RateLimits.Capacity))
+	w.uvarint(uint64(ndf.RateLimits.LeakedTokens))
+	w.uvarint(ndf.RateLimits.LeakDuration)
+
+	return w.buf
+}
+
+// UnmarshalCompact decodes data produced by MarshalCompact into a
+// NetworkDefinition.
+func UnmarshalCompact(data []byte) (*NetworkDefinition, error) {
+	if !IsCompact(data) {
+		return nil, errors.New(compactMagicErr)
+	}
+
+	r := &canonicalReader{buf: data[len(compactMagic):]}
+	if v := r.byte("version"); r.err == nil && v != CompactVersion {
+		return nil, errors.Errorf(compactVersionErr, v, CompactVersion)
+	}
+
+	ndf := &NetworkDefinition{}
+	ndf.SchemaVersion = uint32(r.uvarint("SchemaVersion"))
+	ndf.Timestamp = r.time("Timestamp")
+
+	if n := r.count("Gateways"); n > 0 {
+		ndf.Gateways = make([]Gateway, n)
+		for i := range ndf.Gateways {
+			ndf.Gateways[i] = Gateway{
+				ID:             r.bytes("Gateway.ID"),
+				Address:        r.string("Gateway.Address"),
+				TlsCertificate: readCompactCert(r, "Gateway.TlsCertificate"),
+				Bin:            region.GeoBin(r.byte("Gateway.Bin")),
+			}
+		}
+	}
+
+	if n := r.count("Nodes"); n > 0 {
+		ndf.Nodes = make([]Node, n)
+		for i := range ndf.Nodes {
+			ndf.Nodes[i] = Node{
+				ID:             r.bytes("Node.ID"),
+				Address:        r.string("Node.Address"),
+				TlsCertificate: readCompactCert(r, "Node.TlsCertificate"),
+				Ed25519:        r.bytes("Node.Ed25519"),
+				Status:         Status(r.byte("Node.Status")),
+			}
+		}
+	}
+
+	ndf.Registration = Registration{
+		Address:                   r.string("Registration.Address"),
+		ClientRegistrationAddress: r.string("Registration.ClientRegistrationAddress"),
+		TlsCertificate:            readCompactCert(r, "Registration.TlsCertificate"),
+		EllipticPubKey:            r.string("Registration.EllipticPubKey"),
+	}
+
+	ndf.Notification = Notification{
+		Address:        r.string("Notification.Address"),
+		TlsCertificate: readCompactCert(r, "Notification.TlsCertificate"),
+	}
+
+	ndf.UDB = UDB{
+		ID:                          r.bytes("UDB.ID"),
+		Cert:                        readCompactCert(r, "UDB.Cert"),
+		Address:                     r.string("UDB.Address"),
+		DhPubKey:                    r.bytes("UDB.DhPubKey"),
+		ChannelSigningPubKeyEd25519: r.bytes("UDB.ChannelSigningPubKeyEd25519"),
+	}
+
+	for _, g := range []*Group{&ndf.E2E, &ndf.CMIX} {
+		g.Prime = readCompactHex(r, "Group.Prime")
+		g.SmallPrime = readCompactHex(r, "Group.SmallPrime")
+		g.Generator = readCompactHex(r, "Group.Generator")
+	}
+
+	if n := r.count("AddressSpace"); n > 0 {
+		ndf.AddressSpace = make([]AddressSpace, n)
+		for i := range ndf.AddressSpace {
+			ndf.AddressSpace[i] = AddressSpace{
+				Size:      r.byte("AddressSpace.Size"),
+				Timestamp: r.time("AddressSpace.Timestamp"),
+			}
+		}
+	}
+
+	ndf.ClientVersion = r.string("ClientVersion")
+	ndf.WhitelistedIds = r.strings("WhitelistedIds")
+	ndf.WhitelistedIpAddresses = r.strings("WhitelistedIpAddresses")
+
+	ndf.RateLimits = RateLimiting{
+		Capacity:     uint(r.uvarint("RateLimits.Capacity")),
+		LeakedTokens: uint(r.uvarint("RateLimits.LeakedTokens")),
+		LeakDuration: r.uvarint("RateLimits.LeakDuration"),
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if len(r.buf) != 0 {
+		return nil, errors.Errorf(compactTrailingErr, len(r.buf))
+	}
+
+	return ndf, nil
+}
+
+// writeCompactCert writes the certificate as DER if the PEM string can be
+// rebuilt exactly from it. Otherwise, the string is written as-is.
+func writeCompactCert(w *canonicalWriter, certPEM string) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block != nil && block.Type == pemCertificateType {
+		encoded := string(pem.EncodeToMemory(
+			&pem.Block{Type: pemCertificateType, Bytes: block.Bytes}))
+		switch certPEM {
+		case encoded:
+			w.byte(certDER)
+			w.bytes(block.Bytes)
+			return
+		case strings.TrimSuffix(encoded, "\n"):
+			w.byte(certDERTrimmed)
+			w.bytes(block.Bytes)
+			return
+		}
+	}
+
+	w.byte(certRaw)
+	w.string(certPEM)
+}
+
+// readCompactCert reads a certificate written by writeCompactCert.
+func readCompactCert(r *canonicalReader, field string) string {
+	switch tag := r.byte(field); tag {
+	case certRaw:
+		return r.string(field)
+	case certDER, certDERTrimmed:
+		encoded := string(pem.EncodeToMemory(
+			&pem.Block{Type: pemCertificateType, Bytes: r.next(field)}))
+		if tag == certDERTrimmed {
+			encoded = strings.TrimSuffix(encoded, "\n")
+		}
+		return encoded
+	default:
+		if r.err == nil {
+			r.err = errors.Errorf(compactTagErr, tag, field)
+		}
+		return ""
+	}
+}
+
+// writeCompactHex writes the hex string as raw bytes if it can be rebuilt
+// exactly from them. Otherwise, the string is written as-is.
+func writeCompactHex(w *canonicalWriter, s string) {
+	if b, err := hex.DecodeString(s); err == nil && len(b) > 0 {
+		switch s {
+		case strings.ToUpper(hex.EncodeToString(b)):
+			w.byte(hexUpper)
+			w.bytes(b)
+			return
+		case hex.EncodeToString(b):
+			w.byte(hexLower)
+			w.bytes(b)
+			return
+		}
+	}
+
+	w.byte(hexRaw)
+	w.string(s)
+}
+
+// readCompactHex reads a hex string written by writeCompactHex.
+func readCompactHex(r *canonicalReader, field string) string {
+	switch tag := r.byte(field); tag {
+	case hexRaw:
+		return r.string(field)
+	case hexUpper:
+		return strings.ToUpper(hex.EncodeToString(r.next(field)))
+	case hexLower:
+		return hex.EncodeToString(r.next(field))
+	default:
+		if r.err == nil {
+			r.err = errors.Errorf(compactTagErr, tag, field)
+		}
+		return ""
+	}
+}
@@ -0,0 +1,157 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Tests that an NDF encoded with NetworkDefinition.MarshalCompact and decoded
+// with UnmarshalCompact matches the original and produces the same JSON.
+func TestNetworkDefinition_MarshalCompact_UnmarshalCompact(t *testing.T) {
+	for i, netDef := range []*NetworkDefinition{
+		exampleNdf(t), newFullTestNdf(t),
+		{},
+	} {
+		data := netDef.MarshalCompact()
+
+		decoded, err := UnmarshalCompact(data)
+		if err != nil {
+			t.Errorf("Failed to decode NDF #%d: %+v", i, err)
+			continue
+		}
+
+		if !netDef.Equal(decoded) {
+			t.Errorf("Decoded NDF #%d does not match original."+
+				"\nexpected: %+v\nreceived: %+v", i, netDef, decoded)
+		}
+	}
+}
+
+// Tests that the compact encoding of the example NDF is smaller than its JSON
+// encoding and that its certificates and group values are stored in binary.
+func TestNetworkDefinition_MarshalCompact_Size(t *testing.T) {
+	netDef := exampleNdf(t)
+	jsonData, err := netDef.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal NDF: %+v", err)
+	}
+
+	compact := netDef.MarshalCompact()
+	if len(compact)*10 > len(jsonData)*8 {
+		t.Errorf("Compact encoding is not at least 20%% smaller than JSON."+
+			"\ncompact: %d bytes\nJSON:    %d bytes", len(compact), len(jsonData))
+	}
+
+	if bytes.Contains(compact, []byte("BEGIN CERTIFICATE")) {
+		t.Errorf("Compact encoding contains PEM certificates.")
+	}
+	if bytes.Contains(compact, []byte(netDef.CMIX.Prime)) {
+		t.Errorf("Compact encoding contains hex group primes.")
+	}
+}
+
+// Tests that certificates and hex values that cannot be rebuilt exactly are
+// stored as-is.
+func TestNetworkDefinition_MarshalCompact_RawValues(t *testing.T) {
+	netDef := exampleNdf(t)
+	netDef.Gateways[0].TlsCertificate = "  " + netDef.Gateways[0].TlsCertificate
+	netDef.Gateways[1].TlsCertificate = netDef.Gateways[1].TlsCertificate + "\n"
+	netDef.Nodes[0].TlsCertificate = "not a certificate"
+	netDef.E2E.Prime = strings.ToLower(netDef.E2E.Prime)
+	netDef.E2E.SmallPrime = "aB"
+	netDef.E2E.Generator = "2"
+
+	decoded, err := UnmarshalCompact(netDef.MarshalCompact())
+	if err != nil {
+		t.Fatalf("UnmarshalCompact returned an error: %+v", err)
+	}
+
+	if !netDef.Equal(decoded) {
+		t.Errorf("Decoded NDF does not match original."+
+			"\nexpected: %+v\nreceived: %+v", netDef, decoded)
+	}
+}
+
+// Tests that UnmarshalFormat detects both formats and that MarshalFormat
+// produces them.
+func TestNetworkDefinition_MarshalFormat_UnmarshalFormat(t *testing.T) {
+	netDef := exampleNdf(t)
+	for _, f := range []Format{JSON, Compact} {
+		data, err := netDef.MarshalFormat(f)
+		if err != nil {
+			t.Fatalf("Failed to marshal %s: %+v", f, err)
+		}
+
+		if IsCompact(data) != (f == Compact) {
+			t.Errorf("IsCompact returned %t for %s.", IsCompact(data), f)
+		}
+
+		decoded, err := UnmarshalFormat(data)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal %s: %+v", f, err)
+		}
+		if !netDef.Equal(decoded) {
+			t.Errorf("Decoded %s NDF does not match original.", f)
+		}
+	}
+
+	if _, err := netDef.MarshalFormat(Format(99)); err == nil {
+		t.Errorf("MarshalFormat did not return an error for an unknown format.")
+	}
+}
+
+// Error path: Tests that UnmarshalCompact returns an error for every
+// truncation of valid data.
+func TestUnmarshalCompact_Truncated(t *testing.T) {
+	data := newFullTestNdf(t).MarshalCompact()
+	for i := 0; i < len(data); i++ {
+		if _, err := UnmarshalCompact(data[:i]); err == nil {
+			t.Errorf("No error for data truncated to %d of %d bytes.",
+				i, len(data))
+		}
+	}
+}
+
+// Error path: Tests that UnmarshalCompact rejects an unknown version and data
+// without the compact prefix.
+func TestUnmarshalCompact_HeaderErrors(t *testing.T) {
+	data := newFullTestNdf(t).MarshalCompact()
+	data[len(compactMagic)] = CompactVersion + 1
+	_, err := UnmarshalCompact(data)
+	if err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Unexpected error for invalid version: %+v", err)
+	}
+
+	_, err = UnmarshalCompact([]byte(ExampleNDF))
+	if err == nil || err.Error() != compactMagicErr {
+		t.Errorf("Unexpected error for JSON data.\nexpected: %s\nreceived: %+v",
+			compactMagicErr, err)
+	}
+}
+
+// Error path: Tests that UnmarshalCompact rejects a uvarint that is not
+// minimally encoded.
+func TestUnmarshalCompact_NonMinimalVarint(t *testing.T) {
+	data := (&NetworkDefinition{SchemaVersion: 1}).MarshalCompact()
+
+	// The schema version follows the prefix and format version
+	i := len(compactMagic) + 1
+	if data[i] != 1 {
+		t.Fatalf("Unexpected schema version %d.", data[i])
+	}
+	padded := append(append(append([]byte{}, data[:i]...), 0x81, 0x00),
+		data[i+1:]...)
+
+	_, err := UnmarshalCompact(padded)
+	if err == nil || !strings.Contains(err.Error(), "invalid varint") {
+		t.Errorf("Unexpected error for a non-minimal varint: %+v", err)
+	}
+}
@@ -0,0 +1,472 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// diff.go contains Diff and Apply, which produce and consume a Delta between
+// two NDFs so that small changes can be shipped without the entire NDF.
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Error messages.
+const (
+	baseHashMismatchErr   = "base NDF hash %x does not match delta base hash %x"
+	resultHashMismatchErr = "NDF produced by applying the delta has hash %x; expected %x"
+	unknownGatewayErr     = "delta references unknown gateway %x"
+	unknownNodeErr        = "delta references unknown node %x"
+	deltaIndexErr         = "delta inserts %s at index %d; only %d entries exist"
+	orderLengthErr        = "delta %s order has %d IDs; expected %d"
+	unmarshalDeltaErr     = "failed to unmarshal NDF delta"
+)
+
+// Delta describes the changes needed to turn one NetworkDefinition into
+// another. Fields that did not change are left nil.
+type Delta struct {
+	// BaseHash is the Digest of the NDF the delta applies to and ResultHash is
+	// the Digest of the NDF it produces.
+	BaseHash   []byte
+	ResultHash []byte
+
+	// Timestamp is the timestamp of the new NDF.
+	Timestamp time.Time
+
+	Gateways GatewayDelta
+	Nodes    NodeDelta
+
+	Registration           *Registration   `json:",omitempty"`
+	Notification           *Notification   `json:",omitempty"`
+	UDB                    *UDB            `json:",omitempty"`
+	E2E                    *Group          `json:",omitempty"`
+	CMIX                   *Group          `json:",omitempty"`
+	AddressSpace           *[]AddressSpace `json:",omitempty"`
+	ClientVersion          *string         `json:",omitempty"`
+	WhitelistedIds         *[]string       `json:",omitempty"`
+	WhitelistedIpAddresses *[]string       `json:",omitempty"`
+	RateLimits             *RateLimiting   `json:",omitempty"`
+}
+
+// GatewayDelta lists the gateways added, removed, and changed between two NDFs.
+// Gateways are matched by ID.
+type GatewayDelta struct {
+	// Added gateways with the index they occupy in the new NDF.
+	Added []IndexedGateway `json:",omitempty"`
+
+	// IDs of the gateways removed from the old NDF.
+	Removed [][]byte `json:",omitempty"`
+
+	// New values of gateways whose contents changed.
+	Changed []Gateway `json:",omitempty"`
+
+	// Order is the ID of every gateway in the new NDF. It is only set when the
+	// remaining gateways were reordered.
+	Order [][]byte `json:",omitempty"`
+
+	// Replace is the full list of gateways in the new NDF. It is only set when
+	// gateways cannot be matched by ID because IDs are missing or duplicated.
+	Replace *[]Gateway `json:",omitempty"`
+}
+
+// IndexedGateway is a Gateway and its index in the Gateways list.
+type IndexedGateway struct {
+	Index int
+	Gateway
+}
+
+// NodeDelta lists the nodes added, removed, and changed between two NDFs.
+// Nodes are matched by ID. The fields have the same meaning as in
+// GatewayDelta.
+type NodeDelta struct {
+	Added   []IndexedNode `json:",omitempty"`
+	Removed [][]byte      `json:",omitempty"`
+	Changed []Node        `json:",omitempty"`
+	Order   [][]byte      `json:",omitempty"`
+	Replace *[]Node       `json:",omitempty"`
+}
+
+// IndexedNode is a Node and its index in the Nodes list.
+type IndexedNode struct {
+	Index int
+	Node
+}
+
+// Diff returns the Delta that transforms oldNdf into newNdf. Replaced lists in
+// the Delta are never nil so that they survive a JSON round trip.
+func Diff(oldNdf, newNdf *NetworkDefinition) *Delta {
+	d := &Delta{
+		BaseHash:   oldNdf.Digest(),
+		ResultHash: newNdf.Digest(),
+		Timestamp:  newNdf.Timestamp,
+		Gateways:   diffGateways(oldNdf.Gateways, newNdf.Gateways),
+		Nodes:      diffNodes(oldNdf.Nodes, newNdf.Nodes),
+	}
+
+	if oldNdf.Registration != newNdf.Registration {
+		reg := newNdf.Registration
+		d.Registration = &reg
+	}
+	if oldNdf.Notification != newNdf.Notification {
+		notification := newNdf.Notification
+		d.Notification = &notification
+	}
+	if !fieldsEqual(oldNdf.UDB, newNdf.UDB) {
+		udb := newNdf.UDB
+		d.UDB = &udb
+	}
+	if oldNdf.E2E != newNdf.E2E {
+		e2e := newNdf.E2E
+		d.E2E = &e2e
+	}
+	if oldNdf.CMIX != newNdf.CMIX {
+		cmix := newNdf.CMIX
+		d.CMIX = &cmix
+	}
+	if !fieldsEqual(oldNdf.AddressSpace, newNdf.AddressSpace) {
+		as := append([]AddressSpace{}, newNdf.AddressSpace...)
+		d.AddressSpace = &as
+	}
+	if oldNdf.ClientVersion != newNdf.ClientVersion {
+		cv := newNdf.ClientVersion
+		d.ClientVersion = &cv
+	}
+	if !fieldsEqual(oldNdf.WhitelistedIds, newNdf.WhitelistedIds) {
+		ids := append([]string{}, newNdf.WhitelistedIds...)
+		d.WhitelistedIds = &ids
+	}
+	if !fieldsEqual(oldNdf.WhitelistedIpAddresses, newNdf.WhitelistedIpAddresses) {
+		ips := append([]string{}, newNdf.WhitelistedIpAddresses...)
+		d.WhitelistedIpAddresses = &ips
+	}
+	if oldNdf.RateLimits != newNdf.RateLimits {
+		rl := newNdf.RateLimits
+		d.RateLimits = &rl
+	}
+
+	return d
+}
+
+// Apply applies the Delta to the base NDF and returns the resulting NDF. The
+// base NDF is not modified. An error is returned if the base NDF is not the
+// one the delta was made from or if the result does not match the delta's
+// ResultHash.
+func Apply(base *NetworkDefinition, d *Delta) (*NetworkDefinition, error) {
+	if baseHash := base.Digest(); !bytes.Equal(baseHash, d.BaseHash) {
+		return nil, errors.Errorf(baseHashMismatchErr, baseHash, d.BaseHash)
+	}
+
+	ndf := base.DeepCopy()
+	ndf.Timestamp = d.Timestamp
+
+	var err error
+	ndf.Gateways, err = applyGateways(ndf.Gateways, d.Gateways)
+	if err != nil {
+		return nil, err
+	}
+	ndf.Nodes, err = applyNodes(ndf.Nodes, d.Nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Values from the delta that hold slices are copied so that the result
+	// does not share memory with it
+	if d.Registration != nil {
+		ndf.Registration = *d.Registration
+	}
+	if d.Notification != nil {
+		ndf.Notification = *d.Notification
+	}
+	if d.UDB != nil {
+		ndf.UDB = deepCopy(*d.UDB)
+	}
+	if d.E2E != nil {
+		ndf.E2E = *d.E2E
+	}
+	if d.CMIX != nil {
+		ndf.CMIX = *d.CMIX
+	}
+	if d.AddressSpace != nil {
+		ndf.AddressSpace = deepCopy(*d.AddressSpace)
+	}
+	if d.ClientVersion != nil {
+		ndf.ClientVersion = *d.ClientVersion
+	}
+	if d.WhitelistedIds != nil {
+		ndf.WhitelistedIds = deepCopy(*d.WhitelistedIds)
+	}
+	if d.WhitelistedIpAddresses != nil {
+		ndf.WhitelistedIpAddresses = deepCopy(*d.WhitelistedIpAddresses)
+	}
+	if d.RateLimits != nil {
+		ndf.RateLimits = *d.RateLimits
+	}
+
+	if resultHash := ndf.Digest(); !bytes.Equal(resultHash, d.ResultHash) {
+		return nil, errors.Errorf(resultHashMismatchErr, resultHash, d.ResultHash)
+	}
+
+	return ndf, nil
+}
+
+// IsEmpty returns true if the Delta contains no changes.
+func (d *Delta) IsEmpty() bool {
+	return bytes.Equal(d.BaseHash, d.ResultHash)
+}
+
+// Marshal returns the JSON encoding of the Delta.
+func (d *Delta) Marshal() ([]byte, error) {
+	return json.Marshal(d)
+}
+
+// UnmarshalDelta parses the JSON encoded data and returns the resulting Delta.
+func UnmarshalDelta(data []byte) (*Delta, error) {
+	d := &Delta{}
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, errors.Wrap(err, unmarshalDeltaErr)
+	}
+	return d, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Gateways and Nodes                                                         //
+////////////////////////////////////////////////////////////////////////////////
+
+// diffGateways returns the GatewayDelta that transforms oldList into newList.
+func diffGateways(oldList, newList []Gateway) GatewayDelta {
+	kd := diffKeyed(oldList, newList, gatewayID)
+	gd := GatewayDelta{Removed: kd.removed, Changed: kd.changed,
+		Order: kd.order, Replace: kd.replace}
+	for _, a := range kd.added {
+		gd.Added = append(gd.Added, IndexedGateway{a.index, a.entry})
+	}
+	return gd
+}
+
+// applyGateways applies the GatewayDelta to the list of gateways.
+func applyGateways(list []Gateway, gd GatewayDelta) ([]Gateway, error) {
+	kd := keyedDelta[Gateway]{removed: gd.Removed, changed: gd.Changed,
+		order: gd.Order, replace: gd.Replace}
+	for _, ig := range gd.Added {
+		kd.added = append(kd.added, indexedEntry[Gateway]{ig.Index, ig.Gateway})
+	}
+	return applyKeyed(list, kd, gatewayID, "gateway", unknownGatewayErr)
+}
+
+// diffNodes returns the NodeDelta that transforms oldList into newList.
+func diffNodes(oldList, newList []Node) NodeDelta {
+	kd := diffKeyed(oldList, newList, nodeID)
+	nd := NodeDelta{Removed: kd.removed, Changed: kd.changed,
+		Order: kd.order, Replace: kd.replace}
+	for _, a := range kd.added {
+		nd.Added = append(nd.Added, IndexedNode{a.index, a.entry})
+	}
+	return nd
+}
+
+// applyNodes applies the NodeDelta to the list of nodes.
+func applyNodes(list []Node, nd NodeDelta) ([]Node, error) {
+	kd := keyedDelta[Node]{removed: nd.Removed, changed: nd.Changed,
+		order: nd.Order, replace: nd.Replace}
+	for _, in := range nd.Added {
+		kd.added = append(kd.added, indexedEntry[Node]{in.Index, in.Node})
+	}
+	return applyKeyed(list, kd, nodeID, "node", unknownNodeErr)
+}
+
+func gatewayID(gw Gateway) []byte { return gw.ID }
+func nodeID(node Node) []byte     { return node.ID }
+
+////////////////////////////////////////////////////////////////////////////////
+// Keyed Lists                                                                //
+////////////////////////////////////////////////////////////////////////////////
+
+// keyedDelta is the generic form of GatewayDelta and NodeDelta for a list of
+// entries matched by ID. The fields have the same meaning as in GatewayDelta.
+type keyedDelta[T any] struct {
+	added   []indexedEntry[T]
+	removed [][]byte
+	changed []T
+	order   [][]byte
+	replace *[]T
+}
+
+// indexedEntry is an entry and its index in the list.
+type indexedEntry[T any] struct {
+	index int
+	entry T
+}
+
+// diffKeyed returns the keyedDelta that transforms oldList into newList, using
+// getID to match entries.
+func diffKeyed[T any](
+	oldList, newList []T, getID func(T) []byte) keyedDelta[T] {
+	var kd keyedDelta[T]
+
+	oldIDs, newIDs := make([][]byte, len(oldList)), make([][]byte, len(newList))
+	for i := range oldList {
+		oldIDs[i] = getID(oldList[i])
+	}
+	for i := range newList {
+		newIDs[i] = getID(newList[i])
+	}
+
+	oldIndex, oldOk := indexIDs(oldIDs)
+	newIndex, newOk := indexIDs(newIDs)
+	if !oldOk || !newOk {
+		if !fieldsEqual(oldList, newList) {
+			replace := append([]T{}, newList...)
+			kd.replace = &replace
+		}
+		return kd
+	}
+
+	for _, entryID := range oldIDs {
+		if _, exists := newIndex[string(entryID)]; !exists {
+			kd.removed = append(kd.removed, entryID)
+		}
+	}
+
+	var retained [][]byte
+	for i, entry := range newList {
+		j, exists := oldIndex[string(newIDs[i])]
+		if !exists {
+			kd.added = append(kd.added, indexedEntry[T]{i, entry})
+			continue
+		}
+		retained = append(retained, newIDs[i])
+		if !fieldsEqual(oldList[j], entry) {
+			kd.changed = append(kd.changed, entry)
+		}
+	}
+
+	if !retainedInOrder(oldIDs, newIndex, retained) {
+		kd.order = newIDs
+	}
+
+	return kd
+}
+
+// applyKeyed applies the keyedDelta to the list, using getID to match entries.
+// The kind and unknownErr describe the entries in errors.
+func applyKeyed[T any](list []T, kd keyedDelta[T], getID func(T) []byte,
+	kind, unknownErr string) ([]T, error) {
+	// Entries from the delta are copied so that the result does not share
+	// memory with it
+	if kd.replace != nil {
+		return deepCopy(*kd.replace), nil
+	}
+
+	removed := make(map[string]bool, len(kd.removed))
+	for _, entryID := range kd.removed {
+		removed[string(entryID)] = true
+	}
+	changed := make(map[string]T, len(kd.changed))
+	for _, entry := range kd.changed {
+		changed[string(getID(entry))] = deepCopy(entry)
+	}
+
+	result := make([]T, 0, len(list)+len(kd.added))
+	for _, entry := range list {
+		entryID := string(getID(entry))
+		if removed[entryID] {
+			delete(removed, entryID)
+			continue
+		}
+		if newEntry, exists := changed[entryID]; exists {
+			entry = newEntry
+			delete(changed, entryID)
+		}
+		result = append(result, entry)
+	}
+	for entryID := range removed {
+		return nil, errors.Errorf(unknownErr, entryID)
+	}
+	for entryID := range changed {
+		return nil, errors.Errorf(unknownErr, entryID)
+	}
+
+	if kd.order != nil {
+		if len(kd.order) != len(result)+len(kd.added) {
+			return nil, errors.Errorf(orderLengthErr,
+				kind, len(kd.order), len(result)+len(kd.added))
+		}
+		byID := make(map[string]T, len(result)+len(kd.added))
+		for _, entry := range result {
+			byID[string(getID(entry))] = entry
+		}
+		for _, a := range kd.added {
+			byID[string(getID(a.entry))] = deepCopy(a.entry)
+		}
+		result = result[:0]
+		for _, entryID := range kd.order {
+			entry, exists := byID[string(entryID)]
+			if !exists {
+				return nil, errors.Errorf(unknownErr, entryID)
+			}
+			result = append(result, entry)
+		}
+		return result, nil
+	}
+
+	added := append([]indexedEntry[T]{}, kd.added...)
+	sort.SliceStable(added, func(i, j int) bool {
+		return added[i].index < added[j].index
+	})
+	for _, a := range added {
+		if a.index < 0 || a.index > len(result) {
+			return nil, errors.Errorf(deltaIndexErr, kind, a.index, len(result))
+		}
+		var zero T
+		result = append(result, zero)
+		copy(result[a.index+1:], result[a.index:])
+		result[a.index] = deepCopy(a.entry)
+	}
+
+	return result, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Helpers                                                                    //
+////////////////////////////////////////////////////////////////////////////////
+
+// indexIDs returns a map of each ID to its index in the list. Returns false if
+// any ID is empty or appears more than once.
+func indexIDs(ids [][]byte) (map[string]int, bool) {
+	index := make(map[string]int, len(ids))
+	for i, b := range ids {
+		if len(b) == 0 {
+			return nil, false
+		}
+		if _, exists := index[string(b)]; exists {
+			return nil, false
+		}
+		index[string(b)] = i
+	}
+	return index, true
+}
+
+// retainedInOrder returns true if the retained IDs, in their new order, appear
+// in the same order as they did in the old list.
+func retainedInOrder(
+	oldIDs [][]byte, newIndex map[string]int, retained [][]byte) bool {
+	i := 0
+	for _, b := range oldIDs {
+		if _, exists := newIndex[string(b)]; !exists {
+			continue
+		}
+		if !bytes.Equal(b, retained[i]) {
+			return false
+		}
+		i++
+	}
+	return true
+}
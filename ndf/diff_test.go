@@ -0,0 +1,266 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that Apply reproduces the new NDF from a Diff for a variety of
+// changes, including after a JSON round trip of the Delta.
+func TestDiff_Apply(t *testing.T) {
+	tests := map[string]func(n *NetworkDefinition){
+		"NoChange": func(n *NetworkDefinition) {},
+		"GatewayAddress": func(n *NetworkDefinition) {
+			n.Gateways[1].Address = "new.address:22840"
+		},
+		"NodeStatus": func(n *NetworkDefinition) { n.Nodes[2].Status = Stale },
+		"RemoveFirstPair": func(n *NetworkDefinition) {
+			n.Gateways, n.Nodes = n.Gateways[1:], n.Nodes[1:]
+		},
+		"AddPairInMiddle": func(n *NetworkDefinition) {
+			gw := Gateway{ID: bytes.Repeat([]byte{9}, 33), Address: "gw9"}
+			node := Node{ID: bytes.Repeat([]byte{8}, 33), Address: "node8"}
+			n.Gateways = append(n.Gateways[:1],
+				append([]Gateway{gw}, n.Gateways[1:]...)...)
+			n.Nodes = append(n.Nodes[:1], append([]Node{node}, n.Nodes[1:]...)...)
+		},
+		"Reorder": func(n *NetworkDefinition) {
+			n.Gateways[0], n.Gateways[2] = n.Gateways[2], n.Gateways[0]
+			n.Nodes[0], n.Nodes[1] = n.Nodes[1], n.Nodes[0]
+		},
+		"Everything": func(n *NetworkDefinition) {
+			n.Timestamp = n.Timestamp.Add(time.Minute)
+			n.Registration.Address = "new-registration"
+			n.Notification.Address = "new-notification"
+			n.UDB.Address = "new-udb"
+			n.E2E.Generator = "05"
+			n.CMIX.Generator = "07"
+			n.AddressSpace = append(n.AddressSpace,
+				AddressSpace{Size: 18, Timestamp: n.Timestamp})
+			n.ClientVersion = "5.0.0"
+			n.WhitelistedIds = nil
+			n.WhitelistedIpAddresses = append(n.WhitelistedIpAddresses, "10.0.0.2")
+			n.RateLimits.Capacity = 99
+		},
+	}
+
+	for name, modify := range tests {
+		oldNdf := newFullTestNdf(t)
+		newNdf := newFullTestNdf(t)
+		modify(newNdf)
+
+		delta := Diff(oldNdf, newNdf)
+
+		data, err := delta.Marshal()
+		if err != nil {
+			t.Fatalf("%s: failed to marshal delta: %+v", name, err)
+		}
+		delta, err = UnmarshalDelta(data)
+		if err != nil {
+			t.Fatalf("%s: failed to unmarshal delta: %+v", name, err)
+		}
+
+		result, err := Apply(oldNdf, delta)
+		if err != nil {
+			t.Errorf("%s: Apply returned an error: %+v", name, err)
+			continue
+		}
+
+		if !bytes.Equal(result.SerializeCanonical(), newNdf.SerializeCanonical()) {
+			t.Errorf("%s: Apply did not produce the new NDF."+
+				"\nexpected: %+v\nreceived: %+v", name, newNdf, result)
+		}
+
+		if !bytes.Equal(oldNdf.Digest(), newFullTestNdf(t).Digest()) {
+			t.Errorf("%s: Apply modified the base NDF.", name)
+		}
+	}
+}
+
+// Tests that a client can apply a delta to a base NDF that it received as
+// JSON, when the server made the delta from an NDF with a local timestamp.
+func TestApply_JSONBase(t *testing.T) {
+	oldNdf := newFullTestNdf(t)
+	oldNdf.Timestamp = time.Now()
+	newNdf := oldNdf.DeepCopy()
+	newNdf.Timestamp = oldNdf.Timestamp.Add(time.Minute)
+	newNdf.Gateways[0].Address = "new.address:22840"
+
+	delta := Diff(oldNdf, newNdf)
+
+	data, err := oldNdf.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal base NDF: %+v", err)
+	}
+	clientBase, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal base NDF: %+v", err)
+	}
+
+	result, err := Apply(clientBase, delta)
+	if err != nil {
+		t.Fatalf("Apply returned an error: %+v", err)
+	}
+	if !result.Equal(newNdf) {
+		t.Errorf("Apply did not produce the new NDF."+
+			"\nexpected: %+v\nreceived: %+v", newNdf, result)
+	}
+}
+
+// Tests that Diff only reports the changed gateway and node.
+func TestDiff_Contents(t *testing.T) {
+	oldNdf := newFullTestNdf(t)
+	newNdf := newFullTestNdf(t)
+	newNdf.Gateways[1].Address = "new.address:22840"
+	newNdf.Nodes[0].Status = Stale
+	newNdf.Nodes = newNdf.Nodes[:2]
+
+	delta := Diff(oldNdf, newNdf)
+
+	if len(delta.Gateways.Changed) != 1 ||
+		!bytes.Equal(delta.Gateways.Changed[0].ID, newNdf.Gateways[1].ID) {
+		t.Errorf("Unexpected changed gateways: %+v", delta.Gateways.Changed)
+	}
+	if len(delta.Gateways.Added) != 0 || len(delta.Gateways.Removed) != 0 ||
+		delta.Gateways.Order != nil || delta.Gateways.Replace != nil {
+		t.Errorf("Unexpected gateway delta: %+v", delta.Gateways)
+	}
+
+	if len(delta.Nodes.Changed) != 1 || delta.Nodes.Changed[0].Status != Stale {
+		t.Errorf("Unexpected changed nodes: %+v", delta.Nodes.Changed)
+	}
+	if len(delta.Nodes.Removed) != 1 ||
+		!bytes.Equal(delta.Nodes.Removed[0], oldNdf.Nodes[2].ID) {
+		t.Errorf("Unexpected removed nodes: %+v", delta.Nodes.Removed)
+	}
+
+	if delta.Registration != nil || delta.UDB != nil || delta.E2E != nil ||
+		delta.AddressSpace != nil || delta.RateLimits != nil {
+		t.Errorf("Diff reported unchanged fields: %+v", delta)
+	}
+
+	if delta.IsEmpty() {
+		t.Errorf("Delta with changes reported as empty.")
+	}
+	if !Diff(oldNdf, oldNdf).IsEmpty() {
+		t.Errorf("Delta between identical NDFs is not empty.")
+	}
+}
+
+// Tests that Diff falls back to replacing the gateway list when gateways have
+// no IDs, as in the example NDF.
+func TestDiff_Apply_MissingIDs(t *testing.T) {
+	oldNdf := exampleNdf(t)
+	newNdf := exampleNdf(t)
+	newNdf.Gateways[0].Address = "1.1.1.1"
+
+	delta := Diff(oldNdf, newNdf)
+	if delta.Gateways.Replace == nil {
+		t.Fatalf("Diff did not replace gateways with missing IDs.")
+	}
+
+	result, err := Apply(oldNdf, delta)
+	if err != nil {
+		t.Fatalf("Apply returned an error: %+v", err)
+	}
+	if result.Gateways[0].Address != "1.1.1.1" {
+		t.Errorf("Apply did not update the gateway address.")
+	}
+}
+
+// Tests that modifying a Delta after it has been applied does not change the
+// resulting NDF.
+func TestApply_DeltaNotShared(t *testing.T) {
+	oldNdf := newFullTestNdf(t)
+	newNdf := newFullTestNdf(t)
+	newNdf.Gateways = append(newNdf.Gateways,
+		Gateway{ID: bytes.Repeat([]byte{9}, 33), Address: "gw9"})
+	newNdf.Nodes[0].Ed25519 = []byte{1, 2, 3}
+	newNdf.UDB.DhPubKey = []byte{7, 7, 7}
+	newNdf.AddressSpace = append(newNdf.AddressSpace,
+		AddressSpace{Size: 18, Timestamp: newNdf.Timestamp})
+	newNdf.WhitelistedIds = []string{"id3"}
+
+	delta := Diff(oldNdf, newNdf)
+	result, err := Apply(oldNdf, delta)
+	if err != nil {
+		t.Fatalf("Apply returned an error: %+v", err)
+	}
+	expected := result.DeepCopy()
+
+	delta.Gateways.Added[0].ID[0] = 0xFF
+	delta.Nodes.Changed[0].Ed25519[0] = 0xFF
+	delta.UDB.DhPubKey[0] = 0xFF
+	(*delta.AddressSpace)[0].Size = 0xFF
+	(*delta.WhitelistedIds)[0] = "modified"
+
+	if !result.Equal(expected) {
+		t.Errorf("Modifying the delta changed the result."+
+			"\nexpected: %+v\nreceived: %+v", expected, result)
+	}
+
+	// Replaced lists are also copied
+	oldNdf, newNdf = exampleNdf(t), exampleNdf(t)
+	newNdf.Gateways[0].Address = "1.1.1.1"
+	delta = Diff(oldNdf, newNdf)
+	result, err = Apply(oldNdf, delta)
+	if err != nil {
+		t.Fatalf("Apply returned an error: %+v", err)
+	}
+
+	(*delta.Gateways.Replace)[0].Address = "modified"
+	if result.Gateways[0].Address != "1.1.1.1" {
+		t.Errorf("Modifying the replaced gateways changed the result.")
+	}
+}
+
+// Error path: Tests that Apply rejects a delta made from a different base.
+func TestApply_BaseHashMismatch(t *testing.T) {
+	oldNdf := newFullTestNdf(t)
+	newNdf := newFullTestNdf(t)
+	newNdf.ClientVersion = "9.9.9"
+	delta := Diff(oldNdf, newNdf)
+
+	otherBase := newFullTestNdf(t)
+	otherBase.Registration.Address = "other"
+	_, err := Apply(otherBase, delta)
+	if err == nil || !strings.Contains(err.Error(), "does not match delta base") {
+		t.Errorf("Unexpected error for mismatched base: %+v", err)
+	}
+}
+
+// Error path: Tests that Apply rejects a delta whose contents were altered.
+func TestApply_ResultHashMismatch(t *testing.T) {
+	oldNdf := newFullTestNdf(t)
+	newNdf := newFullTestNdf(t)
+	newNdf.ClientVersion = "9.9.9"
+	delta := Diff(oldNdf, newNdf)
+
+	cv := "6.6.6"
+	delta.ClientVersion = &cv
+	_, err := Apply(oldNdf, delta)
+	if err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("Unexpected error for altered delta: %+v", err)
+	}
+}
+
+// Error path: Tests that Apply rejects a delta referencing an unknown node.
+func TestApply_UnknownNode(t *testing.T) {
+	oldNdf := newFullTestNdf(t)
+	delta := Diff(oldNdf, oldNdf)
+	delta.Nodes.Removed = [][]byte{{1, 2, 3}}
+
+	_, err := Apply(oldNdf, delta)
+	if err == nil || !strings.Contains(err.Error(), "unknown node") {
+		t.Errorf("Unexpected error for unknown node: %+v", err)
+	}
+}
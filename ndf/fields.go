@@ -0,0 +1,207 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// fields.go contains reflection-based deep copy, equality, and field walking
+// for the NDF types so that they do not need to be updated by hand when a
+// field is added.
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// timeType is the type of time.Time, which is treated as a single value.
+var timeType = reflect.TypeOf(time.Time{})
+
+// WalkFunc is called by NetworkDefinition.Walk for every field. The path is the
+// Go expression of the field relative to the NDF, such as "Gateways[2].ID".
+// The value is addressable and can be modified. Returning an error stops the
+// walk.
+type WalkFunc func(path string, value reflect.Value) error
+
+// Walk calls fn for every leaf field of the NDF in declaration order. Leaves
+// are the fields that are not structs or slices, byte slices, and times.
+// Slice elements are walked by index, so empty slices produce no calls.
+func (ndf *NetworkDefinition) Walk(fn WalkFunc) error {
+	return walkValue("", reflect.ValueOf(ndf).Elem(), fn)
+}
+
+// Equal returns true if both NDFs have the same contents. Nil and empty
+// slices are equal and times are equal if they are the same instant, even if
+// they are in different zones.
+func (ndf *NetworkDefinition) Equal(other *NetworkDefinition) bool {
+	if ndf == nil || other == nil {
+		return ndf == other
+	}
+	return fieldsEqual(*ndf, *other)
+}
+
+// deepCopy returns a copy of the value that does not share memory with the
+// original. Nil slices, maps, and pointers remain nil and empty slices and maps
+// remain empty, so the copy encodes to the same JSON as the original.
+func deepCopy[T any](v T) T {
+	var out T
+	copyValue(reflect.ValueOf(&out).Elem(), reflect.ValueOf(v))
+	return out
+}
+
+// fieldsEqual returns true if the values are equal using the same rules as
+// NetworkDefinition.Equal.
+func fieldsEqual[T any](a, b T) bool {
+	return valuesEqual(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+// copyValue deep copies src into dst, which must be settable and hold the
+// zero value.
+func copyValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		// Copies unexported fields, such as those of time.Time, which are
+		// treated as immutable
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				dst.Field(i).Set(reflect.Zero(src.Field(i).Type()))
+				copyValue(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			copyValue(dst.Index(i), src.Index(i))
+		}
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			copyValue(dst.Index(i), src.Index(i))
+		}
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		copyValue(dst.Elem(), src.Elem())
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			v := reflect.New(src.Type().Elem()).Elem()
+			copyValue(v, iter.Value())
+			dst.SetMapIndex(iter.Key(), v)
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// valuesEqual compares two values of the same type. Unexported struct fields
+// are ignored.
+func valuesEqual(a, b reflect.Value) bool {
+	if a.Type() == timeType {
+		return a.Interface().(time.Time).Equal(b.Interface().(time.Time))
+	}
+
+	switch a.Kind() {
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if a.Type().Field(i).IsExported() &&
+				!valuesEqual(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.Type().Elem().Kind() == reflect.Uint8 {
+			return bytes.Equal(a.Bytes(), b.Bytes())
+		}
+		fallthrough
+	case reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !valuesEqual(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return valuesEqual(a.Elem(), b.Elem())
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			v := b.MapIndex(iter.Key())
+			if !v.IsValid() || !valuesEqual(iter.Value(), v) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.Interface() == b.Interface()
+	}
+}
+
+// walkValue calls fn for every leaf of the value.
+func walkValue(path string, v reflect.Value, fn WalkFunc) error {
+	if v.Type() == timeType ||
+		(v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8) {
+		return fn(path, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			err := walkValue(joinPath(path, field.Name), v.Field(i), fn)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			elemPath := path + "[" + strconv.Itoa(i) + "]"
+			if err := walkValue(elemPath, v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return walkValue(path, v.Elem(), fn)
+	default:
+		return fn(path, v)
+	}
+}
+
+// joinPath appends the field name to the path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
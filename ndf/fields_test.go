@@ -0,0 +1,223 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mutateField changes the value of a leaf reached by NetworkDefinition.Walk.
+// Byte slices are modified in place so that shared memory is detected.
+func mutateField(t *testing.T, path string, v reflect.Value) {
+	switch {
+	case v.Type() == timeType:
+		v.Set(reflect.ValueOf(v.Interface().(time.Time).Add(time.Second)))
+	case v.Kind() == reflect.Slice:
+		if v.Len() == 0 {
+			t.Fatalf("Field %s is empty.", path)
+		}
+		v.Index(0).SetUint(v.Index(0).Uint() + 1)
+	case v.Kind() == reflect.String:
+		v.SetString(v.String() + "x")
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64:
+		v.SetUint(v.Uint() + 1)
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		v.SetInt(v.Int() + 1)
+	case v.Kind() == reflect.Bool:
+		v.SetBool(!v.Bool())
+	default:
+		t.Fatalf("Cannot mutate field %s of kind %s.", path, v.Kind())
+	}
+}
+
+// Tests that every field of the test NDF is reached by
+// NetworkDefinition.Walk and is set, so that the other tests in this file
+// cover every field. This fails when a field is added to the NDF without being
+// added to newFullTestNdf.
+func TestNetworkDefinition_Walk(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	paths := make(map[string]bool)
+	err := netDef.Walk(func(path string, v reflect.Value) error {
+		paths[path] = true
+		if v.IsZero() && v.Kind() != reflect.Uint8 {
+			t.Errorf("Field %s is not set in the test NDF.", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk returned an error: %+v", err)
+	}
+
+	for _, path := range []string{"SchemaVersion", "Timestamp",
+		"Gateways[2].Bin", "Nodes[0].Ed25519", "Registration.EllipticPubKey",
+		"UDB.ChannelSigningPubKeyEd25519", "CMIX.Generator",
+		"AddressSpace[1].Timestamp", "WhitelistedIpAddresses[0]",
+		"RateLimits.LeakDuration"} {
+		if !paths[path] {
+			t.Errorf("Walk did not visit %s.", path)
+		}
+	}
+}
+
+// Tests that NetworkDefinition.Walk stops and returns the error returned by
+// the WalkFunc.
+func TestNetworkDefinition_Walk_Error(t *testing.T) {
+	expectedErr := errors.New("stop")
+	var calls int
+	err := newFullTestNdf(t).Walk(func(string, reflect.Value) error {
+		calls++
+		return expectedErr
+	})
+	if err != expectedErr || calls != 1 {
+		t.Errorf("Unexpected result.\nerror: %+v\ncalls: %d", err, calls)
+	}
+}
+
+// Tests that changing every field of a copy made by
+// NetworkDefinition.DeepCopy does not change the original, meaning no field
+// is aliased.
+func TestNetworkDefinition_DeepCopy_NoAliasing(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	newNDF := netDef.DeepCopy()
+
+	if !reflect.DeepEqual(netDef, newNDF) {
+		t.Fatalf("DeepCopy did not return an equal NDF."+
+			"\nexpected: %+v\nreceived: %+v", netDef, newNDF)
+	}
+
+	err := newNDF.Walk(func(path string, v reflect.Value) error {
+		mutateField(t, path, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk returned an error: %+v", err)
+	}
+
+	if !reflect.DeepEqual(netDef, newFullTestNdf(t)) {
+		t.Errorf("Modifying the copy modified the original."+
+			"\nexpected: %+v\nreceived: %+v", newFullTestNdf(t), netDef)
+	}
+}
+
+// Tests that DeepCopy keeps nil slices nil and empty slices empty.
+func TestNetworkDefinition_DeepCopy_NilSlices(t *testing.T) {
+	netDef := &NetworkDefinition{Gateways: []Gateway{}}
+	newNDF := netDef.DeepCopy()
+	if newNDF.Gateways == nil || newNDF.Nodes != nil {
+		t.Errorf("Unexpected slices in copy: %+v", newNDF)
+	}
+}
+
+// Tests that the copy made by NetworkDefinition.DeepCopy marshals to the same
+// JSON as the original when its slices are nil, empty, or populated, including
+// the slices nested in slice elements.
+func TestNetworkDefinition_DeepCopy_Marshal(t *testing.T) {
+	empty := newFullTestNdf(t)
+	setEmptySlices(reflect.ValueOf(empty).Elem())
+	emptyElements := newFullTestNdf(t)
+	emptyElements.Gateways[0].ID = []byte{}
+	emptyElements.Nodes[0].ID = nil
+	emptyElements.WhitelistedIds = []string{}
+
+	for i, netDef := range []*NetworkDefinition{{}, {Gateways: []Gateway{}},
+		newFullTestNdf(t), exampleNdf(t), empty, emptyElements} {
+		expected, err := netDef.Marshal()
+		if err != nil {
+			t.Fatalf("Failed to marshal NDF #%d: %+v", i, err)
+		}
+		received, err := netDef.DeepCopy().Marshal()
+		if err != nil {
+			t.Fatalf("Failed to marshal copy of NDF #%d: %+v", i, err)
+		}
+
+		if !bytes.Equal(expected, received) {
+			t.Errorf("Copy of NDF #%d marshals differently."+
+				"\nexpected: %s\nreceived: %s", i, expected, received)
+		}
+	}
+}
+
+// setEmptySlices replaces every nil slice in the value with an empty slice.
+func setEmptySlices(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				setEmptySlices(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		}
+		for i := 0; i < v.Len(); i++ {
+			setEmptySlices(v.Index(i))
+		}
+	}
+}
+
+// Tests that NetworkDefinition.Equal detects a change to any single field.
+func TestNetworkDefinition_Equal(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	if !netDef.Equal(netDef.DeepCopy()) {
+		t.Fatalf("NDF is not equal to its copy.")
+	}
+
+	var numFields int
+	_ = netDef.Walk(func(string, reflect.Value) error {
+		numFields++
+		return nil
+	})
+
+	for i := 0; i < numFields; i++ {
+		modified := netDef.DeepCopy()
+		var j int
+		var mutated string
+		_ = modified.Walk(func(path string, v reflect.Value) error {
+			if j == i {
+				mutateField(t, path, v)
+				mutated = path
+			}
+			j++
+			return nil
+		})
+
+		if netDef.Equal(modified) {
+			t.Errorf("Equal did not detect a change to %s.", mutated)
+		}
+	}
+}
+
+// Tests that NetworkDefinition.Equal treats nil and empty slices as equal and
+// compares times by instant only.
+func TestNetworkDefinition_Equal_Normalization(t *testing.T) {
+	ts := time.Date(2023, 5, 17, 12, 0, 0, 0, time.FixedZone("A", 3600))
+	a := &NetworkDefinition{Timestamp: ts, Nodes: []Node{}}
+	b := &NetworkDefinition{Timestamp: ts.In(time.FixedZone("B", 3600))}
+	if !a.Equal(b) {
+		t.Errorf("Expected NDFs to be equal.\na: %+v\nb: %+v", a, b)
+	}
+
+	b.Timestamp = ts.In(time.FixedZone("B", 7200))
+	if !a.Equal(b) {
+		t.Errorf("Expected the same instant in different zones to be equal.")
+	}
+
+	b.Timestamp = ts.Add(time.Nanosecond)
+	if a.Equal(b) {
+		t.Errorf("Expected NDFs with different timestamps to differ.")
+	}
+
+	if a.Equal(nil) || !(*NetworkDefinition)(nil).Equal(nil) {
+		t.Errorf("Unexpected result comparing nil NDFs.")
+	}
+}
@@ -0,0 +1,127 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// group.go contains parsing and safety checks for the cyclic group parameters
+// stored as hex strings in a Group.
+
+import (
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// primalityRounds is the number of Miller-Rabin rounds used to check that the
+// group primes are prime, in addition to the Baillie-PSW test performed by
+// big.Int.ProbablyPrime.
+const primalityRounds = 4
+
+// Error messages.
+const (
+	groupHexErr       = "group %s %q is not valid hex"
+	groupNotPrimeErr  = "%s is not prime"
+	groupSafePrimeErr = "Prime is not equal to 2*SmallPrime+1"
+	groupGenRangeErr  = "Generator must be in the range (1, Prime-1)"
+	groupSubgroupErr  = "Generator does not generate the subgroup of order " +
+		"SmallPrime"
+)
+
+// GetPrime returns the group prime p.
+func (g *Group) GetPrime() (*big.Int, error) {
+	return parseGroupHex("Prime", g.Prime)
+}
+
+// GetSmallPrime returns the group small prime q, where p = 2q + 1.
+func (g *Group) GetSmallPrime() (*big.Int, error) {
+	return parseGroupHex("SmallPrime", g.SmallPrime)
+}
+
+// GetGenerator returns the group generator g.
+func (g *Group) GetGenerator() (*big.Int, error) {
+	return parseGroupHex("Generator", g.Generator)
+}
+
+// BitLen returns the length of the group prime in bits.
+func (g *Group) BitLen() (int, error) {
+	p, err := g.GetPrime()
+	if err != nil {
+		return 0, err
+	}
+	return p.BitLen(), nil
+}
+
+// Validate checks that the group is safe to use: p and q are prime, p = 2q + 1,
+// and the generator generates the subgroup of order q. The primality checks
+// are expensive for large groups, so Validate is not called by
+// NetworkDefinition.Validate.
+func (g *Group) Validate() error {
+	p, q, gen, err := g.parse()
+	if err != nil {
+		return err
+	}
+
+	if err = checkGroupStructure(p, q, gen); err != nil {
+		return err
+	}
+
+	if !p.ProbablyPrime(primalityRounds) {
+		return errors.Errorf(groupNotPrimeErr, "Prime")
+	}
+	if !q.ProbablyPrime(primalityRounds) {
+		return errors.Errorf(groupNotPrimeErr, "SmallPrime")
+	}
+
+	return nil
+}
+
+// parse returns p, q, and g.
+func (g *Group) parse() (p, q, gen *big.Int, err error) {
+	if p, err = g.GetPrime(); err != nil {
+		return nil, nil, nil, err
+	}
+	if q, err = g.GetSmallPrime(); err != nil {
+		return nil, nil, nil, err
+	}
+	if gen, err = g.GetGenerator(); err != nil {
+		return nil, nil, nil, err
+	}
+	return p, q, gen, nil
+}
+
+// checkGroupStructure performs the inexpensive checks of Group.Validate:
+// p = 2q + 1, 1 < g < p - 1, and g^q = 1 mod p, meaning g has order q when q is
+// prime.
+func checkGroupStructure(p, q, gen *big.Int) error {
+	one := big.NewInt(1)
+
+	twoQPlusOne := new(big.Int).Lsh(q, 1)
+	twoQPlusOne.Add(twoQPlusOne, one)
+	if p.Cmp(twoQPlusOne) != 0 {
+		return errors.New(groupSafePrimeErr)
+	}
+
+	pMinusOne := new(big.Int).Sub(p, one)
+	if gen.Cmp(one) <= 0 || gen.Cmp(pMinusOne) >= 0 {
+		return errors.New(groupGenRangeErr)
+	}
+
+	if new(big.Int).Exp(gen, q, p).Cmp(one) != 0 {
+		return errors.New(groupSubgroupErr)
+	}
+
+	return nil
+}
+
+// parseGroupHex parses the named hex group value.
+func parseGroupHex(name, s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok || n.Sign() < 0 {
+		return nil, errors.Errorf(groupHexErr, name, s)
+	}
+	return n, nil
+}
@@ -0,0 +1,155 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// The 4096-bit MODP group from RFC 3526 section 5, which has the same form as
+// the 4096-bit CMIX and E2E groups used on the network: a safe prime with a
+// generator of the subgroup of prime order q = (p-1)/2.
+const (
+	testPrime4096 = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D7" +
+		"88719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8" +
+		"DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2" +
+		"233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA9" +
+		"93B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFF"
+
+	testSmallPrime4096 = "7FFFFFFFFFFFFFFFE487ED5110B4611A62633145C06E0E68948127044533E63A" +
+		"0105DF531D89CD9128A5043CC71A026EF7CA8CD9E69D218D98158536F92F8A1B" +
+		"A7F09AB6B6A8E122F242DABB312F3F637A262174D31BF6B585FFAE5B7A035BF6" +
+		"F71C35FDAD44CFD2D74F9208BE258FF324943328F6722D9EE1003E5C50B1DF82" +
+		"CC6D241B0E2AE9CD348B1FD47E9267AFC1B2AE91EE51D6CB0E3179AB1042A95D" +
+		"CF6A9483B84B4B36B3861AA7255E4C0278BA3604650C10BE19482F23171B671D" +
+		"F1CF3B960C074301CD93C1D17603D147DAE2AEF837A62964EF15E5FB4AAC0B8C" +
+		"1CCAA4BE754AB5728AE9130C4C7D02880AB9472D45556216D6998B8682283D19" +
+		"D42A90D5EF8E5D32767DC2822C6DF785457538ABAE83063ED9CB87C2D370F263" +
+		"D5FAD7466D8499EB8F464A702512B0CEE771E9130D697735F897FD036CC50432" +
+		"6C3B01399F643532290F958C0BBD90065DF08BABBD30AEB63B84C4605D6CA371" +
+		"047127D03A72D598A1EDADFE707E884725C16890549084008D391E0953C3F36B" +
+		"C438CD085EDD2D934CE1938C357A711E0D4A341A5B0A85ED12C1F4E5156A2674" +
+		"6DDDE16D826F477C97477E0A0FDF6553143E2CA3A735E02ECCD94B27D04861D1" +
+		"119DD0C328ADF3F68FB094B867716BD7DC0DEEBB10B8240E68034893EAD82D54" +
+		"C9DA754C46C7EEE0C37FDBEE48536047A6FA1AE49A0318CCFFFFFFFFFFFFFFFF"
+)
+
+// newTestGroup4096 returns the 4096-bit test group.
+func newTestGroup4096() Group {
+	return Group{
+		Prime:      testPrime4096,
+		SmallPrime: testSmallPrime4096,
+		Generator:  "02",
+	}
+}
+
+// Tests that the 4096-bit group and the groups of the example NDF are valid
+// and have the expected bit lengths.
+func TestGroup_Validate(t *testing.T) {
+	netDef := newValidTestNdf(t)
+	netDef.CMIX = newTestGroup4096()
+	netDef.E2E = newTestGroup4096()
+
+	for _, tt := range []struct {
+		name   string
+		group  Group
+		bitLen int
+	}{
+		{"4096-bit", newTestGroup4096(), 4096},
+		{"example CMIX", exampleNdf(t).CMIX, 2048},
+		{"example E2E", exampleNdf(t).E2E, 2048},
+	} {
+		if err := tt.group.Validate(); err != nil {
+			t.Errorf("%s group is invalid: %+v", tt.name, err)
+		}
+
+		bitLen, err := tt.group.BitLen()
+		if err != nil {
+			t.Errorf("Failed to get bit length of %s group: %+v", tt.name, err)
+		} else if bitLen != tt.bitLen {
+			t.Errorf("Unexpected bit length of %s group."+
+				"\nexpected: %d\nreceived: %d", tt.name, tt.bitLen, bitLen)
+		}
+	}
+
+	if errs := netDef.Validate(); len(errs) != 0 {
+		t.Errorf("NDF with 4096-bit groups is invalid: %+v", errs)
+	}
+}
+
+// Tests that the group values are returned as integers.
+func TestGroup_GetPrime_GetSmallPrime_GetGenerator(t *testing.T) {
+	g := newTestGroup4096()
+
+	p, err := g.GetPrime()
+	if err != nil {
+		t.Fatalf("GetPrime returned an error: %+v", err)
+	}
+	q, err := g.GetSmallPrime()
+	if err != nil {
+		t.Fatalf("GetSmallPrime returned an error: %+v", err)
+	}
+	gen, err := g.GetGenerator()
+	if err != nil {
+		t.Fatalf("GetGenerator returned an error: %+v", err)
+	}
+
+	if !strings.EqualFold(p.Text(16), testPrime4096) ||
+		!strings.EqualFold(q.Text(16), testSmallPrime4096) ||
+		gen.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("Unexpected group values.\np: %X\nq: %X\ng: %s", p, q, gen)
+	}
+
+	// Modifying the returned values must not affect later calls
+	p.SetInt64(5)
+	if p2, _ := g.GetPrime(); p2.Cmp(p) == 0 {
+		t.Errorf("GetPrime returned a shared value.")
+	}
+}
+
+// Error path: Tests that Group.Validate reports each kind of unsafe group.
+func TestGroup_Validate_Error(t *testing.T) {
+	// p - 2 is not a quadratic residue, so it does not generate the subgroup
+	p, _ := new(big.Int).SetString(testPrime4096, 16)
+	badGenerator := new(big.Int).Sub(p, big.NewInt(2)).Text(16)
+
+	for _, tt := range []struct {
+		name     string
+		group    Group
+		expected string
+	}{
+		{"InvalidHex", Group{"zz", "0B", "02"}, "group Prime \"zz\" is not valid hex"},
+		{"NegativeHex", Group{"17", "-0B", "02"}, "group SmallPrime"},
+		{"NotSafePrime", Group{"17", "0A", "02"}, groupSafePrimeErr},
+		{"GeneratorOne", Group{"17", "0B", "01"}, groupGenRangeErr},
+		{"GeneratorPMinusOne", Group{"17", "0B", "16"}, groupGenRangeErr},
+		{"GeneratorNonResidue", Group{"17", "0B", "05"}, groupSubgroupErr},
+		{"SmallPrimeComposite", Group{"13", "09", "07"}, "SmallPrime is not prime"},
+		{"BadGenerator4096", Group{testPrime4096, testSmallPrime4096, badGenerator},
+			groupSubgroupErr},
+	} {
+		err := tt.group.Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Unexpected error for %s.\nexpected: %s\nreceived: %+v",
+				tt.name, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// history.go contains an on-disk, append-only store of past NDFs that allows
+// operators to look up and roll back to earlier revisions.
+//
+// Each revision is stored as the JSON NDF in its own file named
+//
+//	<number>_<timestamp>_<hash>.ndf.json
+//
+// where number is the zero-padded revision number, timestamp is the NDF
+// timestamp in Unix nanoseconds, and hash is the hex NetworkDefinition.Digest.
+// Files are written to a temporary file, synced, and renamed into place, so a
+// crash never leaves a partial revision.
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/xx_network/primitives/utils"
+)
+
+const (
+	// revisionExt is the file extension of stored revisions.
+	revisionExt = ".ndf.json"
+
+	// tempExt is the extension of revisions that are being written.
+	tempExt = ".tmp"
+)
+
+// Error messages.
+const (
+	historyDirErr      = "failed to open NDF history directory %q"
+	historyFileErr     = "invalid NDF history file name %q"
+	historyEmptyErr    = "NDF history is empty"
+	historyNoRevErr    = "NDF history has no revision %d"
+	historyAsOfErr     = "NDF history has no revision at or before %s"
+	historyTimeErr     = "NDF timestamp %s is not after the current revision timestamp %s"
+	historyReadErr     = "failed to read NDF revision %d"
+	historyHashErr     = "NDF revision %d does not match its hash"
+	historyWriteErr    = "failed to write NDF revision %d"
+	historyMarshalErr  = "failed to marshal NDF revision"
+	historyRollbackErr = "failed to roll back to NDF revision %d"
+)
+
+// Revision describes an NDF stored in a History.
+type Revision struct {
+	// Number is the position of the revision in the history, starting at 0.
+	Number uint64
+
+	// Timestamp is the timestamp of the NDF.
+	Timestamp time.Time
+
+	// Hash is the NetworkDefinition.Digest of the NDF.
+	Hash []byte
+}
+
+// History is an append-only store of NDFs in a directory. Revisions are
+// ordered by number and have strictly increasing timestamps. It is safe for
+// concurrent use within a single process.
+type History struct {
+	dir       string
+	revisions []Revision
+	mux       sync.RWMutex
+}
+
+// OpenHistory opens the history stored in the directory, creating the
+// directory if it does not exist. Temporary files left by an interrupted write
+// are removed.
+func OpenHistory(dir string) (*History, error) {
+	dir, err := utils.ExpandPath(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, historyDirErr, dir)
+	}
+
+	// MakeDirs creates the parent directories of the path
+	if err = utils.MakeDirs(filepath.Join(dir, "revision"), utils.DirPerms); err != nil {
+		return nil, errors.Wrapf(err, historyDirErr, dir)
+	}
+
+	files, err := utils.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, historyDirErr, dir)
+	}
+
+	h := &History{dir: dir}
+	for _, name := range files {
+		if strings.HasSuffix(name, tempExt) {
+			jww.WARN.Printf("Removing incomplete NDF revision %s", name)
+			if err = os.Remove(filepath.Join(dir, name)); err != nil {
+				return nil, errors.Wrapf(err, historyDirErr, dir)
+			}
+			continue
+		} else if !strings.HasSuffix(name, revisionExt) {
+			continue
+		}
+
+		rev, err := parseRevisionName(name)
+		if err != nil {
+			return nil, err
+		}
+		h.revisions = append(h.revisions, rev)
+	}
+
+	sort.Slice(h.revisions, func(i, j int) bool {
+		return h.revisions[i].Number < h.revisions[j].Number
+	})
+	for i, rev := range h.revisions {
+		if rev.Number != uint64(i) {
+			return nil, errors.Errorf(historyNoRevErr, i)
+		}
+	}
+
+	return h, nil
+}
+
+// Append stores the NDF as a new revision. The NDF timestamp must be after the
+// timestamp of the current revision. If the NDF matches the current revision,
+// then nothing is stored and the current revision is returned.
+func (h *History) Append(ndf *NetworkDefinition) (Revision, error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return h.append(ndf)
+}
+
+// Current returns the newest revision and its NDF.
+func (h *History) Current() (*NetworkDefinition, Revision, error) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	if len(h.revisions) == 0 {
+		return nil, Revision{}, errors.New(historyEmptyErr)
+	}
+	rev := h.revisions[len(h.revisions)-1]
+	ndf, err := h.read(rev)
+	return ndf, rev, err
+}
+
+// AsOf returns the revision that was current at the given time, which is the
+// newest revision with a timestamp at or before it, and its NDF.
+func (h *History) AsOf(t time.Time) (*NetworkDefinition, Revision, error) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	i := sort.Search(len(h.revisions), func(i int) bool {
+		return h.revisions[i].Timestamp.After(t)
+	})
+	if i == 0 {
+		return nil, Revision{}, errors.Errorf(historyAsOfErr, t)
+	}
+
+	rev := h.revisions[i-1]
+	ndf, err := h.read(rev)
+	return ndf, rev, err
+}
+
+// Get returns the NDF of the revision with the given number.
+func (h *History) Get(number uint64) (*NetworkDefinition, error) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	if number >= uint64(len(h.revisions)) {
+		return nil, errors.Errorf(historyNoRevErr, number)
+	}
+	return h.read(h.revisions[number])
+}
+
+// Revisions returns every revision in the history from oldest to newest.
+func (h *History) Revisions() []Revision {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return deepCopy(h.revisions)
+}
+
+// Diff returns the Delta that transforms the NDF of revision from into the
+// NDF of revision to.
+func (h *History) Diff(from, to uint64) (*Delta, error) {
+	fromNdf, err := h.Get(from)
+	if err != nil {
+		return nil, err
+	}
+	toNdf, err := h.Get(to)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(fromNdf, toNdf), nil
+}
+
+// Rollback restores the NDF of an earlier revision by appending it as a new
+// revision with the given timestamp, which must be after the timestamp of the
+// current revision so that watchers accept it. The history itself is never
+// rewritten.
+func (h *History) Rollback(number uint64, timestamp time.Time) (Revision, error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if number >= uint64(len(h.revisions)) {
+		return Revision{}, errors.Errorf(historyNoRevErr, number)
+	}
+
+	ndf, err := h.read(h.revisions[number])
+	if err != nil {
+		return Revision{}, errors.WithMessagef(err, historyRollbackErr, number)
+	}
+	ndf.Timestamp = timestamp
+
+	rev, err := h.append(ndf)
+	if err != nil {
+		return Revision{}, errors.WithMessagef(err, historyRollbackErr, number)
+	}
+	return rev, nil
+}
+
+// append stores the NDF as a new revision. The caller must hold the write
+// lock.
+func (h *History) append(ndf *NetworkDefinition) (Revision, error) {
+	data, err := ndf.Marshal()
+	if err != nil {
+		return Revision{}, errors.Wrap(err, historyMarshalErr)
+	}
+
+	// The hash is of the NDF as it is read back from the stored bytes, so that
+	// anything lost in the JSON encoding cannot cause a mismatch in read
+	stored, err := Unmarshal(data)
+	if err != nil {
+		return Revision{}, errors.Wrap(err, historyMarshalErr)
+	}
+
+	rev := Revision{
+		Number:    uint64(len(h.revisions)),
+		Timestamp: time.Unix(0, stored.Timestamp.UnixNano()),
+		Hash:      stored.Digest(),
+	}
+
+	if len(h.revisions) > 0 {
+		current := h.revisions[len(h.revisions)-1]
+		if bytes.Equal(current.Hash, rev.Hash) {
+			return deepCopy(current), nil
+		} else if !rev.Timestamp.After(current.Timestamp) {
+			return Revision{}, errors.Errorf(
+				historyTimeErr, ndf.Timestamp, current.Timestamp)
+		}
+	}
+
+	if err = writeFileAtomic(h.path(rev), data); err != nil {
+		return Revision{}, errors.Wrapf(err, historyWriteErr, rev.Number)
+	}
+
+	h.revisions = append(h.revisions, rev)
+	return deepCopy(rev), nil
+}
+
+// read loads the NDF of the revision and checks it against its hash.
+func (h *History) read(rev Revision) (*NetworkDefinition, error) {
+	data, err := utils.ReadFile(h.path(rev))
+	if err != nil {
+		return nil, errors.Wrapf(err, historyReadErr, rev.Number)
+	}
+
+	ndf, err := Unmarshal(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, historyReadErr, rev.Number)
+	}
+
+	if !bytes.Equal(ndf.Digest(), rev.Hash) {
+		return nil, errors.Errorf(historyHashErr, rev.Number)
+	}
+
+	return ndf, nil
+}
+
+// path returns the path of the file storing the revision.
+func (h *History) path(rev Revision) string {
+	name := fmt.Sprintf("%020d_%d_%s%s", rev.Number, rev.Timestamp.UnixNano(),
+		hex.EncodeToString(rev.Hash), revisionExt)
+	return filepath.Join(h.dir, name)
+}
+
+// parseRevisionName parses the revision described by a file name produced by
+// History.path.
+func parseRevisionName(name string) (Revision, error) {
+	parts := strings.Split(strings.TrimSuffix(name, revisionExt), "_")
+	if len(parts) != 3 {
+		return Revision{}, errors.Errorf(historyFileErr, name)
+	}
+
+	number, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return Revision{}, errors.Wrapf(err, historyFileErr, name)
+	}
+
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Revision{}, errors.Wrapf(err, historyFileErr, name)
+	}
+
+	hash, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return Revision{}, errors.Wrapf(err, historyFileErr, name)
+	}
+
+	return Revision{Number: number, Timestamp: time.Unix(0, nanos), Hash: hash}, nil
+}
+
+// writeFileAtomic writes the data to a temporary file, syncs it to disk, and
+// renames it to the path so that the file is either absent or complete.
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + tempExt
+	if err := utils.WriteFile(tmpPath, data, utils.FilePerms, utils.DirPerms); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(tmpPath, os.O_RDWR, utils.FilePerms)
+	if err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// Sync the directory so that the rename is durable. Not every platform
+	// supports syncing directories, so failures are ignored.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,286 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/xx_network/primitives/utils"
+)
+
+// newTestHistory opens a History in a temporary directory and appends the
+// given number of NDFs, each a minute apart with a different client version.
+func newTestHistory(t *testing.T, n int) (*History, string) {
+	dir := filepath.Join(t.TempDir(), "history")
+	h, err := OpenHistory(dir)
+	if err != nil {
+		t.Fatalf("Failed to open history: %+v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		if _, err = h.Append(newRevisionNdf(t, i)); err != nil {
+			t.Fatalf("Failed to append NDF %d: %+v", i, err)
+		}
+	}
+
+	return h, dir
+}
+
+// newRevisionNdf returns the i-th NDF appended by newTestHistory.
+func newRevisionNdf(t *testing.T, i int) *NetworkDefinition {
+	netDef := newTimestampedNdf(t, time.Duration(i)*time.Minute)
+	netDef.ClientVersion = fmt.Sprintf("4.0.%d", i)
+	return netDef
+}
+
+// Tests that NDFs with local timestamps can be read back after being appended,
+// both when the local zone is UTC and when it is not.
+func TestHistory_Append_LocalTimestamp(t *testing.T) {
+	local := time.Local
+	defer func() { time.Local = local }()
+
+	for _, loc := range []*time.Location{
+		time.FixedZone("UTC", 0), time.FixedZone("EDT", -4*3600)} {
+		time.Local = loc
+		h, _ := newTestHistory(t, 0)
+
+		netDef := newFullTestNdf(t)
+		netDef.Timestamp = time.Now()
+		netDef.AddressSpace[0].Timestamp = time.Now().Add(-time.Hour)
+
+		rev, err := h.Append(netDef)
+		if err != nil {
+			t.Fatalf("Failed to append NDF (%s): %+v", loc, err)
+		}
+
+		current, currentRev, err := h.Current()
+		if err != nil {
+			t.Fatalf("Failed to read current NDF (%s): %+v", loc, err)
+		}
+		if !bytes.Equal(currentRev.Hash, rev.Hash) ||
+			!bytes.Equal(current.Digest(), netDef.Digest()) {
+			t.Errorf("Current NDF does not match appended NDF (%s).", loc)
+		}
+
+		// Appending the same NDF again does not add a revision
+		if _, err = h.Append(netDef); err != nil || len(h.Revisions()) != 1 {
+			t.Errorf("Appending the same NDF changed the history (%s): %+v",
+				loc, err)
+		}
+	}
+}
+
+// Tests that appended revisions can be listed and read back, including after
+// reopening the history.
+func TestHistory_Append_Revisions(t *testing.T) {
+	h, dir := newTestHistory(t, 3)
+
+	for _, hist := range []*History{h, reopenHistory(t, dir)} {
+		revs := hist.Revisions()
+		if len(revs) != 3 {
+			t.Fatalf("Expected 3 revisions, received %d.", len(revs))
+		}
+
+		for i, rev := range revs {
+			expected := newRevisionNdf(t, i)
+			if rev.Number != uint64(i) ||
+				!rev.Timestamp.Equal(expected.Timestamp) ||
+				!bytes.Equal(rev.Hash, expected.Digest()) {
+				t.Errorf("Unexpected revision %d: %+v", i, rev)
+			}
+
+			received, err := hist.Get(rev.Number)
+			if err != nil {
+				t.Fatalf("Failed to get revision %d: %+v", i, err)
+			}
+			if !received.Equal(expected) {
+				t.Errorf("Unexpected NDF for revision %d."+
+					"\nexpected: %+v\nreceived: %+v", i, expected, received)
+			}
+		}
+
+		current, rev, err := hist.Current()
+		if err != nil || rev.Number != 2 || !current.Equal(newRevisionNdf(t, 2)) {
+			t.Errorf("Unexpected current revision %+v: %+v", rev, err)
+		}
+	}
+}
+
+// Tests that History.Append ignores an NDF that matches the current revision
+// and rejects an NDF whose timestamp is not newer.
+func TestHistory_Append_Order(t *testing.T) {
+	h, _ := newTestHistory(t, 2)
+
+	rev, err := h.Append(newRevisionNdf(t, 1))
+	if err != nil || rev.Number != 1 || len(h.Revisions()) != 2 {
+		t.Errorf("Unexpected result appending current NDF %+v: %+v", rev, err)
+	}
+
+	older := newRevisionNdf(t, 1)
+	older.ClientVersion = "changed"
+	_, err = h.Append(older)
+	if err == nil || !strings.Contains(err.Error(), "is not after") {
+		t.Errorf("Unexpected error for NDF with same timestamp: %+v", err)
+	}
+}
+
+// Tests that History.AsOf returns the revision current at each time.
+func TestHistory_AsOf(t *testing.T) {
+	h, _ := newTestHistory(t, 3)
+	start := newRevisionNdf(t, 0).Timestamp
+
+	for _, tt := range []struct {
+		offset   time.Duration
+		expected uint64
+	}{
+		{0, 0},
+		{30 * time.Second, 0},
+		{time.Minute, 1},
+		{90 * time.Second, 1},
+		{time.Hour, 2},
+	} {
+		ndf, rev, err := h.AsOf(start.Add(tt.offset))
+		if err != nil {
+			t.Errorf("AsOf(%s) returned an error: %+v", tt.offset, err)
+		} else if rev.Number != tt.expected ||
+			!ndf.Equal(newRevisionNdf(t, int(tt.expected))) {
+			t.Errorf("AsOf(%s) returned revision %d; expected %d.",
+				tt.offset, rev.Number, tt.expected)
+		}
+	}
+
+	if _, _, err := h.AsOf(start.Add(-time.Nanosecond)); err == nil {
+		t.Errorf("AsOf did not return an error before the first revision.")
+	}
+}
+
+// Tests that the Delta returned by History.Diff transforms one revision into
+// the other.
+func TestHistory_Diff(t *testing.T) {
+	h, _ := newTestHistory(t, 3)
+
+	d, err := h.Diff(0, 2)
+	if err != nil {
+		t.Fatalf("Diff returned an error: %+v", err)
+	}
+
+	result, err := Apply(newRevisionNdf(t, 0), d)
+	if err != nil {
+		t.Fatalf("Failed to apply delta: %+v", err)
+	}
+	if !result.Equal(newRevisionNdf(t, 2)) {
+		t.Errorf("Applied delta does not produce revision 2.")
+	}
+
+	if _, err = h.Diff(0, 3); err == nil {
+		t.Errorf("Diff did not return an error for a missing revision.")
+	}
+}
+
+// Tests that History.Rollback appends the old NDF with the new timestamp and
+// keeps the rest of the history.
+func TestHistory_Rollback(t *testing.T) {
+	h, dir := newTestHistory(t, 3)
+	ts := newRevisionNdf(t, 2).Timestamp.Add(time.Hour)
+
+	rev, err := h.Rollback(0, ts)
+	if err != nil {
+		t.Fatalf("Rollback returned an error: %+v", err)
+	}
+	if rev.Number != 3 || !rev.Timestamp.Equal(ts) {
+		t.Errorf("Unexpected revision: %+v", rev)
+	}
+
+	expected := newRevisionNdf(t, 0)
+	expected.Timestamp = ts
+	current, _, err := reopenHistory(t, dir).Current()
+	if err != nil || !current.Equal(expected) {
+		t.Errorf("Unexpected current NDF after rollback: %+v", err)
+	}
+	if len(h.Revisions()) != 4 {
+		t.Errorf("Rollback did not keep the history.")
+	}
+
+	if _, err = h.Rollback(1, ts); err == nil {
+		t.Errorf("Rollback accepted a timestamp that is not newer.")
+	}
+	if _, err = h.Rollback(10, ts.Add(time.Hour)); err == nil {
+		t.Errorf("Rollback accepted a missing revision.")
+	}
+}
+
+// Tests that OpenHistory removes incomplete writes and that a revision whose
+// contents do not match its hash is rejected.
+func TestOpenHistory_Recovery(t *testing.T) {
+	h, dir := newTestHistory(t, 2)
+
+	tmpPath := filepath.Join(dir, "00000000000000000002_0_00"+revisionExt+tempExt)
+	if err := utils.WriteFileDef(tmpPath, []byte("partial")); err != nil {
+		t.Fatalf("Failed to write file: %+v", err)
+	}
+
+	h = reopenHistory(t, dir)
+	if utils.Exists(tmpPath) {
+		t.Errorf("Incomplete revision was not removed.")
+	}
+	if len(h.Revisions()) != 2 {
+		t.Errorf("Expected 2 revisions, received %d.", len(h.Revisions()))
+	}
+
+	tampered := newRevisionNdf(t, 1)
+	tampered.ClientVersion = "tampered"
+	data, _ := tampered.Marshal()
+	if err := os.WriteFile(h.path(h.Revisions()[1]), data, 0644); err != nil {
+		t.Fatalf("Failed to write file: %+v", err)
+	}
+	if _, _, err := h.Current(); err == nil ||
+		!strings.Contains(err.Error(), "does not match its hash") {
+		t.Errorf("Unexpected error for tampered revision: %+v", err)
+	}
+}
+
+// Error path: Tests that OpenHistory rejects revision files with invalid names
+// and histories with missing revisions.
+func TestOpenHistory_InvalidFiles(t *testing.T) {
+	_, dir := newTestHistory(t, 0)
+	badPath := filepath.Join(dir, "bad"+revisionExt)
+	if err := utils.WriteFileDef(badPath, []byte("{}")); err != nil {
+		t.Fatalf("Failed to write file: %+v", err)
+	}
+	if _, err := OpenHistory(dir); err == nil {
+		t.Errorf("OpenHistory accepted an invalid file name.")
+	}
+
+	h, dir := newTestHistory(t, 2)
+	if err := os.Remove(h.path(h.Revisions()[0])); err != nil {
+		t.Fatalf("Failed to remove file: %+v", err)
+	}
+	if _, err := OpenHistory(dir); err == nil {
+		t.Errorf("OpenHistory accepted a history with a missing revision.")
+	}
+
+	empty, _ := newTestHistory(t, 0)
+	if _, _, err := empty.Current(); err == nil {
+		t.Errorf("Current did not return an error for an empty history.")
+	}
+}
+
+// reopenHistory opens the history in the directory again.
+func reopenHistory(t *testing.T, dir string) *History {
+	h, err := OpenHistory(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen history: %+v", err)
+	}
+	return h
+}
@@ -0,0 +1,142 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// index.go contains Index, an immutable indexed view of a NetworkDefinition.
+
+import (
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+)
+
+// Index is an immutable, indexed view of a NetworkDefinition that allows
+// gateways and nodes to be looked up without looping over the NDF. It holds
+// its own copy of the NDF and only returns copies, so it is safe to share
+// across goroutines.
+//
+// Gateways and nodes whose IDs fail to unmarshal cannot be looked up by ID but
+// are still included in the grouped lists. If an ID appears more than once,
+// lookups return the first entry.
+type Index struct {
+	ndf *NetworkDefinition
+
+	gatewaysByID map[id.ID]int
+	nodesByID    map[id.ID]int
+	byBin        map[region.GeoBin][]int
+	byStatus     map[Status][]int
+}
+
+// NewIndex builds an Index from a copy of the NetworkDefinition.
+func NewIndex(ndf *NetworkDefinition) *Index {
+	ndf = ndf.DeepCopy()
+	idx := &Index{
+		ndf:          ndf,
+		gatewaysByID: make(map[id.ID]int, len(ndf.Gateways)),
+		nodesByID:    make(map[id.ID]int, len(ndf.Nodes)),
+		byBin:        make(map[region.GeoBin][]int),
+		byStatus:     make(map[Status][]int),
+	}
+
+	for i, gw := range ndf.Gateways {
+		if gwID, err := gw.GetGatewayId(); err == nil {
+			if _, exists := idx.gatewaysByID[*gwID]; !exists {
+				idx.gatewaysByID[*gwID] = i
+			}
+		}
+		idx.byBin[gw.Bin] = append(idx.byBin[gw.Bin], i)
+	}
+
+	for i, node := range ndf.Nodes {
+		if nodeID, err := node.GetNodeId(); err == nil {
+			if _, exists := idx.nodesByID[*nodeID]; !exists {
+				idx.nodesByID[*nodeID] = i
+			}
+		}
+		idx.byStatus[node.Status] = append(idx.byStatus[node.Status], i)
+	}
+
+	return idx
+}
+
+// NetworkDefinition returns a copy of the indexed NDF.
+func (idx *Index) NetworkDefinition() *NetworkDefinition {
+	return idx.ndf.DeepCopy()
+}
+
+// Gateway returns the gateway with the given ID. Returns false if no gateway
+// has the ID.
+func (idx *Index) Gateway(gwID *id.ID) (Gateway, bool) {
+	i, exists := idx.gatewaysByID[*gwID]
+	if !exists {
+		return Gateway{}, false
+	}
+	return deepCopy(idx.ndf.Gateways[i]), true
+}
+
+// Node returns the node with the given ID. Returns false if no node has the ID.
+func (idx *Index) Node(nodeID *id.ID) (Node, bool) {
+	i, exists := idx.nodesByID[*nodeID]
+	if !exists {
+		return Node{}, false
+	}
+	return deepCopy(idx.ndf.Nodes[i]), true
+}
+
+// GatewayForNode returns the gateway paired with the node with the given ID. A
+// node and its gateway share the same ID data and differ only in the type byte.
+// Returns false if there is no such gateway.
+func (idx *Index) GatewayForNode(nodeID *id.ID) (Gateway, bool) {
+	gwID := nodeID.DeepCopy()
+	gwID.SetType(id.Gateway)
+	return idx.Gateway(gwID)
+}
+
+// NodeForGateway returns the node paired with the gateway with the given ID.
+// Returns false if there is no such node.
+func (idx *Index) NodeForGateway(gwID *id.ID) (Node, bool) {
+	nodeID := gwID.DeepCopy()
+	nodeID.SetType(id.Node)
+	return idx.Node(nodeID)
+}
+
+// GatewaysInBin returns all gateways in the region, in NDF order.
+func (idx *Index) GatewaysInBin(bin region.GeoBin) []Gateway {
+	list := make([]Gateway, len(idx.byBin[bin]))
+	for i, j := range idx.byBin[bin] {
+		list[i] = deepCopy(idx.ndf.Gateways[j])
+	}
+	return list
+}
+
+// GatewaysByBin returns all gateways grouped by region.
+func (idx *Index) GatewaysByBin() map[region.GeoBin][]Gateway {
+	bins := make(map[region.GeoBin][]Gateway, len(idx.byBin))
+	for bin := range idx.byBin {
+		bins[bin] = idx.GatewaysInBin(bin)
+	}
+	return bins
+}
+
+// NodesWithStatus returns all nodes with the given status, in NDF order.
+func (idx *Index) NodesWithStatus(s Status) []Node {
+	list := make([]Node, len(idx.byStatus[s]))
+	for i, j := range idx.byStatus[s] {
+		list[i] = deepCopy(idx.ndf.Nodes[j])
+	}
+	return list
+}
+
+// ActiveNodes returns all nodes with the Active status.
+func (idx *Index) ActiveNodes() []Node {
+	return idx.NodesWithStatus(Active)
+}
+
+// StaleNodes returns all nodes with the Stale status.
+func (idx *Index) StaleNodes() []Node {
+	return idx.NodesWithStatus(Stale)
+}
@@ -0,0 +1,156 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+)
+
+// Tests that Index.Gateway and Index.Node find every entry by ID.
+func TestIndex_Gateway_Node(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	idx := NewIndex(netDef)
+
+	for i, gw := range netDef.Gateways {
+		gwID, _ := gw.GetGatewayId()
+		received, exists := idx.Gateway(gwID)
+		if !exists || !fieldsEqual(received, gw) {
+			t.Errorf("Failed to get gateway %d.\nexpected: %+v\nreceived: %+v",
+				i, gw, received)
+		}
+	}
+
+	for i, node := range netDef.Nodes {
+		nodeID, _ := node.GetNodeId()
+		received, exists := idx.Node(nodeID)
+		if !exists || !fieldsEqual(received, node) {
+			t.Errorf("Failed to get node %d.\nexpected: %+v\nreceived: %+v",
+				i, node, received)
+		}
+	}
+
+	if _, exists := idx.Gateway(id.NewIdFromString("unknown", id.Gateway, t)); exists {
+		t.Errorf("Found gateway that is not in the NDF.")
+	}
+	if _, exists := idx.Node(id.NewIdFromString("unknown", id.Node, t)); exists {
+		t.Errorf("Found node that is not in the NDF.")
+	}
+}
+
+// Tests that Index.GatewayForNode and Index.NodeForGateway return the paired
+// entry.
+func TestIndex_GatewayForNode_NodeForGateway(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	idx := NewIndex(netDef)
+
+	for i, node := range netDef.Nodes {
+		nodeID, _ := node.GetNodeId()
+		gw, exists := idx.GatewayForNode(nodeID)
+		if !exists || !fieldsEqual(gw, netDef.Gateways[i]) {
+			t.Errorf("Unexpected gateway for node %d: %+v", i, gw)
+		}
+
+		gwID, _ := gw.GetGatewayId()
+		pairedNode, exists := idx.NodeForGateway(gwID)
+		if !exists || !fieldsEqual(pairedNode, node) {
+			t.Errorf("Unexpected node for gateway %d: %+v", i, pairedNode)
+		}
+	}
+}
+
+// Tests that Index.GatewaysInBin and Index.GatewaysByBin group the gateways by
+// region.
+func TestIndex_GatewaysByBin(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	netDef.Gateways[2].Bin = netDef.Gateways[0].Bin
+	idx := NewIndex(netDef)
+
+	bins := idx.GatewaysByBin()
+	if len(bins) != 2 {
+		t.Errorf("Expected 2 bins, received %d: %+v", len(bins), bins)
+	}
+
+	list := idx.GatewaysInBin(netDef.Gateways[0].Bin)
+	if len(list) != 2 || !fieldsEqual(list[0], netDef.Gateways[0]) ||
+		!fieldsEqual(list[1], netDef.Gateways[2]) {
+		t.Errorf("Unexpected gateways in bin: %+v", list)
+	}
+
+	if list = idx.GatewaysInBin(region.Oceania); len(list) != 0 {
+		t.Errorf("Unexpected gateways in empty bin: %+v", list)
+	}
+}
+
+// Tests that Index.ActiveNodes and Index.StaleNodes split nodes by status.
+func TestIndex_ActiveNodes_StaleNodes(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	netDef.Nodes[1].Status = Stale
+	idx := NewIndex(netDef)
+
+	active, stale := idx.ActiveNodes(), idx.StaleNodes()
+	if len(active) != 2 || len(stale) != 1 {
+		t.Fatalf("Unexpected node split.\nactive: %+v\nstale: %+v",
+			active, stale)
+	}
+	if !fieldsEqual(stale[0], netDef.Nodes[1]) {
+		t.Errorf("Unexpected stale node: %+v", stale[0])
+	}
+}
+
+// Tests that changes to the source NDF or to returned values do not affect the
+// Index.
+func TestIndex_Immutable(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	gwID, _ := netDef.Gateways[0].GetGatewayId()
+	idx := NewIndex(netDef)
+
+	netDef.Gateways[0].ID[0] = 0xFF
+	netDef.Gateways[0].Address = "changed"
+
+	gw, exists := idx.Gateway(gwID)
+	if !exists || gw.Address == "changed" {
+		t.Fatalf("Index changed after source NDF was modified: %+v", gw)
+	}
+
+	gw.ID[0] = 0xFF
+	gw, _ = idx.Gateway(gwID)
+	if !bytes.Equal(gw.ID, gwID.Marshal()) {
+		t.Errorf("Index changed after returned gateway was modified: %+v", gw)
+	}
+
+	idx.NetworkDefinition().Nodes[0].Ed25519[0] = 0xFF
+	if idx.ActiveNodes()[0].Ed25519[0] == 0xFF {
+		t.Errorf("Index changed after returned NDF was modified.")
+	}
+}
+
+// Tests that an Index can be read concurrently.
+func TestIndex_Concurrent(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	idx := NewIndex(netDef)
+	nodeID, _ := netDef.Nodes[0].GetNodeId()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				idx.GatewayForNode(nodeID)
+				idx.GatewaysByBin()
+				idx.ActiveNodes()
+			}
+		}()
+	}
+	wg.Wait()
+}
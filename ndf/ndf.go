@@ -31,6 +31,9 @@ const NO_NDF = "Contacted server does not have an ndf to give"
 // NetworkDefinition structure hold connection and network information. It
 // matches the JSON structure generated in Terraform.
 type NetworkDefinition struct {
+	// Version of the JSON layout; see CurrentSchemaVersion. It is zero for
+	// unversioned documents, which are encoded without it.
+	SchemaVersion uint32 `json:",omitempty"`
 	Timestamp     time.Time
 	Gateways      []Gateway
 	Nodes         []Node
@@ -154,93 +157,24 @@ func (ndf *NetworkDefinition) Marshal() (data []byte, err error) {
 }
 
 // Unmarshal parses the JSON encoded data and returns the resulting
-// NetworkDefinition.
+// NetworkDefinition. Documents with an older schema version are migrated to
+// CurrentSchemaVersion; unversioned documents are decoded as they are.
 func Unmarshal(data []byte) (*NetworkDefinition, error) {
-	ndf := &NetworkDefinition{}
-	err := json.Unmarshal(data, ndf)
-	return ndf, err
+	return ndfSchema.unmarshal(data)
 }
 
-// DeepCopy returns a deep copy of the NDF. Note that this function is
-// intentionally verbose so that tests will fail if this function is not updated
-// when the NetworkDefinition is modified.
+// DeepCopy returns a deep copy of the NDF that does not share memory with the
+// original. Nil and empty slices are preserved, so the copy marshals to the
+// same JSON as the original.
 func (ndf *NetworkDefinition) DeepCopy() *NetworkDefinition {
-	newNDF := &NetworkDefinition{
-		Gateways:     make([]Gateway, len(ndf.Gateways)),
-		Nodes:        make([]Node, len(ndf.Nodes)),
-		AddressSpace: make([]AddressSpace, len(ndf.AddressSpace)),
-	}
-
-	// Copy timestamp
-	newNDF.Timestamp = ndf.Timestamp
-
-	// Copy Gateways
-	copy(newNDF.Gateways, ndf.Gateways)
-
-	// Copy Nodes
-	copy(newNDF.Nodes, ndf.Nodes)
-
-	// Copy Registration
-	newNDF.Registration = Registration{
-		Address:                   ndf.Registration.Address,
-		TlsCertificate:            ndf.Registration.TlsCertificate,
-		EllipticPubKey:            ndf.Registration.EllipticPubKey,
-		ClientRegistrationAddress: ndf.Registration.ClientRegistrationAddress,
-	}
-
-	// Copy Notification
-	newNDF.Notification = Notification{
-		Address:        ndf.Notification.Address,
-		TlsCertificate: ndf.Notification.TlsCertificate,
-	}
-
-	// Copy UD
-	newNDF.UDB = UDB{
-		ID:                          make([]byte, len(ndf.UDB.ID)),
-		Cert:                        ndf.UDB.Cert,
-		Address:                     ndf.UDB.Address,
-		DhPubKey:                    make([]byte, len(ndf.UDB.DhPubKey)),
-		ChannelSigningPubKeyEd25519: make([]byte, len(ndf.UDB.ChannelSigningPubKeyEd25519)),
-	}
-	copy(newNDF.UDB.ID, ndf.UDB.ID)
-	copy(newNDF.UDB.DhPubKey, ndf.UDB.DhPubKey)
-	copy(newNDF.UDB.ChannelSigningPubKeyEd25519, ndf.UDB.ChannelSigningPubKeyEd25519)
-
-	// Copy E2E group
-	newNDF.E2E = Group{
-		Prime:      ndf.E2E.Prime,
-		SmallPrime: ndf.E2E.SmallPrime,
-		Generator:  ndf.E2E.Generator,
-	}
-
-	// Copy CMIX group
-	newNDF.CMIX = Group{
-		Prime:      ndf.CMIX.Prime,
-		SmallPrime: ndf.CMIX.SmallPrime,
-		Generator:  ndf.CMIX.Generator,
-	}
-
-	// Copy AddressSpace
-	copy(newNDF.AddressSpace, ndf.AddressSpace)
-
-	// Copy ClientVersion
-	newNDF.ClientVersion = ndf.ClientVersion
-
-	newNDF.WhitelistedIpAddresses = ndf.WhitelistedIpAddresses
-
-	newNDF.WhitelistedIds = ndf.WhitelistedIds
-
-	newNDF.RateLimits = RateLimiting{
-		Capacity:     ndf.RateLimits.Capacity,
-		LeakedTokens: ndf.RateLimits.LeakedTokens,
-		LeakDuration: ndf.RateLimits.LeakDuration,
-	}
-
-	return newNDF
+	newNDF := deepCopy(*ndf)
+	return &newNDF
 }
 
 // StripNdf returns a stripped down copy of the NetworkDefinition to be used by
-// Clients.
+// Clients. It also drops ClientVersion, the whitelists, and RateLimits; use
+// Project with the ClientProfile profile for a policy-driven projection, which
+// returns an error instead of a partial NDF if the profile is invalid.
 func (ndf *NetworkDefinition) StripNdf() *NetworkDefinition {
 	// Remove address and TLS cert for every node.
 	var strippedNodes []Node
@@ -251,19 +185,25 @@ func (ndf *NetworkDefinition) StripNdf() *NetworkDefinition {
 
 	// Create a new NetworkDefinition with the stripped information
 	return &NetworkDefinition{
-		Timestamp:    ndf.Timestamp,
-		Gateways:     ndf.Gateways,
-		Nodes:        strippedNodes,
-		Registration: ndf.Registration,
-		Notification: ndf.Notification,
-		UDB:          ndf.UDB,
-		E2E:          ndf.E2E,
-		CMIX:         ndf.CMIX,
-		AddressSpace: ndf.AddressSpace,
+		SchemaVersion: ndf.SchemaVersion,
+		Timestamp:     ndf.Timestamp,
+		Gateways:      ndf.Gateways,
+		Nodes:         strippedNodes,
+		Registration:  ndf.Registration,
+		Notification:  ndf.Notification,
+		UDB:           ndf.UDB,
+		E2E:           ndf.E2E,
+		CMIX:          ndf.CMIX,
+		AddressSpace:  ndf.AddressSpace,
 	}
 }
 
 // Serialize serializes the NetworkDefinition into a byte slice.
+//
+// Deprecated: Serialize concatenates fields without framing, so different NDFs
+// can produce the same bytes, and it omits several fields. Use
+// SerializeCanonical instead. Serialize is kept so that hashes of its output
+// produced by older versions can still be compared.
 func (ndf *NetworkDefinition) Serialize() []byte {
 	var b []byte
 
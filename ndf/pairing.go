@@ -0,0 +1,176 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// pairing.go derives the pairing between nodes and gateways in the NDF and
+// checks that it is consistent.
+//
+// A node and its gateway have IDs with the same data and differ only in the
+// type byte, which is id.Node for the node and id.Gateway for the gateway. The
+// node and gateway are also expected to share the same index in the Nodes and
+// Gateways lists.
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// Error messages.
+const (
+	pairInvalidIdErr    = "%s[%d]: invalid ID: %+v"
+	pairWrongTypeErr    = "%s[%d]: ID has type %s; expected %s"
+	pairDuplicateErr    = "%s[%d]: ID data duplicates %s[%d]"
+	pairIndexErr        = "Nodes[%d] is paired with Gateways[%d]; expected the same index"
+	pairOrphanNodeErr   = "Nodes[%d] has no gateway"
+	pairOrphanGwErr     = "Gateways[%d] has no node"
+	inconsistentPairErr = "inconsistent node and gateway pairing: %s"
+)
+
+// Pair is a node and the gateway paired with it.
+type Pair struct {
+	NodeIndex    int
+	GatewayIndex int
+	NodeID       *id.ID
+	GatewayID    *id.ID
+}
+
+// PairingReport is the result of deriving the node and gateway pairing of an
+// NDF.
+type PairingReport struct {
+	// Pairs lists every node and gateway whose IDs match, in node order.
+	Pairs []Pair
+
+	// OrphanNodes and OrphanGateways are the indices of nodes and gateways
+	// that have no match.
+	OrphanNodes    []int
+	OrphanGateways []int
+
+	// Problems lists every inconsistency found, including orphans.
+	Problems []error
+}
+
+// Consistent returns true if every node is paired with the gateway at the same
+// index and there are no other problems.
+func (r *PairingReport) Consistent() bool {
+	return len(r.Problems) == 0
+}
+
+// Err returns an error describing all the problems in the report or nil if
+// the pairing is consistent.
+func (r *PairingReport) Err() error {
+	if r.Consistent() {
+		return nil
+	}
+
+	msgs := make([]string, len(r.Problems))
+	for i, err := range r.Problems {
+		msgs[i] = err.Error()
+	}
+	return errors.Errorf(inconsistentPairErr, strings.Join(msgs, "; "))
+}
+
+// Pairing derives the pairing between the nodes and gateways in the NDF and
+// reports any inconsistencies.
+func (ndf *NetworkDefinition) Pairing() *PairingReport {
+	r := &PairingReport{}
+
+	nodeIDs := make([]*id.ID, len(ndf.Nodes))
+	nodesByData := make(map[id.ID]int, len(ndf.Nodes))
+	for i := range ndf.Nodes {
+		nodeIDs[i] = r.checkID("Nodes", i, ndf.Nodes[i].ID, id.Node, nodesByData)
+	}
+
+	gwIDs := make([]*id.ID, len(ndf.Gateways))
+	gatewaysByData := make(map[id.ID]int, len(ndf.Gateways))
+	for i := range ndf.Gateways {
+		gwIDs[i] = r.checkID(
+			"Gateways", i, ndf.Gateways[i].ID, id.Gateway, gatewaysByData)
+	}
+
+	paired := make(map[int]bool, len(ndf.Gateways))
+	for i, nodeID := range nodeIDs {
+		if nodeID == nil {
+			continue
+		}
+
+		j, exists := gatewaysByData[idData(nodeID)]
+		if !exists {
+			r.OrphanNodes = append(r.OrphanNodes, i)
+			r.Problems = append(r.Problems, errors.Errorf(pairOrphanNodeErr, i))
+			continue
+		}
+
+		paired[j] = true
+		r.Pairs = append(r.Pairs, Pair{
+			NodeIndex:    i,
+			GatewayIndex: j,
+			NodeID:       nodeID,
+			GatewayID:    gwIDs[j],
+		})
+		if i != j {
+			r.Problems = append(r.Problems, errors.Errorf(pairIndexErr, i, j))
+		}
+	}
+
+	for j, gwID := range gwIDs {
+		if gwID != nil && !paired[j] {
+			r.OrphanGateways = append(r.OrphanGateways, j)
+			r.Problems = append(r.Problems, errors.Errorf(pairOrphanGwErr, j))
+		}
+	}
+
+	return r
+}
+
+// Pairs returns the node and gateway pairs of the NDF. An error describing
+// every problem is returned if the pairing is not consistent.
+func (ndf *NetworkDefinition) Pairs() ([]Pair, error) {
+	r := ndf.Pairing()
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return r.Pairs, nil
+}
+
+// checkID unmarshalls the ID at the given index of the named list, records any
+// problems with it, and adds it to the byData map. Returns nil if the ID is
+// invalid or its data duplicates an earlier ID in the list.
+func (r *PairingReport) checkID(list string, i int, idBytes []byte,
+	t id.Type, byData map[id.ID]int) *id.ID {
+	newID, err := id.Unmarshal(idBytes)
+	if err != nil {
+		r.Problems = append(r.Problems, errors.Errorf(pairInvalidIdErr, list, i, err))
+		return nil
+	}
+
+	if newID.GetType() != t {
+		r.Problems = append(r.Problems,
+			errors.Errorf(pairWrongTypeErr, list, i, newID.GetType(), t))
+	}
+
+	data := idData(newID)
+	if j, exists := byData[data]; exists {
+		r.Problems = append(r.Problems,
+			errors.Errorf(pairDuplicateErr, list, i, list, j))
+		return nil
+	}
+	byData[data] = i
+
+	return newID
+}
+
+// idData returns a copy of the ID with the type byte cleared so that IDs can
+// be compared by their data alone.
+func idData(fullID *id.ID) id.ID {
+	data := *fullID
+	data.SetType(id.Generic)
+	return data
+}
@@ -0,0 +1,123 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// Happy path: every node is paired with the gateway at the same index.
+func TestNetworkDefinition_Pairs(t *testing.T) {
+	netDef := newFullTestNdf(t)
+
+	pairs, err := netDef.Pairs()
+	if err != nil {
+		t.Fatalf("Pairs returned an error: %+v", err)
+	}
+
+	if len(pairs) != len(netDef.Nodes) {
+		t.Fatalf("Expected %d pairs, received %d.", len(netDef.Nodes), len(pairs))
+	}
+
+	for i, p := range pairs {
+		nodeID, _ := netDef.Nodes[i].GetNodeId()
+		gwID, _ := netDef.Gateways[i].GetGatewayId()
+		expected := Pair{i, i, nodeID, gwID}
+		if !reflect.DeepEqual(expected, p) {
+			t.Errorf("Unexpected pair %d.\nexpected: %+v\nreceived: %+v",
+				i, expected, p)
+		}
+	}
+}
+
+// Tests that NetworkDefinition.Pairing reports a pair at mismatched indices.
+func TestNetworkDefinition_Pairing_IndexMismatch(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	netDef.Gateways[0], netDef.Gateways[1] = netDef.Gateways[1], netDef.Gateways[0]
+
+	r := netDef.Pairing()
+	if r.Consistent() {
+		t.Fatalf("Pairing reported swapped gateways as consistent.")
+	}
+	if len(r.Pairs) != 3 {
+		t.Errorf("Expected 3 pairs, received %d.", len(r.Pairs))
+	}
+	if r.Pairs[0].GatewayIndex != 1 || r.Pairs[1].GatewayIndex != 0 {
+		t.Errorf("Unexpected pairs: %+v", r.Pairs)
+	}
+
+	expected := fmt.Sprintf(pairIndexErr, 0, 1)
+	if !strings.Contains(r.Err().Error(), expected) {
+		t.Errorf("Unexpected error.\nexpected: %s\nreceived: %+v",
+			expected, r.Err())
+	}
+
+	if _, err := netDef.Pairs(); err == nil {
+		t.Errorf("Pairs did not return an error for an inconsistent NDF.")
+	}
+}
+
+// Tests that NetworkDefinition.Pairing reports orphaned nodes and gateways.
+func TestNetworkDefinition_Pairing_Orphans(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	netDef.Nodes[2].ID = id.NewIdFromString("lonely", id.Node, t).Marshal()
+
+	r := netDef.Pairing()
+	if !reflect.DeepEqual(r.OrphanNodes, []int{2}) {
+		t.Errorf("Unexpected orphan nodes: %v", r.OrphanNodes)
+	}
+	if !reflect.DeepEqual(r.OrphanGateways, []int{2}) {
+		t.Errorf("Unexpected orphan gateways: %v", r.OrphanGateways)
+	}
+	if len(r.Pairs) != 2 || len(r.Problems) != 2 {
+		t.Errorf("Unexpected report: %+v", r)
+	}
+}
+
+// Tests that NetworkDefinition.Pairing reports IDs with the wrong type and IDs
+// that fail to unmarshal.
+func TestNetworkDefinition_Pairing_BadIDs(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	netDef.Gateways[0].ID[id.ArrIDLen-1] = byte(id.Node)
+	netDef.Nodes[1].ID = []byte{1, 2, 3}
+
+	r := netDef.Pairing()
+	errStr := r.Err().Error()
+	for _, expected := range []string{
+		"Gateways[0]: ID has type node; expected gateway",
+		"Nodes[1]: invalid ID",
+		"Gateways[1] has no node",
+	} {
+		if !strings.Contains(errStr, expected) {
+			t.Errorf("Missing problem %q in %s", expected, errStr)
+		}
+	}
+
+	// The gateway with the wrong type is still paired by its ID data
+	if len(r.Pairs) != 2 || r.Pairs[0].GatewayIndex != 0 {
+		t.Errorf("Unexpected pairs: %+v", r.Pairs)
+	}
+}
+
+// Tests that NetworkDefinition.Pairing reports nodes with duplicate ID data.
+func TestNetworkDefinition_Pairing_Duplicate(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	netDef.Nodes[2].ID = netDef.Nodes[0].ID
+
+	r := netDef.Pairing()
+	expected := fmt.Sprintf(pairDuplicateErr, "Nodes", 2, "Nodes", 0)
+	if r.Consistent() || !strings.Contains(r.Err().Error(), expected) {
+		t.Errorf("Unexpected error.\nexpected: %s\nreceived: %+v",
+			expected, r.Err())
+	}
+}
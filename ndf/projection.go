@@ -0,0 +1,192 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// projection.go contains policy-driven projections of the NDF that remove the
+// fields a member of the network should not receive.
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// AllFields is a Profile pattern that matches every field.
+const AllFields = "*"
+
+// Names of the built-in profiles returned by GetProfile.
+const (
+	ClientProfile  = "client"
+	GatewayProfile = "gateway"
+	PublicProfile  = "public"
+)
+
+// Error messages.
+const (
+	unknownProfileErr = "unknown NDF profile %q"
+	profilePatternErr = "profile %s: pattern %q does not name an NDF field"
+)
+
+// Profile declares which fields of the NDF are kept in a projection.
+//
+// Patterns are field paths without slice indices, such as "Nodes.Address",
+// and match the named field and everything it contains. A field is kept only
+// if it matches a Keep pattern and no Redact pattern, so fields added to the
+// NDF are excluded from a profile until it is updated to keep them.
+type Profile struct {
+	Name   string
+	Keep   []string
+	Redact []string
+}
+
+// clientProfile is the projection sent to clients. Node addresses and
+// certificates are removed because clients only connect to gateways, and the
+// rate limiting whitelists are removed because they identify privileged
+// parties.
+var clientProfile = Profile{
+	Name: ClientProfile,
+	Keep: []string{"SchemaVersion", "Timestamp", "Gateways", "Nodes.ID",
+		"Nodes.Ed25519", "Nodes.Status", "Registration", "Notification",
+		"UDB", "E2E", "CMIX", "AddressSpace", "ClientVersion", "RateLimits"},
+}
+
+// gatewayProfile is the projection sent to gateways, which need the full NDF
+// to connect to their node and to enforce rate limits.
+var gatewayProfile = Profile{
+	Name: GatewayProfile,
+	Keep: []string{AllFields},
+}
+
+// publicProfile is the projection published to network explorers. It contains
+// only what is needed to show the shape of the network.
+var publicProfile = Profile{
+	Name: PublicProfile,
+	Keep: []string{"SchemaVersion", "Timestamp", "Gateways", "Nodes.ID",
+		"Nodes.Status", "E2E", "CMIX", "AddressSpace", "ClientVersion"},
+	Redact: []string{"Gateways.TlsCertificate"},
+}
+
+// profiles maps the names of the built-in profiles to the profiles.
+var profiles = map[string]Profile{
+	clientProfile.Name:  clientProfile,
+	gatewayProfile.Name: gatewayProfile,
+	publicProfile.Name:  publicProfile,
+}
+
+// GetProfile returns a copy of the built-in profile with the given name, such
+// as ClientProfile. Modifying the copy does not change the built-in profile.
+func GetProfile(name string) (Profile, error) {
+	p, exists := profiles[name]
+	if !exists {
+		return Profile{}, errors.Errorf(unknownProfileErr, name)
+	}
+	return p.copy(), nil
+}
+
+// copy returns a copy of the profile that does not share its pattern lists.
+func (p Profile) copy() Profile {
+	p.Keep = append([]string(nil), p.Keep...)
+	p.Redact = append([]string(nil), p.Redact...)
+	return p
+}
+
+// Project returns a copy of the NDF containing only the fields kept by the
+// profile. All other fields are set to their zero value. An error is returned
+// if the profile is invalid (see Profile.Validate), since a misspelled pattern
+// would otherwise silently remove or keep the wrong fields.
+func (ndf *NetworkDefinition) Project(p Profile) (*NetworkDefinition, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	newNdf := ndf.DeepCopy()
+	p.project("", reflect.ValueOf(newNdf).Elem())
+	return newNdf, nil
+}
+
+// Validate returns an error if any pattern in the profile does not name a
+// field of the NDF.
+func (p Profile) Validate() error {
+	ndfType := reflect.TypeOf(NetworkDefinition{})
+	for _, pattern := range append(append([]string{}, p.Keep...), p.Redact...) {
+		if pattern != AllFields && !fieldExists(ndfType, pattern) {
+			return errors.Errorf(profilePatternErr, p.Name, pattern)
+		}
+	}
+	return nil
+}
+
+// project zeroes every part of the value at the path that the profile does
+// not keep.
+func (p Profile) project(path string, v reflect.Value) {
+	if path != "" {
+		if matchAny(p.Redact, path) ||
+			!(matchAny(p.Keep, path) || containsAny(p.Keep, path)) {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		if matchAny(p.Keep, path) && !containsAny(p.Redact, path) {
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.IsExported() {
+				p.project(joinPath(path, field.Name), v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			p.project(path, v.Index(i))
+		}
+	}
+}
+
+// matchAny returns true if any pattern matches the path or one of the fields
+// containing it.
+func matchAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if pattern == AllFields || pattern == path ||
+			strings.HasPrefix(path, pattern+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny returns true if any pattern names a field contained in the path.
+func containsAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldExists returns true if the pattern names a field of the type.
+func fieldExists(t reflect.Type, pattern string) bool {
+	for _, name := range strings.Split(pattern, ".") {
+		for t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || t == timeType {
+			return false
+		}
+		field, exists := t.FieldByName(name)
+		if !exists || !field.IsExported() {
+			return false
+		}
+		t = field.Type
+	}
+	return true
+}
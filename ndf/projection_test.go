@@ -0,0 +1,196 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// secretFields are fields that must never be sent to clients or published.
+var secretFields = []string{
+	"Nodes.Address",
+	"Nodes.TlsCertificate",
+	"WhitelistedIds",
+	"WhitelistedIpAddresses",
+}
+
+// indexPattern matches the slice indices in a path from NetworkDefinition.Walk.
+var indexPattern = regexp.MustCompile(`\[\d+]`)
+
+// Tests that no secret field leaks into the client or public projections and
+// that every non-zero field in a projection is kept by its profile.
+func TestNetworkDefinition_Project_NoSecrets(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	for _, name := range []string{ClientProfile, PublicProfile} {
+		p, _ := GetProfile(name)
+		projected, err := netDef.Project(p)
+		if err != nil {
+			t.Fatalf("Failed to project %s: %+v", name, err)
+		}
+		_ = projected.Walk(func(path string, v reflect.Value) error {
+			if v.IsZero() || (v.Kind() == reflect.Slice && v.Len() == 0) {
+				return nil
+			}
+
+			fieldPath := indexPattern.ReplaceAllString(path, "")
+			if matchAny(secretFields, fieldPath) {
+				t.Errorf("Profile %s leaks %s.", p.Name, path)
+			}
+			if !matchAny(p.Keep, fieldPath) || matchAny(p.Redact, fieldPath) {
+				t.Errorf("Profile %s contains %s, which it does not keep.",
+					p.Name, path)
+			}
+			return nil
+		})
+	}
+}
+
+// Tests that the client projection keeps the fields clients need, including
+// the ones dropped by StripNdf, and does not modify the original.
+func TestNetworkDefinition_Project_Client(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	p, _ := GetProfile(ClientProfile)
+	projected, err := netDef.Project(p)
+	if err != nil {
+		t.Fatalf("Failed to project: %+v", err)
+	}
+
+	if !fieldsEqual(projected.Gateways, netDef.Gateways) ||
+		projected.Registration != netDef.Registration ||
+		projected.ClientVersion != netDef.ClientVersion ||
+		projected.RateLimits != netDef.RateLimits ||
+		!fieldsEqual(projected.UDB, netDef.UDB) {
+		t.Errorf("Client projection is missing fields: %+v", projected)
+	}
+
+	for i, node := range projected.Nodes {
+		expected := Node{ID: netDef.Nodes[i].ID,
+			Ed25519: netDef.Nodes[i].Ed25519, Status: netDef.Nodes[i].Status}
+		if !fieldsEqual(node, expected) {
+			t.Errorf("Unexpected node %d.\nexpected: %+v\nreceived: %+v",
+				i, expected, node)
+		}
+	}
+
+	if !netDef.Equal(newFullTestNdf(t)) {
+		t.Errorf("Project modified the original NDF.")
+	}
+}
+
+// Tests that the gateway projection is the full NDF.
+func TestNetworkDefinition_Project_Gateway(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	p, _ := GetProfile(GatewayProfile)
+	projected, err := netDef.Project(p)
+	if err != nil {
+		t.Fatalf("Failed to project: %+v", err)
+	}
+	if !netDef.Equal(projected) {
+		t.Errorf("Gateway projection does not match the full NDF.")
+	}
+}
+
+// Tests that a field not named by a profile is excluded, so that new fields
+// do not leak into existing profiles.
+func TestNetworkDefinition_Project_DefaultExcluded(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	projected, err := netDef.Project(
+		Profile{Name: "test", Keep: []string{"Timestamp"}})
+	if err != nil {
+		t.Fatalf("Failed to project: %+v", err)
+	}
+
+	expected := &NetworkDefinition{Timestamp: netDef.Timestamp}
+	if !projected.Equal(expected) {
+		t.Errorf("Unexpected projection.\nexpected: %+v\nreceived: %+v",
+			expected, projected)
+	}
+}
+
+// Tests that Redact patterns take precedence over Keep patterns.
+func TestNetworkDefinition_Project_Redact(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	projected, err := netDef.Project(Profile{Name: "test",
+		Keep: []string{AllFields}, Redact: []string{"UDB.Cert", "Gateways"}})
+	if err != nil {
+		t.Fatalf("Failed to project: %+v", err)
+	}
+
+	if projected.UDB.Cert != "" || projected.Gateways != nil {
+		t.Errorf("Redacted fields were kept: %+v", projected)
+	}
+	if projected.UDB.Address != netDef.UDB.Address {
+		t.Errorf("Field next to redacted field was removed.")
+	}
+}
+
+// Tests that the built-in profiles are valid and can be looked up by name.
+func TestGetProfile(t *testing.T) {
+	for _, name := range []string{ClientProfile, GatewayProfile, PublicProfile} {
+		p, err := GetProfile(name)
+		if err != nil {
+			t.Fatalf("Failed to get profile %s: %+v", name, err)
+		}
+		if err = p.Validate(); err != nil {
+			t.Errorf("Profile %s is invalid: %+v", name, err)
+		}
+	}
+
+	if _, err := GetProfile("unknown"); err == nil {
+		t.Errorf("GetProfile did not return an error for an unknown profile.")
+	}
+}
+
+// Tests that modifying a profile returned by GetProfile does not change the
+// built-in profile.
+func TestGetProfile_Copy(t *testing.T) {
+	p, _ := GetProfile(ClientProfile)
+	p.Keep[0] = AllFields
+	p.Keep = append(p.Keep, "WhitelistedIds")
+	p.Redact = append(p.Redact, "Gateways")
+
+	received, _ := GetProfile(ClientProfile)
+	if !reflect.DeepEqual(received, clientProfile) ||
+		received.Keep[0] == AllFields || len(received.Redact) != 0 {
+		t.Errorf("Modifying the returned profile changed the built-in "+
+			"profile: %+v", received)
+	}
+}
+
+// Error path: Tests that Profile.Validate rejects patterns that do not name a
+// field.
+func TestProfile_Validate_Error(t *testing.T) {
+	for _, pattern := range []string{
+		"Node", "Nodes.Addr", "Timestamp.wall", "Nodes.ID.x", ""} {
+		p := Profile{Name: "test", Keep: []string{pattern}}
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate did not return an error for %q.", pattern)
+		}
+	}
+}
+
+// Error path: Tests that NetworkDefinition.Project rejects an invalid profile
+// instead of returning a projection.
+func TestNetworkDefinition_Project_InvalidProfile(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	for _, p := range []Profile{
+		{Name: "test", Keep: []string{"Nodes.Addr"}},
+		{Name: "test", Keep: []string{AllFields}, Redact: []string{"UDB.Certs"}},
+	} {
+		projected, err := netDef.Project(p)
+		if err == nil || !strings.Contains(err.Error(), "does not name") {
+			t.Errorf("Unexpected error for %+v: %+v", p, err)
+		}
+		if projected != nil {
+			t.Errorf("Projection returned for invalid profile %+v.", p)
+		}
+	}
+}
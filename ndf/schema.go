@@ -0,0 +1,194 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// schema.go contains the schema versioning of the NDF JSON format and the
+// migrations used to upgrade older documents on Unmarshal and to produce
+// down-level documents for legacy clients.
+//
+// To change the JSON layout, increment CurrentSchemaVersion and add a
+// migration from the previous version to the migrations map.
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// CurrentSchemaVersion is the schema version of the NetworkDefinition struct.
+// The history of the schema is:
+//
+//	1: The layout of the NetworkDefinition struct when SchemaVersion was
+//	   introduced. Unversioned documents have the same layout.
+const CurrentSchemaVersion = 1
+
+// schemaVersionKey is the JSON key of the schema version.
+const schemaVersionKey = "SchemaVersion"
+
+// Error messages.
+const (
+	schemaDocumentErr   = "failed to parse NDF JSON document"
+	missingMigrationErr = "no migration from NDF schema version %d"
+	migrateUpErr        = "failed to migrate NDF from schema version %d to %d"
+	migrateDownErr      = "failed to migrate NDF from schema version %d to %d"
+	targetVersionErr    = "cannot produce NDF schema version %d; current version is %d"
+	futureVersionWarn   = "NDF has schema version %d, which is newer than the " +
+		"supported version %d; unknown fields are ignored"
+)
+
+// migration upgrades a JSON document from one schema version to the next and
+// downgrades it back.
+type migration struct {
+	up   func(doc jsonDoc) error
+	down func(doc jsonDoc) error
+}
+
+// migrations maps each schema version to the migration that upgrades it to the
+// next version. There must be a migration for every version from 1 up to
+// CurrentSchemaVersion.
+var migrations = map[uint32]migration{}
+
+// schema is a schema version of the NetworkDefinition struct and the
+// migrations that lead up to it.
+type schema struct {
+	current    uint32
+	migrations map[uint32]migration
+}
+
+// ndfSchema is the schema used by Unmarshal and MarshalVersion.
+var ndfSchema = schema{current: CurrentSchemaVersion, migrations: migrations}
+
+// unmarshal parses the JSON NDF, upgrading it to the current schema version if
+// it is older. Unversioned documents are decoded as they are and keep a
+// SchemaVersion of 0.
+func (s schema) unmarshal(data []byte) (*NetworkDefinition, error) {
+	var header struct{ SchemaVersion uint32 }
+	if err := json.Unmarshal(data, &header); err != nil {
+		// Fall back to the struct so that the error describes the problem
+		ndf := &NetworkDefinition{}
+		return ndf, json.Unmarshal(data, ndf)
+	}
+
+	if header.SchemaVersion == 0 || header.SchemaVersion >= s.current {
+		ndf := &NetworkDefinition{}
+		err := json.Unmarshal(data, ndf)
+		if header.SchemaVersion > s.current {
+			jww.WARN.Printf(futureVersionWarn, header.SchemaVersion, s.current)
+			ndf.SchemaVersion = s.current
+		}
+		return ndf, err
+	}
+
+	var doc jsonDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, schemaDocumentErr)
+	}
+
+	for v := header.SchemaVersion; v < s.current; v++ {
+		m, exists := s.migrations[v]
+		if !exists {
+			return nil, errors.Errorf(missingMigrationErr, v)
+		}
+		if err := m.up(doc); err != nil {
+			return nil, errors.Wrapf(err, migrateUpErr, v, v+1)
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, schemaDocumentErr)
+	}
+
+	ndf := &NetworkDefinition{}
+	err = json.Unmarshal(data, ndf)
+	ndf.SchemaVersion = s.current
+	return ndf, err
+}
+
+// MarshalVersion returns the JSON encoding of the NDF in an older schema
+// version for legacy clients. Fields that do not exist in that version are
+// removed. Version 0 produces an unversioned document.
+func (ndf *NetworkDefinition) MarshalVersion(version uint32) ([]byte, error) {
+	return ndfSchema.marshalVersion(ndf, version)
+}
+
+// marshalVersion returns the JSON encoding of the NDF in the given version of
+// the schema.
+func (s schema) marshalVersion(
+	ndf *NetworkDefinition, version uint32) ([]byte, error) {
+	if version > s.current {
+		return nil, errors.Errorf(targetVersionErr, version, s.current)
+	}
+
+	data, err := ndf.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var doc jsonDoc
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, schemaDocumentErr)
+	}
+
+	// Unversioned documents have the layout of version 1
+	for v := s.current; v > version && v > 1; v-- {
+		m, exists := s.migrations[v-1]
+		if !exists {
+			return nil, errors.Errorf(missingMigrationErr, v-1)
+		}
+		if err = m.down(doc); err != nil {
+			return nil, errors.Wrapf(err, migrateDownErr, v, v-1)
+		}
+	}
+
+	doc.delete(schemaVersionKey)
+	if version > 0 {
+		versionJSON, _ := json.Marshal(version)
+		doc.set(schemaVersionKey, versionJSON)
+	}
+
+	return json.Marshal(doc)
+}
+
+// jsonDoc is a JSON object used to migrate documents between schema versions.
+// Keys are matched case-insensitively, like encoding/json does when decoding
+// into a struct.
+type jsonDoc map[string]json.RawMessage
+
+// key returns the key in the document matching the name or the name itself if
+// there is no match.
+func (doc jsonDoc) key(name string) string {
+	if _, exists := doc[name]; exists {
+		return name
+	}
+	for k := range doc {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+// get returns the value of the named field and true if it exists.
+func (doc jsonDoc) get(name string) (json.RawMessage, bool) {
+	v, exists := doc[doc.key(name)]
+	return v, exists
+}
+
+// set sets the value of the named field, replacing an existing field with a
+// differently cased name.
+func (doc jsonDoc) set(name string, v json.RawMessage) {
+	doc[doc.key(name)] = v
+}
+
+// delete removes the named field.
+func (doc jsonDoc) delete(name string) {
+	delete(doc, doc.key(name))
+}
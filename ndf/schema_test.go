@@ -0,0 +1,286 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// testSchemaV2 is a synthetic schema version 2 of the NetworkDefinition
+// struct. In version 1, the ClientVersion field was named ClientVer.
+var testSchemaV2 = schema{
+	current: 2,
+	migrations: map[uint32]migration{
+		1: {
+			up: func(doc jsonDoc) error {
+				return doc.rename("ClientVer", "ClientVersion")
+			},
+			down: func(doc jsonDoc) error {
+				return doc.rename("ClientVersion", "ClientVer")
+			},
+		},
+	},
+}
+
+// rename moves the value of the field from one name to another.
+func (doc jsonDoc) rename(from, to string) error {
+	v, exists := doc.get(from)
+	if !exists {
+		return errors.Errorf("missing field %s", from)
+	}
+	doc.delete(from)
+	doc.set(to, v)
+	return nil
+}
+
+// checkMigrations reports every schema version from 1 up to the current
+// version of the schema that is missing a migration.
+func checkMigrations(t *testing.T, s schema) {
+	for v := uint32(1); v < s.current; v++ {
+		m, exists := s.migrations[v]
+		if !exists || m.up == nil || m.down == nil {
+			t.Errorf("Missing migration from schema version %d.", v)
+		}
+	}
+}
+
+// Tests that the schema used by the package is the current version and that
+// it and the synthetic test schema have a migration for every version.
+func TestMigrations(t *testing.T) {
+	if ndfSchema.current != CurrentSchemaVersion {
+		t.Errorf("Unexpected package schema version.\nexpected: %d"+
+			"\nreceived: %d", CurrentSchemaVersion, ndfSchema.current)
+	}
+	checkMigrations(t, ndfSchema)
+	checkMigrations(t, testSchemaV2)
+}
+
+// Tests that a version 1 document is upgraded by the migration of the
+// synthetic version 2 schema when it is unmarshalled.
+func TestSchema_unmarshal_Migration(t *testing.T) {
+	netDef, err := testSchemaV2.unmarshal(
+		[]byte(`{"SchemaVersion": 1, "ClientVer": "1.2.3"}`))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %+v", err)
+	}
+
+	if netDef.ClientVersion != "1.2.3" || netDef.SchemaVersion != 2 {
+		t.Errorf("Unexpected NDF: %+v", netDef)
+	}
+
+	// Version 2 and unversioned documents are not migrated
+	for _, data := range []string{
+		`{"SchemaVersion": 2, "ClientVersion": "1.2.3"}`,
+		`{"ClientVersion": "1.2.3"}`,
+	} {
+		netDef, err = testSchemaV2.unmarshal([]byte(data))
+		if err != nil {
+			t.Fatalf("Failed to unmarshal %s: %+v", data, err)
+		}
+		if netDef.ClientVersion != "1.2.3" {
+			t.Errorf("Unexpected NDF for %s: %+v", data, netDef)
+		}
+	}
+}
+
+// Tests that an NDF is downgraded by the migration of the synthetic version 2
+// schema when it is marshalled in version 1 or unversioned, and is not when
+// marshalled in version 2.
+func TestSchema_marshalVersion_Migration(t *testing.T) {
+	netDef := &NetworkDefinition{SchemaVersion: 2, ClientVersion: "1.2.3"}
+
+	tests := []struct {
+		version       uint32
+		field, absent string
+	}{
+		{0, "ClientVer", "ClientVersion"},
+		{1, "ClientVer", "ClientVersion"},
+		{2, "ClientVersion", "ClientVer"},
+	}
+
+	for _, tt := range tests {
+		data, err := testSchemaV2.marshalVersion(netDef, tt.version)
+		if err != nil {
+			t.Fatalf("Failed to marshal version %d: %+v", tt.version, err)
+		}
+
+		var doc map[string]json.RawMessage
+		if err = json.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("Failed to parse version %d: %+v", tt.version, err)
+		}
+		if string(doc[tt.field]) != `"1.2.3"` {
+			t.Errorf("Version %d does not have %s: %s",
+				tt.version, tt.field, data)
+		}
+		if _, exists := doc[tt.absent]; exists {
+			t.Errorf("Version %d has %s: %s", tt.version, tt.absent, data)
+		}
+
+		var header struct{ SchemaVersion uint32 }
+		_ = json.Unmarshal(data, &header)
+		if header.SchemaVersion != tt.version {
+			t.Errorf("Unexpected schema version.\nexpected: %d\nreceived: %d",
+				tt.version, header.SchemaVersion)
+		}
+
+		// Versions 1 and 2 round trip through the schema
+		if tt.version > 0 {
+			decoded, err := testSchemaV2.unmarshal(data)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal version %d: %+v", tt.version, err)
+			}
+			if !netDef.Equal(decoded) {
+				t.Errorf("Decoded version %d does not match original."+
+					"\nexpected: %+v\nreceived: %+v", tt.version, netDef, decoded)
+			}
+		}
+	}
+}
+
+// Error path: Tests that a schema missing a migration cannot upgrade or
+// downgrade documents.
+func TestSchema_MissingMigration(t *testing.T) {
+	s := schema{current: 2, migrations: map[uint32]migration{}}
+
+	_, err := s.unmarshal([]byte(`{"SchemaVersion": 1}`))
+	if err == nil || !strings.Contains(err.Error(), "no migration") {
+		t.Errorf("Unexpected error for unmarshal: %+v", err)
+	}
+
+	_, err = s.marshalVersion(&NetworkDefinition{}, 1)
+	if err == nil || !strings.Contains(err.Error(), "no migration") {
+		t.Errorf("Unexpected error for marshalVersion: %+v", err)
+	}
+}
+
+// Tests that Unmarshal decodes the unversioned example NDF without changing
+// any fields and that it is marshalled without a schema version.
+func TestUnmarshal_Unversioned(t *testing.T) {
+	if strings.Contains(ExampleNDF, schemaVersionKey) {
+		t.Fatalf("ExampleNDF must be unversioned.")
+	}
+
+	netDef, err := Unmarshal([]byte(ExampleNDF))
+	if err != nil {
+		t.Fatalf("Unmarshal returned an error: %+v", err)
+	}
+
+	if netDef.SchemaVersion != 0 {
+		t.Errorf("Unexpected schema version.\nexpected: %d\nreceived: %d",
+			0, netDef.SchemaVersion)
+	}
+
+	if netDef.Registration.ClientRegistrationAddress != "" {
+		t.Errorf("Client registration address was set: %q",
+			netDef.Registration.ClientRegistrationAddress)
+	}
+
+	data, err := netDef.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %+v", err)
+	}
+	if strings.Contains(string(data), schemaVersionKey) {
+		t.Errorf("Unversioned NDF marshalled with a schema version: %s", data)
+	}
+}
+
+// Tests that Unmarshal keeps the schema version of a versioned document and
+// that Marshal writes it back.
+func TestUnmarshal_Versioned(t *testing.T) {
+	netDef, err := Unmarshal([]byte(`{"SchemaVersion": 1, ` +
+		`"Registration": {"Address": "reg.xx.network:11420"}}`))
+	if err != nil {
+		t.Fatalf("Unmarshal returned an error: %+v", err)
+	}
+	if netDef.SchemaVersion != 1 ||
+		netDef.Registration.ClientRegistrationAddress != "" {
+		t.Errorf("Unexpected NDF: %+v", netDef)
+	}
+
+	data, err := netDef.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %+v", err)
+	}
+
+	var header struct{ SchemaVersion uint32 }
+	if err = json.Unmarshal(data, &header); err != nil {
+		t.Fatalf("Failed to parse JSON: %+v", err)
+	}
+	if header.SchemaVersion != 1 {
+		t.Errorf("Unexpected schema version.\nexpected: %d\nreceived: %d",
+			1, header.SchemaVersion)
+	}
+}
+
+// Tests that a document with a newer schema version is decoded and that its
+// unknown fields are ignored.
+func TestUnmarshal_FutureVersion(t *testing.T) {
+	netDef, err := Unmarshal([]byte(
+		`{"SchemaVersion": 99, "ClientVersion": "1.2.3", "NewField": 5}`))
+	if err != nil {
+		t.Fatalf("Unmarshal returned an error: %+v", err)
+	}
+
+	if netDef.ClientVersion != "1.2.3" ||
+		netDef.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("Unexpected NDF: %+v", netDef)
+	}
+}
+
+// Tests that NetworkDefinition.MarshalVersion produces an unversioned document
+// for version 0 and a versioned one otherwise, and that the result decodes to
+// the original NDF with the requested version.
+func TestNetworkDefinition_MarshalVersion(t *testing.T) {
+	netDef := newFullTestNdf(t)
+
+	for v := uint32(0); v <= CurrentSchemaVersion; v++ {
+		data, err := netDef.MarshalVersion(v)
+		if err != nil {
+			t.Fatalf("Failed to marshal version %d: %+v", v, err)
+		}
+
+		if v == 0 && strings.Contains(string(data), schemaVersionKey) {
+			t.Errorf("Version 0 contains %s: %s", schemaVersionKey, data)
+		}
+
+		var header struct{ SchemaVersion uint32 }
+		if err = json.Unmarshal(data, &header); err != nil {
+			t.Fatalf("Failed to parse version %d: %+v", v, err)
+		}
+		if header.SchemaVersion != v {
+			t.Errorf("Unexpected schema version.\nexpected: %d\nreceived: %d",
+				v, header.SchemaVersion)
+		}
+
+		decoded, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal version %d: %+v", v, err)
+		}
+		expected := netDef.DeepCopy()
+		expected.SchemaVersion = v
+		if !bytes.Equal(expected.SerializeCanonical(),
+			decoded.SerializeCanonical()) {
+			t.Errorf("Decoded version %d does not match original."+
+				"\nexpected: %+v\nreceived: %+v", v, expected, decoded)
+		}
+	}
+}
+
+// Error path: Tests that NetworkDefinition.MarshalVersion rejects versions
+// newer than the current version.
+func TestNetworkDefinition_MarshalVersion_FutureVersion(t *testing.T) {
+	_, err := newFullTestNdf(t).MarshalVersion(CurrentSchemaVersion + 1)
+	if err == nil {
+		t.Errorf("MarshalVersion did not return an error for a future version.")
+	}
+}
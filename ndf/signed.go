@@ -0,0 +1,304 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// signed.go contains the SignedNetworkDefinition envelope and the functions
+// used to sign and verify it.
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// SignatureScheme describes the key type used to sign a
+// SignedNetworkDefinition.
+type SignatureScheme uint8
+
+const (
+	// RSA signatures use RSASSA-PSS with SHA-256.
+	RSA SignatureScheme = iota + 1
+
+	// Ed25519 signatures are pure Ed25519 over the signing payload.
+	Ed25519
+)
+
+// signingDomain is prepended to every signing payload so that an NDF signature
+// can never be mistaken for a signature over some other message.
+const signingDomain = "xx network NDF signature"
+
+// Error messages.
+const (
+	nilSignerErr        = "cannot sign NDF: signer is nil"
+	nilNdfErr           = "cannot sign NDF: NDF is nil"
+	nilVerifierErr      = "cannot verify NDF: verifier is nil"
+	nilSignedErr        = "cannot verify NDF: signed NDF is nil"
+	signNdfErr          = "failed to sign NDF"
+	schemeMismatchErr   = "signature scheme %s does not match verifier scheme %s"
+	invalidSignatureErr = "NDF signature is invalid"
+	unmarshalSignedErr  = "failed to unmarshal signed NDF"
+	decodeSignedErr     = "failed to decode signed NDF"
+	decodePemErr        = "failed to decode PEM block"
+	parseCertErr        = "failed to parse x509 certificate"
+	notRsaKeyErr        = "certificate public key is %T, expected *rsa.PublicKey"
+	ed25519KeySizeErr   = "Ed25519 public key must be %d bytes; received %d"
+	ed25519PrivSizeErr  = "Ed25519 private key must be %d bytes; received %d"
+	nilRsaKeyErr        = "RSA key is nil"
+)
+
+// String returns the human-readable name of the SignatureScheme. This function
+// adheres to the fmt.Stringer interface.
+func (s SignatureScheme) String() string {
+	switch s {
+	case RSA:
+		return "RSA"
+	case Ed25519:
+		return "Ed25519"
+	default:
+		return "UNKNOWN SIGNATURE SCHEME: " + strconv.Itoa(int(s))
+	}
+}
+
+// SignedNetworkDefinition is an envelope holding the canonical encoding of a
+// NetworkDefinition (see SerializeCanonical) and a signature over it. The NDF
+// is kept in its encoded form so that the verified bytes are exactly the bytes
+// that were signed, and because the encoding is canonical, every signer
+// produces the same bytes for the same NDF.
+type SignedNetworkDefinition struct {
+	Ndf       []byte
+	Signature []byte
+	Scheme    SignatureScheme
+}
+
+// Signer signs NDF signing payloads.
+type Signer interface {
+	// Sign returns a signature over the message.
+	Sign(message []byte) ([]byte, error)
+
+	// Scheme returns the SignatureScheme of the signer.
+	Scheme() SignatureScheme
+}
+
+// Verifier verifies signatures over NDF signing payloads.
+type Verifier interface {
+	// Verify returns an error if the signature over the message is invalid.
+	Verify(message, signature []byte) error
+
+	// Scheme returns the SignatureScheme of the verifier.
+	Scheme() SignatureScheme
+}
+
+// Sign encodes the NetworkDefinition with SerializeCanonical and signs it with
+// the given Signer.
+func Sign(ndf *NetworkDefinition, s Signer) (*SignedNetworkDefinition, error) {
+	if s == nil {
+		return nil, errors.New(nilSignerErr)
+	}
+	if ndf == nil {
+		return nil, errors.New(nilNdfErr)
+	}
+
+	data := ndf.SerializeCanonical()
+	sig, err := s.Sign(signingPayload(s.Scheme(), data))
+	if err != nil {
+		return nil, errors.Wrap(err, signNdfErr)
+	}
+
+	return &SignedNetworkDefinition{
+		Ndf:       data,
+		Signature: sig,
+		Scheme:    s.Scheme(),
+	}, nil
+}
+
+// Verify checks the signature on the SignedNetworkDefinition with the given
+// Verifier and, if it is valid, decodes the signed bytes with
+// DeserializeCanonical and returns the NetworkDefinition.
+func Verify(signed *SignedNetworkDefinition, v Verifier) (
+	*NetworkDefinition, error) {
+	if v == nil {
+		return nil, errors.New(nilVerifierErr)
+	}
+	if signed == nil {
+		return nil, errors.New(nilSignedErr)
+	}
+
+	if signed.Scheme != v.Scheme() {
+		return nil, errors.Errorf(schemeMismatchErr, signed.Scheme, v.Scheme())
+	}
+
+	err := v.Verify(signingPayload(signed.Scheme, signed.Ndf), signed.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, invalidSignatureErr)
+	}
+
+	ndf, err := DeserializeCanonical(signed.Ndf)
+	if err != nil {
+		return nil, errors.Wrap(err, decodeSignedErr)
+	}
+	return ndf, nil
+}
+
+// Marshal returns the JSON encoding of the SignedNetworkDefinition.
+func (s *SignedNetworkDefinition) Marshal() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// UnmarshalSigned parses the JSON encoded data and returns the resulting
+// SignedNetworkDefinition. The signature is not checked.
+func UnmarshalSigned(data []byte) (*SignedNetworkDefinition, error) {
+	signed := &SignedNetworkDefinition{}
+	if err := json.Unmarshal(data, signed); err != nil {
+		return nil, errors.Wrap(err, unmarshalSignedErr)
+	}
+
+	return signed, nil
+}
+
+// signingPayload frames the encoded NDF for signing. The payload is the
+// signing domain, the scheme, and the big-endian length of the data followed
+// by the data itself.
+func signingPayload(scheme SignatureScheme, data []byte) []byte {
+	payload := make([]byte, 0, len(signingDomain)+1+8+len(data))
+	payload = append(payload, signingDomain...)
+	payload = append(payload, byte(scheme))
+	payload = binary.BigEndian.AppendUint64(payload, uint64(len(data)))
+	return append(payload, data...)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// RSA                                                                        //
+////////////////////////////////////////////////////////////////////////////////
+
+// rsaSigner signs with an RSA private key using RSASSA-PSS and SHA-256.
+type rsaSigner struct {
+	key *rsa.PrivateKey
+}
+
+// NewRSASigner returns a Signer that uses the RSA private key.
+func NewRSASigner(key *rsa.PrivateKey) Signer {
+	return &rsaSigner{key}
+}
+
+// Sign returns the RSASSA-PSS signature of the SHA-256 hash of the message.
+func (r *rsaSigner) Sign(message []byte) ([]byte, error) {
+	if r.key == nil {
+		return nil, errors.New(nilRsaKeyErr)
+	}
+	digest := sha256.Sum256(message)
+	return rsa.SignPSS(rand.Reader, r.key, crypto.SHA256, digest[:],
+		&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+}
+
+// Scheme returns RSA.
+func (r *rsaSigner) Scheme() SignatureScheme { return RSA }
+
+// rsaVerifier verifies RSASSA-PSS signatures with an RSA public key.
+type rsaVerifier struct {
+	key *rsa.PublicKey
+}
+
+// NewRSAVerifier returns a Verifier that uses the RSA public key.
+func NewRSAVerifier(key *rsa.PublicKey) Verifier {
+	return &rsaVerifier{key}
+}
+
+// NewRSAVerifierFromCert returns a Verifier that uses the RSA public key in
+// the PEM encoded x509 certificate.
+func NewRSAVerifierFromCert(certPEM string) (Verifier, error) {
+	cert, err := ParseCertificate(certPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf(notRsaKeyErr, cert.PublicKey)
+	}
+
+	return NewRSAVerifier(key), nil
+}
+
+// Verify checks the RSASSA-PSS signature of the SHA-256 hash of the message.
+func (r *rsaVerifier) Verify(message, signature []byte) error {
+	if r.key == nil {
+		return errors.New(nilRsaKeyErr)
+	}
+	digest := sha256.Sum256(message)
+	return rsa.VerifyPSS(r.key, crypto.SHA256, digest[:], signature,
+		&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+}
+
+// Scheme returns RSA.
+func (r *rsaVerifier) Scheme() SignatureScheme { return RSA }
+
+// RegistrationVerifier returns a Verifier for the permissioning server's RSA
+// key found in Registration.TlsCertificate.
+func (ndf *NetworkDefinition) RegistrationVerifier() (Verifier, error) {
+	return NewRSAVerifierFromCert(ndf.Registration.TlsCertificate)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Ed25519                                                                    //
+////////////////////////////////////////////////////////////////////////////////
+
+// ed25519Signer signs with an Ed25519 private key.
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewEd25519Signer returns a Signer that uses the Ed25519 private key.
+func NewEd25519Signer(key ed25519.PrivateKey) Signer {
+	return &ed25519Signer{key}
+}
+
+// Sign returns the Ed25519 signature of the message.
+func (e *ed25519Signer) Sign(message []byte) ([]byte, error) {
+	if len(e.key) != ed25519.PrivateKeySize {
+		return nil, errors.Errorf(
+			ed25519PrivSizeErr, ed25519.PrivateKeySize, len(e.key))
+	}
+	return ed25519.Sign(e.key, message), nil
+}
+
+// Scheme returns Ed25519.
+func (e *ed25519Signer) Scheme() SignatureScheme { return Ed25519 }
+
+// ed25519Verifier verifies Ed25519 signatures.
+type ed25519Verifier struct {
+	key ed25519.PublicKey
+}
+
+// NewEd25519Verifier returns a Verifier that uses the Ed25519 public key. An
+// error is returned if the key is not the correct size.
+func NewEd25519Verifier(key ed25519.PublicKey) (Verifier, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil,
+			errors.Errorf(ed25519KeySizeErr, ed25519.PublicKeySize, len(key))
+	}
+
+	return &ed25519Verifier{key}, nil
+}
+
+// Verify checks the Ed25519 signature of the message.
+func (e *ed25519Verifier) Verify(message, signature []byte) error {
+	if !ed25519.Verify(e.key, message, signature) {
+		return errors.New("ed25519: verification error")
+	}
+	return nil
+}
+
+// Scheme returns Ed25519.
+func (e *ed25519Verifier) Scheme() SignatureScheme { return Ed25519 }
@@ -0,0 +1,324 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Happy path: signs and verifies an NDF with an Ed25519 key.
+func TestSign_Ed25519(t *testing.T) {
+	netDef, err := Unmarshal([]byte(ExampleNDF))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal example NDF: %+v", err)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %+v", err)
+	}
+
+	signed, err := Sign(netDef, NewEd25519Signer(priv))
+	if err != nil {
+		t.Fatalf("Sign returned an error: %+v", err)
+	}
+
+	v, err := NewEd25519Verifier(pub)
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %+v", err)
+	}
+
+	verified, err := Verify(signed, v)
+	if err != nil {
+		t.Fatalf("Verify returned an error: %+v", err)
+	}
+
+	if !netDef.Equal(verified) {
+		t.Errorf("Verified NDF does not match original."+
+			"\nexpected: %+v\nreceived: %+v", netDef, verified)
+	}
+}
+
+// Happy path: signs an NDF with an RSA key and verifies it using the
+// certificate stored in Registration.TlsCertificate.
+func TestSign_RSA(t *testing.T) {
+	netDef, err := Unmarshal([]byte(ExampleNDF))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal example NDF: %+v", err)
+	}
+
+	key, certPEM := newTestRsaCert(
+		t, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	netDef.Registration.TlsCertificate = certPEM
+
+	signed, err := Sign(netDef, NewRSASigner(key))
+	if err != nil {
+		t.Fatalf("Sign returned an error: %+v", err)
+	}
+
+	v, err := netDef.RegistrationVerifier()
+	if err != nil {
+		t.Fatalf("RegistrationVerifier returned an error: %+v", err)
+	}
+
+	verified, err := Verify(signed, v)
+	if err != nil {
+		t.Fatalf("Verify returned an error: %+v", err)
+	}
+
+	if !netDef.Equal(verified) {
+		t.Errorf("Verified NDF does not match original."+
+			"\nexpected: %+v\nreceived: %+v", netDef, verified)
+	}
+}
+
+// Tests that a SignedNetworkDefinition survives a JSON round trip and still
+// verifies.
+func TestSignedNetworkDefinition_Marshal_UnmarshalSigned(t *testing.T) {
+	netDef, err := Unmarshal([]byte(ExampleNDF))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal example NDF: %+v", err)
+	}
+
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signed, err := Sign(netDef, NewEd25519Signer(priv))
+	if err != nil {
+		t.Fatalf("Sign returned an error: %+v", err)
+	}
+
+	data, err := signed.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %+v", err)
+	}
+
+	newSigned, err := UnmarshalSigned(data)
+	if err != nil {
+		t.Fatalf("UnmarshalSigned returned an error: %+v", err)
+	}
+
+	if !reflect.DeepEqual(signed, newSigned) {
+		t.Errorf("Unexpected signed NDF.\nexpected: %+v\nreceived: %+v",
+			signed, newSigned)
+	}
+
+	v, _ := NewEd25519Verifier(pub)
+	if _, err = Verify(newSigned, v); err != nil {
+		t.Errorf("Verify returned an error: %+v", err)
+	}
+}
+
+// Tests that Sign signs the canonical encoding, so that the same NDF decoded
+// from JSON with a different timestamp zone produces the same signature.
+func TestSign_Canonical(t *testing.T) {
+	netDef := newFullTestNdf(t)
+	netDef.Timestamp = time.Now().In(time.FixedZone("EST", -5*3600))
+
+	data, err := netDef.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal NDF: %+v", err)
+	}
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal NDF: %+v", err)
+	}
+
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signed, err := Sign(netDef, NewEd25519Signer(priv))
+	if err != nil {
+		t.Fatalf("Sign returned an error: %+v", err)
+	}
+	decodedSigned, err := Sign(decoded.DeepCopy(), NewEd25519Signer(priv))
+	if err != nil {
+		t.Fatalf("Sign returned an error: %+v", err)
+	}
+
+	if !bytes.Equal(signed.Ndf, netDef.SerializeCanonical()) {
+		t.Errorf("Signed bytes are not the canonical encoding.")
+	}
+	if !bytes.Equal(signed.Ndf, decodedSigned.Ndf) ||
+		!bytes.Equal(signed.Signature, decodedSigned.Signature) {
+		t.Errorf("Signatures of the same NDF differ after a JSON round trip.")
+	}
+}
+
+// Error path: Tests that Verify rejects an NDF that was modified after
+// signing.
+func TestVerify_TamperedNdf(t *testing.T) {
+	netDef, err := Unmarshal([]byte(ExampleNDF))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal example NDF: %+v", err)
+	}
+
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signed, err := Sign(netDef, NewEd25519Signer(priv))
+	if err != nil {
+		t.Fatalf("Sign returned an error: %+v", err)
+	}
+
+	signed.Ndf = []byte(strings.Replace(
+		string(signed.Ndf), "52.25.135.52", "52.25.135.53", 1))
+
+	v, _ := NewEd25519Verifier(pub)
+	_, err = Verify(signed, v)
+	if err == nil || !strings.Contains(err.Error(), invalidSignatureErr) {
+		t.Errorf("Verify did not return the expected error for a tampered "+
+			"NDF.\nexpected: %s\nreceived: %+v", invalidSignatureErr, err)
+	}
+}
+
+// Error path: Tests that Verify rejects a signature made by a different key.
+func TestVerify_WrongKey(t *testing.T) {
+	netDef, err := Unmarshal([]byte(ExampleNDF))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal example NDF: %+v", err)
+	}
+
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	signed, err := Sign(netDef, NewEd25519Signer(priv))
+	if err != nil {
+		t.Fatalf("Sign returned an error: %+v", err)
+	}
+
+	v, _ := NewEd25519Verifier(otherPub)
+	if _, err = Verify(signed, v); err == nil {
+		t.Error("Verify did not return an error for the wrong key.")
+	}
+}
+
+// Error path: Tests that Verify rejects an envelope whose scheme does not
+// match the verifier.
+func TestVerify_SchemeMismatch(t *testing.T) {
+	netDef, err := Unmarshal([]byte(ExampleNDF))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal example NDF: %+v", err)
+	}
+
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signed, err := Sign(netDef, NewEd25519Signer(priv))
+	if err != nil {
+		t.Fatalf("Sign returned an error: %+v", err)
+	}
+
+	key, _ := newTestRsaCert(
+		t, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	_, err = Verify(signed, NewRSAVerifier(&key.PublicKey))
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Verify did not return the expected error for a scheme "+
+			"mismatch: %+v", err)
+	}
+}
+
+// Error path: Tests that Verify returns an error instead of panicking for a nil
+// envelope or verifier, or a verifier without a key.
+func TestVerify_Nil(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	v, _ := NewEd25519Verifier(pub)
+
+	_, err := Verify(nil, v)
+	if err == nil || err.Error() != nilSignedErr {
+		t.Errorf("Unexpected error for a nil envelope.\nexpected: %s"+
+			"\nreceived: %+v", nilSignedErr, err)
+	}
+
+	_, err = Verify(&SignedNetworkDefinition{Scheme: Ed25519}, nil)
+	if err == nil || err.Error() != nilVerifierErr {
+		t.Errorf("Unexpected error for a nil verifier.\nexpected: %s"+
+			"\nreceived: %+v", nilVerifierErr, err)
+	}
+
+	signed := &SignedNetworkDefinition{Scheme: RSA}
+	_, err = Verify(signed, NewRSAVerifier(nil))
+	if err == nil || !strings.Contains(err.Error(), nilRsaKeyErr) {
+		t.Errorf("Unexpected error for a nil RSA key.\nexpected: %s"+
+			"\nreceived: %+v", nilRsaKeyErr, err)
+	}
+}
+
+// Error path: Tests that Sign returns an error instead of panicking for a nil
+// NDF or signer, or a signer with an invalid key.
+func TestSign_Nil(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	_, err := Sign(nil, NewEd25519Signer(priv))
+	if err == nil || err.Error() != nilNdfErr {
+		t.Errorf("Unexpected error for a nil NDF.\nexpected: %s"+
+			"\nreceived: %+v", nilNdfErr, err)
+	}
+
+	_, err = Sign(&NetworkDefinition{}, nil)
+	if err == nil || err.Error() != nilSignerErr {
+		t.Errorf("Unexpected error for a nil signer.\nexpected: %s"+
+			"\nreceived: %+v", nilSignerErr, err)
+	}
+
+	_, err = Sign(&NetworkDefinition{}, NewRSASigner(nil))
+	if err == nil || !strings.Contains(err.Error(), nilRsaKeyErr) {
+		t.Errorf("Unexpected error for a nil RSA key.\nexpected: %s"+
+			"\nreceived: %+v", nilRsaKeyErr, err)
+	}
+
+	_, err = Sign(&NetworkDefinition{}, NewEd25519Signer(priv[:5]))
+	if err == nil || !strings.Contains(err.Error(), "private key") {
+		t.Errorf("Unexpected error for a short Ed25519 key: %+v", err)
+	}
+}
+
+// Error path: Tests that NewRSAVerifierFromCert returns an error for invalid
+// PEM data.
+func TestNewRSAVerifierFromCert_InvalidPem(t *testing.T) {
+	_, err := NewRSAVerifierFromCert("not a certificate")
+	if err == nil || err.Error() != decodePemErr {
+		t.Errorf("Unexpected error.\nexpected: %s\nreceived: %+v",
+			decodePemErr, err)
+	}
+}
+
+// Error path: Tests that NewEd25519Verifier rejects a key of the wrong size.
+func TestNewEd25519Verifier_KeySizeError(t *testing.T) {
+	if _, err := NewEd25519Verifier(make([]byte, 5)); err == nil {
+		t.Error("NewEd25519Verifier did not return an error for an invalid " +
+			"key size.")
+	}
+}
+
+// newTestRsaCert generates an RSA key and a PEM encoded self-signed
+// certificate for it that is valid between the two times.
+func newTestRsaCert(t *testing.T, notBefore, notAfter time.Time) (
+	*rsa.PrivateKey, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %+v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "registration*.cmix.rip"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %+v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return key, string(certPEM)
+}
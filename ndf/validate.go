@@ -0,0 +1,184 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// validate.go contains the semantic validation of a NetworkDefinition.
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"gitlab.com/xx_network/primitives/utils"
+)
+
+// Error messages.
+const (
+	invalidIdErr         = "%s: invalid ID: %+v"
+	wrongIdTypeErr       = "%s: ID has type %s; expected %s"
+	duplicateIdErr       = "%s: duplicate of ID at index %d"
+	invalidAddressErr    = "%s: invalid address %q: %+v"
+	emptyFieldErr        = "%s: must not be empty"
+	invalidPemErr        = "%s: %v"
+	countMismatchErr     = "number of gateways (%d) does not match number of nodes (%d)"
+	invalidBinErr        = "%s: unknown region %d"
+	invalidHexErr        = "%s: %q is not valid hex"
+	invalidGroupErr      = "%s: %+v"
+	addressSpaceOrderErr = "AddressSpace[%d]: timestamp %s is not after previous " +
+		"timestamp %s"
+)
+
+// Validate checks the NetworkDefinition for semantic problems and returns every
+// problem found. It returns nil if the NDF is valid.
+//
+// Node addresses and certificates are only checked when present, so NDFs
+// stripped with StripNdf can be validated.
+func (ndf *NetworkDefinition) Validate() []error {
+	var errs []error
+
+	if len(ndf.Gateways) != len(ndf.Nodes) {
+		errs = append(errs,
+			errors.Errorf(countMismatchErr, len(ndf.Gateways), len(ndf.Nodes)))
+	}
+
+	gatewayIDs := make(map[id.ID]int, len(ndf.Gateways))
+	for i, gw := range ndf.Gateways {
+		field := "Gateways[" + strconv.Itoa(i) + "]"
+		errs = validateID(errs, field+".ID", gw.ID, id.Gateway, i, gatewayIDs)
+		if gw.Address == "" {
+			errs = append(errs, errors.Errorf(emptyFieldErr, field+".Address"))
+		} else {
+			errs = validateAddress(errs, field+".Address", gw.Address)
+		}
+		errs = validateCert(errs, field+".TlsCertificate", gw.TlsCertificate)
+		if _, err := region.GetRegion(gw.Bin.String()); err != nil {
+			errs = append(errs, errors.Errorf(invalidBinErr, field+".Bin", gw.Bin))
+		}
+	}
+
+	nodeIDs := make(map[id.ID]int, len(ndf.Nodes))
+	for i, node := range ndf.Nodes {
+		field := "Nodes[" + strconv.Itoa(i) + "]"
+		errs = validateID(errs, field+".ID", node.ID, id.Node, i, nodeIDs)
+		if node.Address != "" {
+			errs = validateAddress(errs, field+".Address", node.Address)
+		}
+		if node.TlsCertificate != "" {
+			errs = validateCert(errs, field+".TlsCertificate", node.TlsCertificate)
+		}
+	}
+
+	if ndf.Registration.Address == "" {
+		errs = append(errs, errors.Errorf(emptyFieldErr, "Registration.Address"))
+	} else {
+		errs = validateAddress(errs, "Registration.Address", ndf.Registration.Address)
+	}
+	if ndf.Registration.ClientRegistrationAddress != "" {
+		errs = validateAddress(errs, "Registration.ClientRegistrationAddress",
+			ndf.Registration.ClientRegistrationAddress)
+	}
+	errs = validateCert(
+		errs, "Registration.TlsCertificate", ndf.Registration.TlsCertificate)
+
+	if ndf.Notification.Address != "" {
+		errs = validateAddress(errs, "Notification.Address", ndf.Notification.Address)
+	}
+	if ndf.Notification.TlsCertificate != "" {
+		errs = validateCert(
+			errs, "Notification.TlsCertificate", ndf.Notification.TlsCertificate)
+	}
+
+	if ndf.UDB.Address != "" {
+		errs = validateAddress(errs, "UDB.Address", ndf.UDB.Address)
+	}
+	if ndf.UDB.Cert != "" {
+		errs = validateCert(errs, "UDB.Cert", ndf.UDB.Cert)
+	}
+
+	errs = validateGroup(errs, "E2E", ndf.E2E)
+	errs = validateGroup(errs, "CMIX", ndf.CMIX)
+
+	errs = validateAddressSpace(errs, ndf.AddressSpace)
+
+	return errs
+}
+
+// validateID checks that the ID unmarshalls, is of the expected type, and has
+// not been seen before. Seen IDs are added to the seen map.
+func validateID(errs []error, field string, idBytes []byte, t id.Type,
+	index int, seen map[id.ID]int) []error {
+	newID, err := id.Unmarshal(idBytes)
+	if err != nil {
+		return append(errs, errors.Errorf(invalidIdErr, field, err))
+	}
+
+	if newID.GetType() != t {
+		errs = append(errs, errors.Errorf(wrongIdTypeErr, field, newID.GetType(), t))
+	}
+
+	if j, exists := seen[*newID]; exists {
+		errs = append(errs, errors.Errorf(duplicateIdErr, field, j))
+	} else {
+		seen[*newID] = index
+	}
+
+	return errs
+}
+
+// validateAddress checks that the address is a valid public address.
+func validateAddress(errs []error, field, address string) []error {
+	if err := utils.IsValidPublicAddress(address); err != nil {
+		return append(errs, errors.Errorf(invalidAddressErr, field, address, err))
+	}
+	return errs
+}
+
+// validateCert checks that the string is a PEM encoded x509 certificate.
+func validateCert(errs []error, field, certPEM string) []error {
+	if certPEM == "" {
+		return append(errs, errors.Errorf(emptyFieldErr, field))
+	}
+
+	if _, err := ParseCertificate(certPEM); err != nil {
+		return append(errs, errors.Errorf(invalidPemErr, field, err))
+	}
+
+	return errs
+}
+
+// validateGroup checks that the group values are valid hex, that the prime is
+// a safe prime of the small prime (p = 2q+1), and that the generator is in
+// range and generates the subgroup of order q. Primality is only checked by
+// Group.Validate.
+func validateGroup(errs []error, field string, g Group) []error {
+	var values [3]*big.Int
+	for i, v := range []struct{ name, hex string }{
+		{"Prime", g.Prime}, {"SmallPrime", g.SmallPrime}, {"Generator", g.Generator},
+	} {
+		n, err := parseGroupHex(v.name, v.hex)
+		if err != nil {
+			errs = append(errs,
+				errors.Errorf(invalidHexErr, field+"."+v.name, v.hex))
+			continue
+		}
+		values[i] = n
+	}
+
+	if values[0] == nil || values[1] == nil || values[2] == nil {
+		return errs
+	}
+
+	if err := checkGroupStructure(values[0], values[1], values[2]); err != nil {
+		errs = append(errs, errors.Errorf(invalidGroupErr, field, err))
+	}
+
+	return errs
+}
@@ -0,0 +1,129 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"strings"
+	"testing"
+
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+)
+
+// Happy path: a well-formed NDF has no problems.
+func TestNetworkDefinition_Validate(t *testing.T) {
+	netDef := newValidTestNdf(t)
+	if errs := netDef.Validate(); errs != nil {
+		t.Errorf("Validate returned errors for a valid NDF: %v", errs)
+	}
+}
+
+// Tests that a stripped NDF, which has no node addresses or certificates,
+// still validates.
+func TestNetworkDefinition_Validate_Stripped(t *testing.T) {
+	netDef := newValidTestNdf(t).StripNdf()
+	if errs := netDef.Validate(); errs != nil {
+		t.Errorf("Validate returned errors for a stripped NDF: %v", errs)
+	}
+}
+
+// Error path: Tests that Validate reports each kind of problem.
+func TestNetworkDefinition_Validate_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(n *NetworkDefinition)
+		expected string
+	}{
+		{"BadGatewayID", func(n *NetworkDefinition) {
+			n.Gateways[0].ID = []byte{1, 2, 3}
+		}, "Gateways[0].ID: invalid ID"},
+		{"WrongNodeType", func(n *NetworkDefinition) {
+			n.Nodes[1].ID[id.ArrIDLen-1] = byte(id.User)
+		}, "Nodes[1].ID: ID has type user; expected node"},
+		{"DuplicateNode", func(n *NetworkDefinition) {
+			n.Nodes[2].ID = n.Nodes[0].ID
+		}, "Nodes[2].ID: duplicate of ID at index 0"},
+		{"PrivateAddress", func(n *NetworkDefinition) {
+			n.Gateways[1].Address = "192.168.1.1"
+		}, "Gateways[1].Address: invalid address"},
+		{"EmptyGatewayAddress", func(n *NetworkDefinition) {
+			n.Gateways[2].Address = ""
+		}, "Gateways[2].Address: must not be empty"},
+		{"BadCert", func(n *NetworkDefinition) {
+			n.Registration.TlsCertificate = "not a cert"
+		}, "Registration.TlsCertificate: " + decodePemErr},
+		{"CountMismatch", func(n *NetworkDefinition) {
+			n.Nodes = n.Nodes[:2]
+		}, "does not match number of nodes"},
+		{"BadBin", func(n *NetworkDefinition) {
+			n.Gateways[0].Bin = region.Oceania + 1
+		}, "Gateways[0].Bin: unknown region"},
+		{"BadHex", func(n *NetworkDefinition) {
+			n.CMIX.Generator = "zz"
+		}, "CMIX.Generator: \"zz\" is not valid hex"},
+		{"NotSafePrime", func(n *NetworkDefinition) {
+			n.E2E.SmallPrime = "05"
+		}, "E2E: Prime is not equal to 2*SmallPrime+1"},
+		{"GeneratorRange", func(n *NetworkDefinition) {
+			n.E2E.Generator = "01"
+		}, "E2E: Generator must be in the range"},
+		{"GeneratorSubgroup", func(n *NetworkDefinition) {
+			n.E2E = Group{Prime: "17", SmallPrime: "0B", Generator: "05"}
+		}, "E2E: Generator does not generate the subgroup"},
+		{"AddressSpaceOrder", func(n *NetworkDefinition) {
+			n.AddressSpace[2].Timestamp = n.AddressSpace[0].Timestamp
+		}, "AddressSpace[2]: timestamp"},
+		{"AddressSpaceSize", func(n *NetworkDefinition) {
+			n.AddressSpace[1].Size = 65
+		}, "AddressSpace[1]: size 65 is not in the range [1, 64]"},
+	}
+
+	for _, tt := range tests {
+		netDef := newValidTestNdf(t)
+		tt.modify(netDef)
+		errs := netDef.Validate()
+
+		found := false
+		for _, err := range errs {
+			if strings.Contains(err.Error(), tt.expected) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: Validate did not report the expected problem."+
+				"\nexpected: %s\nreceived: %v", tt.name, tt.expected, errs)
+		}
+	}
+}
+
+// Tests that Validate reports every problem instead of stopping at the first.
+func TestNetworkDefinition_Validate_AllErrors(t *testing.T) {
+	netDef := newValidTestNdf(t)
+	netDef.Gateways[0].Address = "10.0.0.1"
+	netDef.Nodes[0].TlsCertificate = "bad"
+	netDef.CMIX.Prime = "not hex"
+
+	if errs := netDef.Validate(); len(errs) != 3 {
+		t.Errorf("Expected 3 errors, received %d: %v", len(errs), errs)
+	}
+}
+
+// newValidTestNdf returns the example NDF with the gateway and node IDs set so
+// that it passes validation.
+func newValidTestNdf(t testing.TB) *NetworkDefinition {
+	netDef := exampleNdf(t)
+	for i := range netDef.Nodes {
+		nodeID := id.NewIdFromUInt(uint64(i+1), id.Node, t)
+		gwID := nodeID.DeepCopy()
+		gwID.SetType(id.Gateway)
+		netDef.Nodes[i].ID = nodeID.Marshal()
+		netDef.Gateways[i].ID = gwID.Marshal()
+	}
+	netDef.UDB.Cert = ""
+	return netDef
+}
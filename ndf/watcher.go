@@ -0,0 +1,336 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+// watcher.go contains the Watcher, which polls a Source for new NDFs and
+// publishes them to subscribers, and the file and HTTP sources.
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/xx_network/primitives/utils"
+)
+
+// maxFetchSize is the maximum size of an NDF fetched over HTTP.
+const maxFetchSize = 64 << 20
+
+// Error messages.
+const (
+	fetchNdfErr         = "failed to fetch NDF"
+	parseFetchedErr     = "failed to parse fetched NDF"
+	timestampRegressErr = "rejecting NDF with timestamp %s older than the " +
+		"current timestamp %s"
+	httpStatusErr   = "unexpected HTTP status fetching NDF: %s"
+	httpTooLargeErr = "NDF fetched over HTTP exceeds %d bytes"
+)
+
+// Source is a location that NDFs are fetched from.
+type Source interface {
+	// Fetch returns the encoded NDF. If the NDF has not changed since the last
+	// call or is not yet available, then it returns nil data and no error.
+	Fetch() ([]byte, error)
+}
+
+// CachingSource is a Source that skips NDFs it has already returned. Accept is
+// called once the data returned by the last Fetch has been parsed and verified.
+// Until then, Fetch must not skip the data so that a corrupt or unverifiable
+// response is fetched again.
+type CachingSource interface {
+	Source
+	Accept()
+}
+
+// Watcher polls a Source for NDFs and publishes every NDF with a newer
+// timestamp to its subscribers. It is safe for concurrent use.
+type Watcher struct {
+	source   Source
+	verifier Verifier
+
+	// The most recently accepted NDF
+	current atomic.Pointer[NetworkDefinition]
+
+	// Serializes polls so that timestamps are compared in order
+	pollMux sync.Mutex
+
+	subscribers map[uint64]chan *NetworkDefinition
+	nextSubID   uint64
+	subMux      sync.Mutex
+}
+
+// NewWatcher creates a Watcher that fetches NDFs from the source. If verifier
+// is not nil, then the source must provide signed NDFs, as produced by
+// SignedNetworkDefinition.Marshal, and only NDFs with a valid signature are
+// accepted. Otherwise, the source may provide JSON or compact NDFs.
+func NewWatcher(source Source, verifier Verifier) *Watcher {
+	return &Watcher{
+		source:      source,
+		verifier:    verifier,
+		subscribers: make(map[uint64]chan *NetworkDefinition),
+	}
+}
+
+// Current returns the most recently accepted NDF or nil if none has been
+// accepted. The returned NDF is shared with subscribers and must not be
+// modified; use NetworkDefinition.DeepCopy to get a modifiable copy.
+func (w *Watcher) Current() *NetworkDefinition {
+	return w.current.Load()
+}
+
+// Subscribe returns a channel that receives every NDF accepted from now on
+// and a function that unsubscribes and closes the channel. If the subscriber
+// falls behind, then older NDFs waiting in the channel are replaced with the
+// newest one so that the latest NDF is never dropped.
+func (w *Watcher) Subscribe(buffer int) (<-chan *NetworkDefinition, func()) {
+	if buffer < 1 {
+		buffer = 1
+	}
+	ch := make(chan *NetworkDefinition, buffer)
+
+	w.subMux.Lock()
+	subID := w.nextSubID
+	w.nextSubID++
+	w.subscribers[subID] = ch
+	w.subMux.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			w.subMux.Lock()
+			delete(w.subscribers, subID)
+			w.subMux.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Poll fetches the NDF from the source once and publishes it if its timestamp
+// is newer than the current NDF. Returns true if a new NDF was published. An
+// error is returned if fetching, parsing, or verification fails or if the
+// timestamp is older than the current NDF.
+func (w *Watcher) Poll() (bool, error) {
+	w.pollMux.Lock()
+	defer w.pollMux.Unlock()
+
+	data, err := w.source.Fetch()
+	if err != nil {
+		return false, errors.Wrap(err, fetchNdfErr)
+	}
+	if len(data) == 0 || string(bytes.TrimSpace(data)) == NO_NDF {
+		return false, nil
+	}
+
+	ndf, err := w.parse(data)
+	if err != nil {
+		return false, errors.Wrap(err, parseFetchedErr)
+	}
+	if cs, ok := w.source.(CachingSource); ok {
+		cs.Accept()
+	}
+
+	if current := w.current.Load(); current != nil {
+		if ndf.Timestamp.Before(current.Timestamp) {
+			return false, errors.Errorf(
+				timestampRegressErr, ndf.Timestamp, current.Timestamp)
+		} else if !ndf.Timestamp.After(current.Timestamp) {
+			return false, nil
+		}
+	}
+
+	w.current.Store(ndf)
+	w.publish(ndf)
+	return true, nil
+}
+
+// Start polls the source every interval until the quit channel is closed or
+// receives a value. Errors are logged. This function is meant to be run in its
+// own thread.
+func (w *Watcher) Start(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	jww.DEBUG.Printf("Starting NDF watcher polling every %s.", interval)
+
+	for {
+		if _, err := w.Poll(); err != nil {
+			jww.WARN.Printf("NDF watcher: %+v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-quit:
+			jww.DEBUG.Printf("Stopping NDF watcher.")
+			return
+		}
+	}
+}
+
+// parse decodes the fetched data and verifies its signature if the watcher
+// has a verifier.
+func (w *Watcher) parse(data []byte) (*NetworkDefinition, error) {
+	if w.verifier == nil {
+		return UnmarshalFormat(data)
+	}
+
+	signed, err := UnmarshalSigned(data)
+	if err != nil {
+		return nil, err
+	}
+	return Verify(signed, w.verifier)
+}
+
+// publish sends the NDF to every subscriber, replacing any NDFs that a slow
+// subscriber has not yet received.
+func (w *Watcher) publish(ndf *NetworkDefinition) {
+	w.subMux.Lock()
+	defer w.subMux.Unlock()
+
+	for _, ch := range w.subscribers {
+		for {
+			select {
+			case ch <- ndf:
+			default:
+				// Drop the oldest waiting NDF and try again
+				select {
+				case <-ch:
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+// FileSource is a CachingSource that reads an NDF from a local file. Once an
+// NDF is accepted, the file is only read again when its modification time
+// changes.
+type FileSource struct {
+	path         string
+	lastModified time.Time
+	pending      time.Time // Modification time of the last file read
+}
+
+// NewFileSource returns a FileSource for the file at the path.
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// Fetch returns the contents of the file if it was modified since the last
+// accepted NDF was read. A missing file is treated as the NDF not being
+// available.
+func (fs *FileSource) Fetch() ([]byte, error) {
+	path, err := utils.ExpandPath(fs.path)
+	if err != nil {
+		return nil, err
+	}
+	if !utils.FileExists(path) {
+		return nil, nil
+	}
+
+	modified, err := utils.GetLastModified(path)
+	if err != nil {
+		return nil, err
+	}
+	if modified.Equal(fs.lastModified) {
+		return nil, nil
+	}
+
+	data, err := utils.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fs.pending = modified
+
+	return data, nil
+}
+
+// Accept records the modification time of the file returned by the last call
+// to Fetch so that it is not read again until it changes.
+func (fs *FileSource) Accept() {
+	fs.lastModified = fs.pending
+}
+
+// HTTPSource is a CachingSource that downloads an NDF from a URL. It uses the
+// ETag and Last-Modified headers of the response with the last accepted NDF so
+// that an unchanged NDF is not downloaded again.
+type HTTPSource struct {
+	url          string
+	client       *http.Client
+	etag         string
+	lastModified string
+
+	// Headers of the last response, used once its NDF is accepted
+	pendingEtag         string
+	pendingLastModified string
+}
+
+// NewHTTPSource returns an HTTPSource for the URL. If client is nil, then
+// http.DefaultClient is used.
+func NewHTTPSource(url string, client *http.Client) *HTTPSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPSource{url: url, client: client}
+}
+
+// Fetch downloads the NDF. It returns nil data if the server responds that
+// the NDF has not been modified.
+func (hs *HTTPSource) Fetch() ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, hs.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if hs.etag != "" {
+		req.Header.Set("If-None-Match", hs.etag)
+	}
+	if hs.lastModified != "" {
+		req.Header.Set("If-Modified-Since", hs.lastModified)
+	}
+
+	resp, err := hs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, nil
+	default:
+		return nil, errors.Errorf(httpStatusErr, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFetchSize {
+		return nil, errors.Errorf(httpTooLargeErr, maxFetchSize)
+	}
+
+	hs.pendingEtag = resp.Header.Get("ETag")
+	hs.pendingLastModified = resp.Header.Get("Last-Modified")
+
+	return data, nil
+}
+
+// Accept records the cache headers of the response returned by the last call
+// to Fetch so that they are sent with the next request.
+func (hs *HTTPSource) Accept() {
+	hs.etag = hs.pendingEtag
+	hs.lastModified = hs.pendingLastModified
+}
@@ -0,0 +1,391 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2024 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package ndf
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/xx_network/primitives/utils"
+)
+
+// testSource is a Source that returns the queued data in order.
+type testSource struct {
+	queue [][]byte
+	mux   sync.Mutex
+}
+
+func (ts *testSource) push(data []byte) {
+	ts.mux.Lock()
+	defer ts.mux.Unlock()
+	ts.queue = append(ts.queue, data)
+}
+
+func (ts *testSource) Fetch() ([]byte, error) {
+	ts.mux.Lock()
+	defer ts.mux.Unlock()
+	if len(ts.queue) == 0 {
+		return nil, nil
+	}
+	data := ts.queue[0]
+	ts.queue = ts.queue[1:]
+	return data, nil
+}
+
+// newTimestampedNdf returns the full test NDF with the timestamp moved by the
+// given offset.
+func newTimestampedNdf(t *testing.T, offset time.Duration) *NetworkDefinition {
+	netDef := newFullTestNdf(t)
+	netDef.Timestamp = netDef.Timestamp.Add(offset)
+	return netDef
+}
+
+// marshalTestNdf returns the JSON encoding of the NDF.
+func marshalTestNdf(t *testing.T, netDef *NetworkDefinition) []byte {
+	data, err := netDef.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal NDF: %+v", err)
+	}
+	return data
+}
+
+// Tests that Watcher.Poll accepts newer NDFs, ignores NDFs with the same
+// timestamp, and rejects NDFs with an older timestamp.
+func TestWatcher_Poll(t *testing.T) {
+	source := &testSource{}
+	w := NewWatcher(source, nil)
+
+	if w.Current() != nil {
+		t.Fatalf("New watcher has an NDF.")
+	}
+
+	source.push(marshalTestNdf(t, newTimestampedNdf(t, 0)))
+	if updated, err := w.Poll(); err != nil || !updated {
+		t.Fatalf("Failed to accept first NDF (%t): %+v", updated, err)
+	}
+	if !w.Current().Equal(newTimestampedNdf(t, 0)) {
+		t.Errorf("Unexpected current NDF: %+v", w.Current())
+	}
+
+	source.push(marshalTestNdf(t, newTimestampedNdf(t, 0)))
+	if updated, err := w.Poll(); err != nil || updated {
+		t.Errorf("Unexpected result for same timestamp (%t): %+v", updated, err)
+	}
+
+	source.push(marshalTestNdf(t, newTimestampedNdf(t, -time.Minute)))
+	_, err := w.Poll()
+	if err == nil || !strings.Contains(err.Error(), "older than") {
+		t.Errorf("Unexpected error for older timestamp: %+v", err)
+	}
+
+	source.push(marshalTestNdf(t, newTimestampedNdf(t, time.Minute)))
+	if updated, err := w.Poll(); err != nil || !updated {
+		t.Errorf("Failed to accept newer NDF (%t): %+v", updated, err)
+	}
+	if !w.Current().Timestamp.Equal(newTimestampedNdf(t, time.Minute).Timestamp) {
+		t.Errorf("Current NDF was not updated.")
+	}
+}
+
+// Tests that Watcher.Poll ignores NO_NDF responses and empty fetches and
+// returns an error for data that is not an NDF.
+func TestWatcher_Poll_NoNdf(t *testing.T) {
+	source := &testSource{}
+	w := NewWatcher(source, nil)
+
+	for _, data := range [][]byte{nil, []byte(NO_NDF), []byte(NO_NDF + "\n")} {
+		source.push(data)
+		if updated, err := w.Poll(); err != nil || updated {
+			t.Errorf("Unexpected result for %q (%t): %+v", data, updated, err)
+		}
+	}
+
+	source.push([]byte("not an NDF"))
+	if _, err := w.Poll(); err == nil {
+		t.Errorf("Poll did not return an error for invalid data.")
+	}
+}
+
+// Tests that a Watcher with a verifier only accepts NDFs with a valid
+// signature.
+func TestWatcher_Poll_Verify(t *testing.T) {
+	pubKey, privKey, _ := ed25519.GenerateKey(rand.Reader)
+	verifier, err := NewEd25519Verifier(pubKey)
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %+v", err)
+	}
+
+	source := &testSource{}
+	w := NewWatcher(source, verifier)
+
+	signed, err := Sign(newTimestampedNdf(t, 0), NewEd25519Signer(privKey))
+	if err != nil {
+		t.Fatalf("Failed to sign NDF: %+v", err)
+	}
+	signed.Signature[0] ^= 0xFF
+	data, _ := signed.Marshal()
+	source.push(data)
+	if _, err = w.Poll(); err == nil {
+		t.Errorf("Poll accepted an NDF with an invalid signature.")
+	}
+
+	source.push(marshalTestNdf(t, newTimestampedNdf(t, 0)))
+	if _, err = w.Poll(); err == nil {
+		t.Errorf("Poll accepted an unsigned NDF.")
+	}
+
+	signed.Signature[0] ^= 0xFF
+	data, _ = signed.Marshal()
+	source.push(data)
+	if updated, err := w.Poll(); err != nil || !updated {
+		t.Errorf("Failed to accept signed NDF (%t): %+v", updated, err)
+	}
+}
+
+// Tests that subscribers receive published NDFs, that a slow subscriber
+// receives the newest NDF, and that unsubscribing closes the channel.
+func TestWatcher_Subscribe(t *testing.T) {
+	source := &testSource{}
+	w := NewWatcher(source, nil)
+
+	fast, unsubscribeFast := w.Subscribe(10)
+	slow, unsubscribeSlow := w.Subscribe(1)
+
+	for i := 0; i < 3; i++ {
+		offset := time.Duration(i) * time.Minute
+		source.push(marshalTestNdf(t, newTimestampedNdf(t, offset)))
+		if _, err := w.Poll(); err != nil {
+			t.Fatalf("Poll %d returned an error: %+v", i, err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		received := <-fast
+		expected := newTimestampedNdf(t, time.Duration(i)*time.Minute).Timestamp
+		if !received.Timestamp.Equal(expected) {
+			t.Errorf("Unexpected NDF %d.\nexpected: %s\nreceived: %s",
+				i, expected, received.Timestamp)
+		}
+	}
+
+	if received := <-slow; received != w.Current() {
+		t.Errorf("Slow subscriber did not receive the newest NDF.")
+	}
+
+	unsubscribeFast()
+	unsubscribeFast()
+	if _, ok := <-fast; ok {
+		t.Errorf("Channel not closed after unsubscribing.")
+	}
+
+	source.push(marshalTestNdf(t, newTimestampedNdf(t, time.Hour)))
+	if _, err := w.Poll(); err != nil {
+		t.Fatalf("Poll returned an error: %+v", err)
+	}
+	if received := <-slow; received != w.Current() {
+		t.Errorf("Remaining subscriber did not receive the NDF.")
+	}
+	unsubscribeSlow()
+}
+
+// Tests that Watcher.Start polls the source until quit is closed.
+func TestWatcher_Start(t *testing.T) {
+	source := &testSource{}
+	source.push(marshalTestNdf(t, newTimestampedNdf(t, 0)))
+	w := NewWatcher(source, nil)
+	updates, unsubscribe := w.Subscribe(1)
+	defer unsubscribe()
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		w.Start(time.Millisecond, quit)
+		close(done)
+	}()
+
+	select {
+	case <-updates:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for first NDF.")
+	}
+
+	source.push(marshalTestNdf(t, newTimestampedNdf(t, time.Minute)))
+	select {
+	case <-updates:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for second NDF.")
+	}
+
+	close(quit)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Watcher did not stop.")
+	}
+}
+
+// Tests that FileSource.Fetch only returns the file when it has been modified
+// and treats a missing file as no NDF.
+func TestFileSource_Fetch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ndf.json")
+	fs := NewFileSource(path)
+
+	if data, err := fs.Fetch(); err != nil || data != nil {
+		t.Errorf("Unexpected result for missing file: %q, %+v", data, err)
+	}
+
+	expected := marshalTestNdf(t, newTimestampedNdf(t, 0))
+	if err := utils.WriteFileDef(path, expected); err != nil {
+		t.Fatalf("Failed to write file: %+v", err)
+	}
+
+	if data, err := fs.Fetch(); err != nil || string(data) != string(expected) {
+		t.Errorf("Unexpected data.\nexpected: %s\nreceived: %s\nerror: %+v",
+			expected, data, err)
+	}
+	if data, err := fs.Fetch(); err != nil || string(data) != string(expected) {
+		t.Errorf("Unaccepted file not read again: %q, %+v", data, err)
+	}
+	fs.Accept()
+	if data, err := fs.Fetch(); err != nil || data != nil {
+		t.Errorf("Unexpected result for unmodified file: %q, %+v", data, err)
+	}
+
+	expected = marshalTestNdf(t, newTimestampedNdf(t, time.Minute))
+	if err := utils.WriteFileDef(path, expected); err != nil {
+		t.Fatalf("Failed to write file: %+v", err)
+	}
+	modified := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatalf("Failed to set modification time: %+v", err)
+	}
+
+	if data, err := fs.Fetch(); err != nil || string(data) != string(expected) {
+		t.Errorf("Unexpected data after modification: %q, %+v", data, err)
+	}
+}
+
+// Tests that HTTPSource.Fetch downloads the NDF, sends the cache headers of
+// the previous response, and treats 304 Not Modified as no change.
+func TestHTTPSource_Fetch(t *testing.T) {
+	data := marshalTestNdf(t, newTimestampedNdf(t, 0))
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			switch {
+			case requests == 1:
+				_, _ = w.Write([]byte(NO_NDF))
+			case r.Header.Get("If-None-Match") == `"v1"`:
+				w.WriteHeader(http.StatusNotModified)
+			default:
+				w.Header().Set("ETag", `"v1"`)
+				_, _ = w.Write(data)
+			}
+		}))
+	defer server.Close()
+
+	w := NewWatcher(NewHTTPSource(server.URL, server.Client()), nil)
+
+	if updated, err := w.Poll(); err != nil || updated {
+		t.Errorf("Unexpected result for NO_NDF (%t): %+v", updated, err)
+	}
+	if updated, err := w.Poll(); err != nil || !updated {
+		t.Errorf("Failed to accept NDF (%t): %+v", updated, err)
+	}
+	if updated, err := w.Poll(); err != nil || updated {
+		t.Errorf("Unexpected result for unmodified NDF (%t): %+v", updated, err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, received %d.", requests)
+	}
+}
+
+// Tests that a corrupt first response from an HTTPSource does not stop the
+// watcher from accepting the correct NDF served with the same ETag.
+func TestHTTPSource_Fetch_BadThenGood(t *testing.T) {
+	data := marshalTestNdf(t, newTimestampedNdf(t, 0))
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", "Wed, 17 May 2023 12:00:00 GMT")
+			switch {
+			case r.Header.Get("If-None-Match") == `"v1"`:
+				w.WriteHeader(http.StatusNotModified)
+			case requests == 1:
+				_, _ = w.Write(data[:len(data)/2])
+			default:
+				_, _ = w.Write(data)
+			}
+		}))
+	defer server.Close()
+
+	w := NewWatcher(NewHTTPSource(server.URL, server.Client()), nil)
+
+	if _, err := w.Poll(); err == nil {
+		t.Errorf("No error for corrupt NDF.")
+	}
+	if updated, err := w.Poll(); err != nil || !updated {
+		t.Errorf("Failed to accept NDF after corrupt response (%t): %+v",
+			updated, err)
+	}
+	if updated, err := w.Poll(); err != nil || updated {
+		t.Errorf("Unexpected result for unmodified NDF (%t): %+v", updated, err)
+	}
+}
+
+// Tests that a FileSource reads the file again after its content failed to
+// parse, even if the modification time did not change.
+func TestFileSource_Fetch_BadThenGood(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ndf.json")
+	w := NewWatcher(NewFileSource(path), nil)
+	modified := time.Now()
+
+	data := marshalTestNdf(t, newTimestampedNdf(t, 0))
+	for i, contents := range [][]byte{data[:len(data)/2], data} {
+		if err := utils.WriteFileDef(path, contents); err != nil {
+			t.Fatalf("Failed to write file: %+v", err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatalf("Failed to set modification time: %+v", err)
+		}
+
+		updated, err := w.Poll()
+		if i == 0 && err == nil {
+			t.Errorf("No error for corrupt NDF.")
+		} else if i == 1 && (err != nil || !updated) {
+			t.Errorf("Failed to accept NDF after corrupt file (%t): %+v",
+				updated, err)
+		}
+	}
+}
+
+// Error path: Tests that HTTPSource.Fetch returns an error for an error
+// status.
+func TestHTTPSource_Fetch_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+	defer server.Close()
+
+	_, err := NewHTTPSource(server.URL, server.Client()).Fetch()
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+}